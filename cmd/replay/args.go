@@ -0,0 +1,73 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"io"
+	"time"
+
+	"github.com/anuchito/replay/internal/app"
+	"github.com/anuchito/replay/internal/ui"
+)
+
+// cliArgs holds the parsed command line.
+type cliArgs struct {
+	positional []string
+	exportDir  string
+	resume     bool          // continue a saved session
+	play       bool          // start in autoplay
+	interval   time.Duration // autoplay step interval
+	hook       string        // shell command run after every step
+	timeLapse  bool          // pace autoplay by commit dates
+	scale      float64       // time-lapse speed-up factor
+	maxGap     time.Duration // longest time-lapse pause
+	splitWidth int           // narrowest terminal for the side-by-side diff
+	color      string        // when to use color: never, auto or always
+	logSize    int           // commits the picker loads at a time
+	all        bool          // pick from every ref's history
+	branch     string        // pick from this branch's history
+	refs       bool          // pick the branch, tag or reflog entry first
+}
+
+// parseArgs parses flags and positional arguments. Flags may appear before,
+// between or after the commit arguments.
+func parseArgs(args []string) (cliArgs, error) {
+	var a cliArgs
+	fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.StringVar(&a.exportDir, "export-dir", "", "")
+	fs.BoolVar(&a.resume, "resume", false, "")
+	fs.BoolVar(&a.play, "play", false, "")
+	fs.DurationVar(&a.interval, "interval", app.DefaultInterval, "")
+	fs.StringVar(&a.hook, "hook", "", "")
+	fs.BoolVar(&a.timeLapse, "timelapse", false, "")
+	fs.Float64Var(&a.scale, "scale", app.DefaultScale, "")
+	fs.DurationVar(&a.maxGap, "max-gap", app.DefaultMaxGap, "")
+	fs.IntVar(&a.splitWidth, "split-width", ui.DefaultSplitWidth, "")
+	fs.StringVar(&a.color, "color", "auto", "")
+	fs.IntVar(&a.logSize, "log-size", defaultLogSize, "")
+	fs.BoolVar(&a.all, "all", false, "")
+	fs.StringVar(&a.branch, "branch", "", "")
+	fs.BoolVar(&a.refs, "refs", false, "")
+
+	for {
+		if err := fs.Parse(args); err != nil {
+			return a, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			break
+		}
+		a.positional = append(a.positional, args[0])
+		args = args[1:]
+	}
+	switch {
+	case a.logSize < 1:
+		return a, errors.New("--log-size must be at least 1")
+	case a.all && a.branch != "":
+		return a, errors.New("--all and --branch can't be used together")
+	case a.refs && (a.all || a.branch != ""):
+		return a, errors.New("--refs can't be used with --all or --branch")
+	}
+	return a, nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+
+	"github.com/anuchito/replay/internal/config"
+	"github.com/anuchito/replay/internal/ui"
+)
+
+// settings are what the user's config file sets up.
+type settings struct {
+	keys  *ui.Keymap
+	theme *ui.Theme
+}
+
+// loadSettings builds the keymap and theme from the user's config file, or
+// the defaults if there is none.
+func loadSettings() (settings, error) {
+	path, err := config.Path()
+	if err != nil {
+		return settings{keys: ui.DefaultKeymap()}, nil // no home directory to look in
+	}
+	c, err := config.Load(path)
+	if err != nil {
+		return settings{}, err
+	}
+	km, err := ui.NewKeymap(c.Keymap, c.Keys)
+	if err != nil {
+		return settings{}, fmt.Errorf("%s: %w", path, err)
+	}
+	theme, err := loadTheme(c)
+	if err != nil {
+		return settings{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return settings{keys: km, theme: theme}, nil
+}
+
+// loadTheme builds the theme c names: one of the user's own, or else a
+// built-in one.
+func loadTheme(c config.Config) (*ui.Theme, error) {
+	styles, ok := c.Themes[c.Theme]
+	if !ok {
+		return ui.NewTheme(c.Theme, nil)
+	}
+	styles = maps.Clone(styles)
+	base := styles["base"]
+	delete(styles, "base")
+	theme, err := ui.NewTheme(base, styles)
+	if err != nil {
+		return nil, fmt.Errorf("theme %s: %w", c.Theme, err)
+	}
+	return theme, nil
+}
@@ -1,18 +1,22 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
-	"io"
 	"os"
 	"os/signal"
 	"runtime/debug"
 	"syscall"
+	"time"
 
 	"golang.org/x/term"
 
 	"github.com/anuchito/replay/internal/app"
+	"github.com/anuchito/replay/internal/export"
 	"github.com/anuchito/replay/internal/git"
 	"github.com/anuchito/replay/internal/navigator"
+	"github.com/anuchito/replay/internal/sessions"
 	"github.com/anuchito/replay/internal/ui"
 )
 
@@ -42,332 +46,549 @@ func main() {
 
 	client := git.NewClient(cwd)
 	display := ui.New(os.Stdout)
+	set, setErr := loadSettings()
 
-	// Handle help/version flags
+	// Handle help/version flags; a broken config file doesn't stop them
 	if len(os.Args) >= 2 {
 		switch os.Args[1] {
 		case "-h", "--help", "help":
-			printUsage()
-			os.Exit(0)
+			showHelp(set, setErr)
 		case "-v", "--version", "version":
 			fmt.Printf("replay %s\n", getVersion())
 			os.Exit(0)
 		}
 	}
+	if setErr != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", setErr)
+		os.Exit(1)
+	}
+	display.Keys = set.keys
+
+	if len(os.Args) == 2 && os.Args[1] == "sessions" {
+		if err := listSessions(client); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	args, err := parseArgs(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) { // -h after other arguments
+		showHelp(set, nil)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(2)
+	}
+	colors, err := ui.DetectColorMode(args.color, term.IsTerminal(int(os.Stdout.Fd())), os.Getenv)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(2)
+	}
+	ui.SetTheme(set.theme, colors)
 
-	var opts app.RunOptions
+	opts := app.RunOptions{ExportDir: args.exportDir}
+	var saved *sessions.Session
 
-	switch len(os.Args) {
-	case 1:
+	switch {
+	case args.resume:
+		start := ""
+		if len(args.positional) > 0 {
+			start = args.positional[0]
+		}
+		s, err := findSession(client, start)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		opts.StartCommit, opts.EndCommit = s.Start, s.End
+		if opts.ExportDir == "" {
+			opts.ExportDir = s.ExportDir
+		}
+		saved = &s
+	case len(args.positional) == 0:
 		// No args — show interactive picker
-		selected, err := pickStartCommit(client)
+		start, end, err := pickInteractively(client, display.Keys, &args)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			os.Exit(1)
 		}
-		if selected == nil {
+		if start == nil {
 			os.Exit(0)
 		}
-		opts.StartCommit = selected.Hash
-	case 2:
-		opts.StartCommit = os.Args[1]
+		opts.StartCommit = start.Rev()
+		if end != nil {
+			opts.EndCommit = end.Rev()
+		} else if opts.EndCommit, err = pickedEnd(client, start.Rev(), args); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	case len(args.positional) == 1:
+		opts.StartCommit = args.positional[0]
 	default:
-		opts.StartCommit = os.Args[1]
-		opts.EndCommit = os.Args[2]
+		opts.StartCommit = args.positional[0]
+		opts.EndCommit = args.positional[1]
 	}
 
-	if err := run(client, display, opts); err != nil {
+	if err := run(client, display, opts, args, saved); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
 }
 
-func pickStartCommit(client git.GitClient) (*navigator.Commit, error) {
+// refLogSize is how many reflog entries the ref picker lists.
+const refLogSize = 20
+
+// pickInteractively runs the pickers of the no-argument flow. With
+// args.refs, the user first picks a branch, tag or reflog entry, and
+// pickRange then shows its history, with args.branch set to it; quitting
+// the commit picker goes back to the refs.
+func pickInteractively(client git.GitClient, km *ui.Keymap, args *cliArgs) (start, end *navigator.Commit, err error) {
 	isRepo, err := client.IsRepo()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	if !isRepo {
-		return nil, fmt.Errorf("not a git repository")
+		return nil, nil, fmt.Errorf("not a git repository")
+	}
+	if !args.refs {
+		return pickRange(client, km, *args, "")
 	}
 
-	commits, err := client.Log(defaultLogSize)
+	refs, err := client.Refs(refLogSize)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+	if len(refs) == 0 {
+		return nil, nil, fmt.Errorf("no branches or tags found")
+	}
+	for {
+		var ref *navigator.Ref
+		err := inPicker(km, func(opts ui.PickOptions) (err error) {
+			ref, err = ui.PickRef(refs, keyEvents(), os.Stdout, opts)
+			return err
+		})
+		if err != nil || ref == nil {
+			return nil, nil, err
+		}
+		args.branch = ref.Rev()
+		fmt.Print("\x1b[2J\x1b[H")
+		start, end, err = pickRange(client, km, *args, fmt.Sprintf("Select a commit of %s to replay from:", ref.Name))
+		if err != nil || start != nil {
+			return start, end, err
+		}
+		fmt.Print("\x1b[2J\x1b[H")
+	}
+}
+
+// pickRange lets the user pick a commit to start from, or a range, from
+// the history of HEAD, of args.branch or, with args.all, of every ref.
+// The picker loads args.logSize commits at a time, paging in more as it
+// scrolls. end is nil unless a range was picked; a range must pass the
+// checks replaying it would make. title replaces the picker's default
+// first line unless empty.
+func pickRange(client git.GitClient, km *ui.Keymap, args cliArgs, title string) (start, end *navigator.Commit, err error) {
+	source := git.LogOptions{All: args.all, Max: args.logSize}
+	if args.branch != "" {
+		source.Revs = []string{args.branch}
+	}
+	commits, err := client.LogPage(source)
+	if err != nil {
+		return nil, nil, err
 	}
 	if len(commits) == 0 {
-		return nil, fmt.Errorf("no commits found")
+		return nil, nil, fmt.Errorf("no commits found")
+	}
+	// A short first page is all of history; otherwise the picker pages in
+	// older commits as the cursor nears the end.
+	var load func(skip int) ([]navigator.Commit, error)
+	if len(commits) == args.logSize {
+		load = func(skip int) ([]navigator.Commit, error) {
+			page := source
+			page.Skip = skip
+			return client.LogPage(page)
+		}
+	}
+
+	check := func(start, end navigator.Commit) error {
+		return app.Validate(client, app.RunOptions{StartCommit: start.Rev(), EndCommit: end.Rev(), ExportDir: args.exportDir})
 	}
+	count := func(start, end navigator.Commit) (int, error) {
+		return client.CountRange(start.Rev(), end.Rev())
+	}
+	err = inPicker(km, func(opts ui.PickOptions) (err error) {
+		opts.Title, opts.Load, opts.CheckRange, opts.CountRange = title, load, check, count
+		opts.Diff, opts.SplitWidth = client.ShowDiff, args.splitWidth
+		start, end, err = ui.PickRange(commits, keyEvents(), os.Stdout, opts)
+		return err
+	})
+	return start, end, err
+}
 
-	// Enter raw mode for the picker
+// inPicker runs pick with the terminal in raw mode, passing it the options
+// every picker shares: the terminal's size, its resizes and the keymap.
+func inPicker(km *ui.Keymap, pick func(opts ui.PickOptions) error) error {
 	oldState, err := term.MakeRaw(int(os.Stdin.Fd()))
 	if err != nil {
-		return nil, fmt.Errorf("failed to set raw mode: %v", err)
+		return fmt.Errorf("failed to set raw mode: %v", err)
 	}
 	defer term.Restore(int(os.Stdin.Fd()), oldState)
 
-	return ui.PickCommit(commits, os.Stdin, os.Stdout, 20)
+	resized, stop := notifyResize()
+	defer stop()
+	size := func() (int, int) {
+		termW, termH, err := term.GetSize(int(os.Stdout.Fd()))
+		if err != nil {
+			return 0, 0
+		}
+		return termW, termH
+	}
+	return pick(ui.PickOptions{PageSize: 20, Size: size, Resized: resized, Keymap: km})
+}
+
+// pickedEnd returns where to end a replay from a picked commit: HEAD (an
+// empty string) by default, the branch picked from with --branch, and with
+// --all the ref most recently committed to that contains the commit if
+// HEAD doesn't.
+func pickedEnd(client git.GitClient, start string, args cliArgs) (string, error) {
+	switch {
+	case args.branch != "":
+		return args.branch, nil
+	case !args.all:
+		return "", nil
+	}
+	if onHead, err := client.IsAncestor(start, "HEAD"); err != nil || onHead {
+		return "", err
+	}
+	refs, err := client.RefsContaining(start)
+	if err != nil || len(refs) == 0 {
+		return "", err
+	}
+	return refs[0], nil
 }
 
-func run(client git.GitClient, display *ui.UI, opts app.RunOptions) error {
+// stdinKeys delivers the keys typed in the terminal. The picker and the
+// session share one reader, so that no key goes to a reader nobody is
+// listening to any more.
+var stdinKeys <-chan ui.KeyEvent
+
+func keyEvents() <-chan ui.KeyEvent {
+	if stdinKeys == nil {
+		stdinKeys = ui.NewKeyReader(os.Stdin).Events()
+	}
+	return stdinKeys
+}
+
+// run replays the range in opts. If saved is non-nil, its state is
+// restored first. The session is saved on exit so it can be resumed.
+func run(client git.GitClient, display *ui.UI, opts app.RunOptions, args cliArgs, saved *sessions.Session) error {
 	// Validate preconditions
 	if err := app.Validate(client, opts); err != nil {
 		return err
 	}
-
-	// Save original branch/state
-	originalRef, err := client.CurrentBranch()
+	start, err := client.ResolveRef(opts.StartCommit)
 	if err != nil {
 		return err
 	}
-
-	// Collect commits
-	commits, err := client.CommitRange(opts.StartCommit, opts.EndRef())
+	end, err := client.ResolveRef(opts.EndRef())
 	if err != nil {
 		return err
 	}
-	if len(commits) == 0 {
+
+	// Stream commits in the background; start as soon as the first arrive
+	loads, stopLoading := startLoading(client, opts.StartCommit, opts.EndRef())
+	defer stopLoading()
+
+	first := <-loads
+	if first.done {
+		if first.err != nil {
+			return first.err
+		}
 		return fmt.Errorf("no commits in range")
 	}
+	nav, err := navigator.NewLoadingNavigator(first.commits)
+	if err != nil {
+		return err
+	}
+	nav.SetFileLoader(client.CommitFiles)
 
-	// Create navigator
-	nav, err := navigator.NewNavigator(commits)
+	// Hide commits matching the repository's skip rules, reading on until
+	// something is left to show
+	skip, err := app.LoadSkipRules(client)
 	if err != nil {
 		return err
 	}
+	for err := nav.SetFilter(skip); err != nil; err = nav.SetFilter(skip) {
+		if err != navigator.ErrNoMatches {
+			return err
+		}
+		ev := <-loads
+		if ev.done {
+			nav.Finish()
+			loads = nil
+			if ev.err != nil {
+				return ev.err
+			}
+			fmt.Fprintf(os.Stderr, "Warning: skip rules hide every commit in the range; ignoring them\n")
+			skip = navigator.Filter{}
+			break
+		}
+		nav.Append(ev.commits)
+	}
+	// A resumed session needs every commit it refers to
+	wait := time.After(100 * time.Millisecond)
+	if saved != nil {
+		wait = nil
+	}
+	if loads, err = settle(nav, loads, wait); err != nil {
+		return err
+	}
+
+	play := app.NewAutoplay(args.interval)
+	play.TimeLapse = args.timeLapse
+	play.Scale = args.scale
+	play.MaxGap = args.maxGap
+
+	dv := ui.NewDiffView()
+	dv.SplitWidth = args.splitWidth
+	dv.Keys = display.Keys
+	info := ui.NewCommitPane()
+	info.Keys = display.Keys
+
+	s := &session{
+		client:  client,
+		nav:     nav,
+		display: display,
+		dv:      dv,
+		info:    info,
+		parser:  ui.KeyParser{Keymap: display.Keys},
+		out:     os.Stdout,
+		skip:    skip,
+		loads:   loads,
+		play:    play,
+		hook:    args.hook,
+	}
+	if saved != nil {
+		s.restore(*saved)
+	}
+
+	// checkout materializes a commit: in place by default, or into the
+	// export directory so the repository itself is never touched.
+	checkout := client.Checkout
+	restore := func() {}
+	hookDir := "."
+	if opts.ExportDir != "" {
+		hookDir = opts.ExportDir
+		exp, err := export.New(client, opts.ExportDir)
+		if err != nil {
+			return err
+		}
+		checkout = exp.Apply
+	} else {
+		// Save original branch/state
+		originalRef, err := client.CurrentBranch()
+		if err != nil {
+			return err
+		}
+		restore = func() {
+			fmt.Print("\r\nRestoring original state...\r\n")
+			client.Checkout(originalRef)
+		}
+	}
 
 	// Setup Ctrl+C handler to restore state
 	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
 	go func() {
 		<-sigCh
-		fmt.Print("\r\nRestoring original state...\r\n")
-		client.Checkout(originalRef)
+		restore()
 		os.Exit(0)
 	}()
 
 	// Ensure we restore state on exit
-	defer func() {
-		client.Checkout(originalRef)
-	}()
+	defer restore()
 
 	// Checkout starting commit
-	cur := nav.Current()
-	if err := client.Checkout(cur.Hash); err != nil {
+	s.checkout, s.hookDir = checkout, hookDir
+	if err := checkout(nav.Current().Rev()); err != nil {
 		return err
 	}
 
 	// Enter interactive mode
 	display.PrintBanner()
-	pos, total := nav.Position()
-	display.PrintCommit(cur, pos, total)
-
+	if opts.ExportDir != "" {
+		fmt.Printf("Exporting to %s\r\n\r\n", opts.ExportDir)
+	}
 	// Raw terminal input
-	buf := make([]byte, 1)
 	oldState, err := term.MakeRaw(int(os.Stdin.Fd()))
 	if err != nil {
 		return fmt.Errorf("failed to set raw mode: %v", err)
 	}
 	defer term.Restore(int(os.Stdin.Fd()), oldState)
 
-	dv := ui.NewDiffView()
+	// Redraw when the terminal is resized
+	resized, stopResize := notifyResize()
+	defer stopResize()
+	s.events, s.resized = keyEvents(), resized
 
-	// loadNextDiff fetches the diff for the next commit and caches it in dv.
-	loadNextDiff := func() {
-		next, ok := nav.Peek()
-		if !ok {
-			dv.SetDiff(nil)
-			return
-		}
-		lines, err := client.ShowDiff(next.Hash)
-		if err != nil {
-			dv.SetDiff(nil)
-			return
-		}
-		dv.SetDiff(lines)
+	s.refresh()
+	if args.play {
+		s.togglePlay()
 	}
-
-	// renderDetail performs a full-screen redraw of the detail view.
-	renderDetail := func() {
-		termW, termH, err := term.GetSize(int(os.Stdout.Fd()))
-		if err != nil {
-			termW, termH = 80, 24
-		}
-		cur := nav.Current()
-		pos, total := nav.Position()
-		next, hasNext := nav.Peek()
-		dv.Render(os.Stdout, termW, termH, cur, next, hasNext, pos, total)
+	err = s.loop()
+
+	state := s.snapshot()
+	state.Start, state.End, state.ExportDir = start, end, opts.ExportDir
+	if dir, derr := sessionsDir(client); derr == nil && sessions.Save(dir, state) == nil {
+		fmt.Print("\r\nSession saved; continue with: replay --resume\r\n")
+	} else {
+		fmt.Print("\r\nWarning: could not save the session\r\n")
 	}
+	return err
+}
 
-	// exitDetail clears the screen and returns to append-style output.
-	exitDetail := func() {
-		fmt.Print("\x1b[2J\x1b[H")
-		display.PrintBanner()
-		cur := nav.Current()
-		pos, total := nav.Position()
-		display.PrintCommit(cur, pos, total)
-	}
-
-	for {
-		_, err := os.Stdin.Read(buf)
-		if err != nil {
-			return err
-		}
-
-		switch buf[0] {
-		case 'n':
-			if err := nav.Next(); err != nil {
-				if !dv.Active {
-					display.PrintError(err.Error())
-				}
-				continue
-			}
-			cur = nav.Current()
-			if err := client.Checkout(cur.Hash); err != nil {
-				return err
-			}
-			if dv.Active {
-				loadNextDiff()
-				renderDetail()
-			} else {
-				pos, total = nav.Position()
-				display.PrintCommit(cur, pos, total)
-			}
-
-		case 'p':
-			if err := nav.Prev(); err != nil {
-				if !dv.Active {
-					display.PrintError(err.Error())
-				}
-				continue
-			}
-			cur = nav.Current()
-			if err := client.Checkout(cur.Hash); err != nil {
-				return err
-			}
-			if dv.Active {
-				loadNextDiff()
-				renderDetail()
-			} else {
-				pos, total = nav.Position()
-				display.PrintCommit(cur, pos, total)
-			}
-
-		case 'd':
-			dv.Toggle()
-			if dv.Active {
-				loadNextDiff()
-				renderDetail()
-			} else {
-				exitDetail()
-			}
-
-		case 'j':
-			if dv.Active {
-				_, termH, _ := term.GetSize(int(os.Stdout.Fd()))
-				dv.ScrollDown(termH)
-				renderDetail()
-			}
-
-		case 'k':
-			if dv.Active {
-				_, termH, _ := term.GetSize(int(os.Stdout.Fd()))
-				dv.ScrollUp(termH)
-				renderDetail()
-			}
-
-		case 4: // Ctrl+D — half page down
-			if dv.Active {
-				_, termH, _ := term.GetSize(int(os.Stdout.Fd()))
-				dv.ScrollHalfDown(termH)
-				renderDetail()
-			}
-
-		case 21: // Ctrl+U — half page up
-			if dv.Active {
-				_, termH, _ := term.GetSize(int(os.Stdout.Fd()))
-				dv.ScrollHalfUp(termH)
-				renderDetail()
-			}
-
-		case ' ': // Space — full page down
-			if dv.Active {
-				_, termH, _ := term.GetSize(int(os.Stdout.Fd()))
-				dv.ScrollPageDown(termH)
-				renderDetail()
-			}
-
-		case 0x1b: // escape sequence (arrow keys)
-			seq := make([]byte, 2)
-			if _, err := io.ReadFull(os.Stdin, seq); err != nil {
-				continue
-			}
-			if seq[0] != '[' {
-				continue
-			}
-			switch seq[1] {
-			case 'A': // arrow up
-				if dv.Active {
-					_, termH, _ := term.GetSize(int(os.Stdout.Fd()))
-					dv.ScrollUp(termH)
-					renderDetail()
-				}
-			case 'B': // arrow down
-				if dv.Active {
-					_, termH, _ := term.GetSize(int(os.Stdout.Fd()))
-					dv.ScrollDown(termH)
-					renderDetail()
-				}
-			}
+// loadEvent carries a page of commits read in the background. The last
+// event has done set, with the error that ended loading, if any.
+type loadEvent struct {
+	commits []navigator.Commit
+	done    bool
+	err     error
+}
 
-		case 'q':
-			if dv.Active {
-				fmt.Print("\x1b[2J\x1b[H")
+// startLoading streams the commit range into the returned channel. stop
+// abandons loading, terminating git if it is still running.
+func startLoading(client git.GitClient, from, to string) (<-chan loadEvent, func()) {
+	loads := make(chan loadEvent)
+	quit := make(chan struct{})
+	errStopped := errors.New("loading stopped")
+	go func() {
+		err := client.StreamRange(from, to, func(page []navigator.Commit) error {
+			select {
+			case loads <- loadEvent{commits: page}:
+				return nil
+			case <-quit:
+				return errStopped
 			}
-			fmt.Print("\r\nRestoring original state...\r\n")
-			return nil
+		})
+		if err == errStopped {
+			return
+		}
+		select {
+		case loads <- loadEvent{done: true, err: err}:
+		case <-quit:
+		}
+	}()
+	return loads, func() { close(quit) }
+}
 
-		case 3: // Ctrl+C
-			if dv.Active {
-				fmt.Print("\x1b[2J\x1b[H")
+// settle gives loading a moment to finish before the first screen, so that
+// ordinary ranges show their real total straight away. It returns nil once
+// every commit is loaded.
+// A nil deadline waits for the whole range.
+func settle(nav *navigator.Navigator, loads <-chan loadEvent, deadline <-chan time.Time) (<-chan loadEvent, error) {
+	for loads != nil {
+		select {
+		case ev := <-loads:
+			if ev.done {
+				nav.Finish()
+				return nil, ev.err
 			}
-			fmt.Print("\r\nRestoring original state...\r\n")
-			return nil
+			nav.Append(ev.commits)
+		case <-deadline:
+			return loads, nil
 		}
 	}
+	return nil, nil
 }
 
-func printUsage() {
+// showHelp prints the help text and exits. If the config file couldn't be
+// loaded, err is shown as a warning and the default controls are listed.
+func showHelp(set settings, err error) {
+	keys := set.keys
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v; showing the default keys\n", err)
+		keys = ui.DefaultKeymap()
+	}
+	printUsage(keys)
+	os.Exit(0)
+}
+
+// printUsage prints the help text, with the controls as bound in km.
+func printUsage(km *ui.Keymap) {
 	fmt.Printf("replay %s - interactively navigate Git commit history\n", getVersion())
 	fmt.Print(`
 Usage:
-  replay                          Select a commit interactively
+  replay                          Select a commit (or a range) interactively
   replay <start-commit>           Replay from commit to HEAD
   replay <start-commit> <end>     Replay from commit to end commit
+  replay --export-dir <dir> ...   Write each commit's tree to <dir> instead
+                                  of checking out in place
+  replay --resume [<start>]       Continue the last session (or the last one
+                                  starting at <start>) where it was left
+  replay sessions                 List saved sessions
+  replay --play ...               Start in autoplay
+  replay --interval <dur> ...     Autoplay step interval (default 2s)
+  replay --hook <cmd> ...         Run <cmd> with sh after every step, with
+                                  REPLAY_COMMIT set; a failure stops autoplay
+  replay --timelapse ...          Pace autoplay by the gaps between author
+                                  dates, divided by --scale (default 1440,
+                                  a day per minute) and capped at --max-gap
+                                  (default 10s)
+  replay --split-width <n> ...    Narrowest terminal for the side-by-side
+                                  diff and the picker's preview beside the
+                                  list (default 120); narrower ones get the
+                                  unified diff and the preview below
+  replay --branch <ref>           Pick from <ref>'s history and replay up
+                                  to it
+  replay --all                    Pick from the history of every branch and
+                                  tag; the replay ends at HEAD if it has the
+                                  commit, else at the newest ref that does
+  replay --refs                   Pick a branch, tag or recent reflog entry
+                                  first, then a commit from its history
+  replay --log-size <n> ...       Commits the picker loads at a time
+                                  (default 30); it loads more as you scroll
+  replay --color <when> ...       Use color: never, auto (the default: only
+                                  on a terminal, and not with NO_COLOR set)
+                                  or always
   replay -h, --help               Show this help
   replay -v, --version            Show version
 
 Interactive picker controls:
-  j / ↓      Move down
-  k / ↑      Move up
-  Enter      Select commit
-  q          Quit
-
+`)
+	fmt.Print(km.PickerHelp())
+	fmt.Print(`
 Replay mode controls:
-  n          Next commit
-  p          Previous commit
-  d          Toggle next-commit diff preview (on/off)
-  j / ↓      Scroll diff down            (detail mode)
-  k / ↑      Scroll diff up              (detail mode)
-  Ctrl+D     Scroll half page down       (detail mode)
-  Ctrl+U     Scroll half page up         (detail mode)
-  Space      Scroll full page down       (detail mode)
-  q          Quit and restore original state
-  Ctrl+C     Quit and restore original state
+`)
+	fmt.Print(km.ReplayHelp())
+	fmt.Print(`
+Key bindings:
+  Keys come from the "keymap" preset in ~/.config/replay/config.json:
+  vim (the default), emacs or arrows. "keys" binds actions to key
+  sequences, replacing the preset's bindings for those actions, e.g.
+    {"keymap": "emacs", "keys": {"next": ["n", "ctrl+x n"], "quit": ["q"]}}
+  Action names are the ones in the README. Ctrl+C always quits.
+
+Colors:
+  "theme" in config.json picks dark (the default), light or high-contrast,
+  or a theme of your own from "themes", e.g.
+    {"theme": "mine", "themes": {"mine": {"base": "light", "added": "#00af00"}}}
+  The depth (16, 256 or 24-bit colors) follows COLORTERM and TERM.
+
+Sessions:
+  On quit, the session (range, position, marks, filter, search and diff
+  view) is saved in .git/replay/sessions, one per range.
+
+Skip rules:
+  Commits matching any line of .git/replay/skip are hidden at startup,
+  one filter rule per line (# comments allowed), e.g. msg:^chore: bump deps
 
 Examples:
   replay                          Browse and pick a commit
   replay abc1234                  Replay from abc1234 to HEAD
   replay abc1234 def5678          Replay from abc1234 to def5678
+  replay --export-dir /tmp/site abc1234
+                                  Replay into /tmp/site, leaving the repo alone
+  replay --play --hook 'go test ./...' abc1234
+                                  Play through history, pausing on a failing test run
 `)
 }
@@ -0,0 +1,9 @@
+package main
+
+import "os"
+
+// notifyResize returns a nil channel: Windows has no signal for terminal
+// resizes, so the views pick up a new size on their next redraw.
+func notifyResize() (<-chan os.Signal, func()) {
+	return nil, func() {}
+}
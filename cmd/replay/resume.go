@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/anuchito/replay/internal/git"
+	"github.com/anuchito/replay/internal/navigator"
+	"github.com/anuchito/replay/internal/sessions"
+)
+
+// sessionsDir returns where the repository's sessions are saved.
+func sessionsDir(client git.GitClient) (string, error) {
+	gitDir, err := client.GitDir()
+	if err != nil {
+		return "", fmt.Errorf("not a git repository")
+	}
+	return sessions.Dir(gitDir), nil
+}
+
+// findSession looks up the session to resume: the latest one, or the latest
+// starting at start (a ref or hash prefix) if given.
+func findSession(client git.GitClient, start string) (sessions.Session, error) {
+	dir, err := sessionsDir(client)
+	if err != nil {
+		return sessions.Session{}, err
+	}
+	prefix := start
+	if full, err := client.ResolveRef(start); start != "" && err == nil {
+		prefix = full
+	}
+	return sessions.Find(dir, prefix)
+}
+
+// listSessions prints the saved sessions, newest first.
+func listSessions(client git.GitClient) error {
+	dir, err := sessionsDir(client)
+	if err != nil {
+		return err
+	}
+	list, err := sessions.List(dir)
+	if err != nil {
+		return err
+	}
+	if len(list) == 0 {
+		fmt.Println("No saved sessions.")
+		return nil
+	}
+	for _, s := range list {
+		line := fmt.Sprintf("%s  at %d/%d %s", s.Name(), s.Position, s.Total, s.Commit)
+		if len(s.Marks) > 0 {
+			names := make([]string, 0, len(s.Marks))
+			for name := range s.Marks {
+				names = append(names, "'"+name)
+			}
+			sort.Strings(names)
+			line += "  marks " + strings.Join(names, " ")
+		}
+		if s.Filter != "" {
+			line += "  filter " + s.Filter
+		}
+		fmt.Printf("%s  saved %s\n", line, s.Saved.Format("2006-01-02 15:04"))
+	}
+	fmt.Println("\nResume the latest with: replay --resume  (or replay --resume <start>)")
+	return nil
+}
+
+// snapshot captures the state worth resuming.
+func (s *session) snapshot() sessions.Session {
+	pos, total := s.nav.AbsPosition()
+	marks := map[string]string{}
+	for name, index := range s.nav.Marks() {
+		marks[string(name)] = s.nav.At(index).Rev()
+	}
+	return sessions.Session{
+		Commit:   s.nav.Current().Rev(),
+		Position: pos,
+		Total:    total,
+		Marks:    marks,
+		Filter:   s.typed.String(),
+		NoSkip:   s.skipCleared,
+		Search:   s.search.Raw,
+		Diff:     s.dv.Active,
+		Scroll:   s.dv.Offset(),
+		Unified:  !s.dv.Split,
+		Wrap:     s.dv.Wrap,
+		Folded:   s.dv.FoldedFiles(),
+		Saved:    time.Now(),
+	}
+}
+
+// restore reapplies a saved session before anything is checked out.
+// Anything that no longer applies (a commit or mark outside the range, a
+// filter with no matches) is dropped.
+func (s *session) restore(saved sessions.Session) {
+	if saved.NoSkip {
+		s.skip, s.skipCleared = navigator.Filter{}, true
+	}
+	if f, err := navigator.ParseFilter(saved.Filter); err == nil {
+		s.typed = f
+	}
+	if err := s.nav.SetFilter(s.skip.And(s.typed)); err != nil {
+		s.typed = navigator.Filter{}
+	}
+	for name, hash := range saved.Marks {
+		if index, ok := s.nav.FindHash(hash); ok && name != "" {
+			s.nav.SetMarkAt([]rune(name)[0], index)
+		}
+	}
+	if q, err := navigator.ParseQuery(saved.Search); err == nil && !q.Empty() {
+		s.search, s.matches = q, s.nav.Search(q)
+	}
+	if index, ok := s.nav.FindHash(saved.Commit); ok {
+		s.nav.GoTo(index)
+	}
+	s.dv.Split, s.dv.Wrap = !saved.Unified, saved.Wrap
+	if saved.Diff {
+		s.dv.Toggle()
+		s.loadNextDiff()
+		s.dv.FoldFiles(saved.Folded)
+		// The offset counts rows of the layout it was saved with.
+		termW, termH := s.termSize()
+		s.dv.Layout(termW)
+		s.dv.ScrollTo(saved.Scroll, termH)
+	}
+}
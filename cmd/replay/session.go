@@ -0,0 +1,1119 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"golang.org/x/term"
+
+	"github.com/anuchito/replay/internal/app"
+	"github.com/anuchito/replay/internal/git"
+	"github.com/anuchito/replay/internal/navigator"
+	"github.com/anuchito/replay/internal/ui"
+)
+
+// session is the interactive replay loop: the navigator plus the views drawn from it.
+type session struct {
+	client   git.GitClient
+	nav      *navigator.Navigator
+	display  *ui.UI
+	dv       *ui.DiffView
+	info     *ui.CommitPane
+	out      io.Writer
+	checkout func(ref string) error
+
+	search  navigator.Query // active search, empty if none
+	matches []int           // indices of commits matching search
+
+	skip        navigator.Filter // hides commits matching the repo's skip rules
+	skipCleared bool             // the user dropped the skip rules with 'F'
+	typed       navigator.Filter // filter entered with 'f'
+
+	play        *app.Autoplay
+	hook        string    // shell command run after every step, "" for none
+	hookDir     string    // working directory for hook
+	running     *app.Hook // hook run after the last step; nil once finished
+	events      <-chan ui.KeyEvent
+	resized     <-chan os.Signal // terminal size changes
+	statusShown bool             // autoplay countdown is on the current line (append mode)
+
+	parser ui.KeyParser
+	last   ui.Command // last repeatable command, for the repeat action
+
+	loads <-chan loadEvent  // commits still streaming in; nil once loaded
+	files <-chan filesEvent // file lists looked up for the filter preview; nil if none
+}
+
+// filesEvent carries the file lists of the commits at indices, looked up
+// in the background.
+type filesEvent struct {
+	indices []int
+	files   [][]string
+	err     error
+}
+
+// repeatable lists the actions the repeat action can repeat.
+var repeatable = map[ui.Action]bool{
+	ui.ActionNext: true, ui.ActionPrev: true, ui.ActionNextMatch: true, ui.ActionPrevMatch: true,
+	ui.ActionGraphForward: true, ui.ActionGraphBack: true, ui.ActionEnterBranch: true, ui.ActionLeaveBranch: true,
+	ui.ActionScrollDown: true, ui.ActionScrollUp: true, ui.ActionScrollLeft: true, ui.ActionScrollRight: true,
+	ui.ActionScrollHalfDown: true, ui.ActionScrollHalfUp: true, ui.ActionScrollPageDown: true, ui.ActionScrollPageUp: true,
+	ui.ActionNextFile: true, ui.ActionPrevFile: true, ui.ActionNextHunk: true, ui.ActionPrevHunk: true,
+	ui.ActionFaster: true, ui.ActionSlower: true,
+}
+
+// playControls are the actions that don't pause autoplay.
+var playControls = map[ui.Action]bool{
+	ui.ActionAutoplay: true, ui.ActionFaster: true, ui.ActionSlower: true, ui.ActionTimeLapse: true,
+}
+
+// readKey waits for the next keypress.
+func (s *session) readKey() (ui.Key, error) {
+	ev := <-s.events
+	return ev.Key, ev.Err
+}
+
+func (s *session) termSize() (int, int) {
+	termW, termH, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil {
+		return 80, 24
+	}
+	return termW, termH
+}
+
+// loadNextDiff fetches the diff for the next commit and caches it in dv.
+func (s *session) loadNextDiff() {
+	next, ok := s.nav.Peek()
+	if !ok {
+		s.dv.SetDiff(nil)
+		return
+	}
+	lines, err := s.client.ShowDiff(next.Rev())
+	if err != nil {
+		s.dv.SetDiff(nil)
+		return
+	}
+	s.dv.SetDiff(lines)
+}
+
+// renderDetail performs a full-screen redraw of the detail view, or of the
+// commit pane when it is open over it.
+func (s *session) renderDetail() {
+	termW, termH := s.termSize()
+	if s.info.Active {
+		s.info.Render(s.out, termW, termH, s.infoCommit(), s.parentCommits(), s.progress())
+	} else {
+		next, hasNext := s.nav.Peek()
+		s.dv.Render(s.out, termW, termH, s.nav.Current(), next, hasNext, s.progress())
+	}
+	s.drawStatus()
+}
+
+// toggleSplit switches the diff preview between side-by-side and unified.
+func (s *session) toggleSplit() {
+	if !s.dv.Active || s.info.Active {
+		return
+	}
+	s.dv.Split = !s.dv.Split
+	s.renderDetail()
+	if termW, _ := s.termSize(); s.dv.Split && !s.dv.SplitFits(termW) {
+		s.notice(fmt.Sprintf("Side-by-side needs %d columns (--split-width)", s.dv.SplitWidth))
+	}
+}
+
+// fullScreen reports whether a full-screen view is showing rather than the
+// append-style output.
+func (s *session) fullScreen() bool {
+	return s.dv.Active || s.info.Active
+}
+
+// infoCommit returns the current commit for the commit pane. Its files,
+// which the log leaves out, are looked up the first time it is shown; a
+// merge's against its first parent.
+func (s *session) infoCommit() navigator.Commit {
+	cur := s.nav.Current()
+	if cur.Files == nil {
+		if files, err := s.client.CommitFiles([]string{cur.Rev()}); err == nil {
+			s.nav.SetFiles(s.nav.Index(), files[0])
+			cur.Files = files[0]
+		}
+	}
+	return cur
+}
+
+// parentCommits returns the current commit's parents for the commit pane.
+// Parents outside the range are known only by hash.
+func (s *session) parentCommits() []navigator.Commit {
+	cur := s.nav.Current()
+	parents := make([]navigator.Commit, len(cur.Parents))
+	for i, hash := range cur.Parents {
+		parents[i] = navigator.Commit{Hash: navigator.ShortHash(hash), FullHash: hash}
+		if index, ok := s.nav.IndexOf(hash); ok {
+			parents[i] = s.nav.At(index)
+		}
+	}
+	return parents
+}
+
+// toggleInfo opens or closes the commit pane, returning to whichever view
+// was showing before.
+func (s *session) toggleInfo() {
+	s.info.Toggle()
+	if s.fullScreen() {
+		s.renderDetail()
+	} else {
+		s.exitDetail()
+	}
+}
+
+// progress builds the position indicator for the current commit.
+func (s *session) progress() ui.Progress {
+	pos, total := s.nav.Position()
+	of := 0
+	if !s.nav.Filter().Empty() {
+		of = s.nav.Len()
+	}
+	var date time.Time
+	if s.play.TimeLapse {
+		date = s.nav.Current().Date
+	}
+	return ui.Progress{
+		Pos:       pos,
+		Total:     total,
+		Of:        of,
+		Loading:   s.nav.Loading(),
+		Marks:     s.nav.MarksAt(s.nav.Index()),
+		Match:     navigator.MatchNumber(s.matches, s.nav.Index()),
+		Matches:   len(s.matches),
+		Highlight: s.search.Text,
+		Merge:     len(s.nav.Parents(s.nav.Index())) > 1,
+		Fork:      len(s.nav.Children(s.nav.Index())) > 1,
+		Branch:    s.nav.BranchDepth(),
+		Date:      date,
+		Elapsed:   s.nav.Current().Date.Sub(s.nav.At(0).Date),
+	}
+}
+
+// exitDetail clears the screen and returns to append-style output.
+func (s *session) exitDetail() {
+	fmt.Fprint(s.out, "\x1b[2J\x1b[H")
+	s.display.PrintBanner()
+	s.printCurrent()
+}
+
+func (s *session) printCurrent() {
+	s.clearStatus()
+	s.display.Width, _ = s.termSize()
+	s.display.PrintCommit(s.nav.Current(), s.progress())
+}
+
+// moved checks out the new current commit, starts the hook if one is
+// configured and redraws whichever view is active. The hook runs in the
+// background until it finishes or the next step or key cancels it.
+func (s *session) moved() error {
+	s.cancelHook()
+	if err := s.checkout(s.nav.Current().Rev()); err != nil {
+		return err
+	}
+	if s.hook != "" {
+		s.running = app.StartHook(s.hook, s.hookDir, s.nav.Current())
+	}
+	if s.dv.Active {
+		s.loadNextDiff()
+	}
+	return s.refresh()
+}
+
+// hookDone receives the result of the running hook; nil if none is
+// running.
+func (s *session) hookDone() <-chan error {
+	if s.running == nil {
+		return nil
+	}
+	return s.running.Done()
+}
+
+// hookFinished handles the result of the hook. A failing hook stops
+// autoplay and is reported, but is not fatal.
+func (s *session) hookFinished(err error) {
+	s.running = nil
+	if err != nil {
+		s.stopPlay()
+		s.flash(err.Error())
+	}
+}
+
+// cancelHook kills the hook if it is still running.
+func (s *session) cancelHook() {
+	if s.running != nil {
+		s.running.Cancel()
+		s.running = nil
+	}
+}
+
+// flash reports a non-fatal error. In detail mode it temporarily replaces
+// the status bar; the next redraw clears it.
+func (s *session) flash(msg string) {
+	s.notice("Error: " + msg)
+}
+
+// notice shows a one-line message below the output, or on the status bar
+// in detail mode.
+func (s *session) notice(msg string) {
+	s.clearStatus()
+	if !s.fullScreen() {
+		fmt.Fprintf(s.out, "%s\r\n", msg)
+		return
+	}
+	termW, termH := s.termSize()
+	fmt.Fprintf(s.out, "\x1b[%d;1H\x1b[2K%s\r", termH, ui.Truncate(msg, termW))
+}
+
+// togglePlay starts or pauses autoplay.
+func (s *session) togglePlay() {
+	if s.play.Playing() {
+		s.stopPlay()
+		return
+	}
+	next, ok := s.nav.Peek()
+	if !ok {
+		s.flash(navigator.ErrAtEnd.Error())
+		return
+	}
+	s.play.Start(time.Now(), s.nav.Current(), next)
+	s.drawStatus()
+}
+
+// reschedule restarts the countdown after the pace changed.
+func (s *session) reschedule() {
+	if next, ok := s.nav.Peek(); ok && s.play.Playing() {
+		s.play.Schedule(time.Now(), s.nav.Current(), next)
+	}
+	s.drawStatus()
+}
+
+// toggleTimeLapse switches between fixed-interval and time-lapse pacing.
+func (s *session) toggleTimeLapse() {
+	s.play.TimeLapse = !s.play.TimeLapse
+	s.refresh()
+	s.reschedule()
+}
+
+// pace describes the autoplay speed for the status line.
+func (s *session) pace() string {
+	if s.play.TimeLapse {
+		return fmt.Sprintf("time-lapse %gx, gaps up to %v", s.play.Scale, s.play.MaxGap)
+	}
+	return fmt.Sprintf("every %v", s.play.Interval)
+}
+
+// stopPlay pauses autoplay and removes its countdown.
+func (s *session) stopPlay() {
+	if !s.play.Playing() {
+		return
+	}
+	s.play.Stop()
+	if s.fullScreen() {
+		s.renderDetail()
+	} else {
+		s.clearStatus()
+	}
+}
+
+// playStep advances autoplay by one commit. Playback stops on the last
+// commit of the range and on marked commits.
+func (s *session) playStep() error {
+	if err := s.nav.Next(); err != nil {
+		s.stopPlay()
+		s.flash(err.Error())
+		return nil
+	}
+	if err := s.moved(); err != nil {
+		return err
+	}
+	if marks := s.nav.MarksAt(s.nav.Index()); len(marks) > 0 {
+		s.stopPlay()
+		s.notice(fmt.Sprintf("Autoplay stopped at mark '%c", marks[0]))
+		return nil
+	}
+	next, ok := s.nav.Peek()
+	if !ok && s.nav.Loading() {
+		next = s.nav.Current() // wait for more commits
+	} else if !ok {
+		s.stopPlay()
+		s.notice("Autoplay stopped at the end of the range")
+		return nil
+	}
+	s.play.Schedule(time.Now(), s.nav.Current(), next)
+	s.drawStatus()
+	return nil
+}
+
+// drawStatus shows the autoplay countdown on the status bar in detail mode,
+// or on the line below the last commit in append mode.
+func (s *session) drawStatus() {
+	if !s.play.Playing() {
+		return
+	}
+	termW, termH := s.termSize()
+	text := ui.Truncate(s.display.AutoplayStatus(s.play.Remaining(time.Now()), s.pace()), termW-1)
+	if s.fullScreen() {
+		fmt.Fprintf(s.out, "\x1b[%d;1H\x1b[2K%s\r", termH, text)
+		return
+	}
+	fmt.Fprintf(s.out, "\r\x1b[2K%s", text)
+	s.statusShown = true
+}
+
+// clearStatus erases the append-mode countdown before other output.
+func (s *session) clearStatus() {
+	if s.statusShown {
+		fmt.Fprint(s.out, "\r\x1b[2K")
+		s.statusShown = false
+	}
+}
+
+// goTo jumps straight to a zero-based index, checking out only the target.
+// The jump is recorded in the jump list.
+func (s *session) goTo(index int) error {
+	if index == s.nav.Index() {
+		return nil
+	}
+	return s.jump(func() error { return s.nav.JumpTo(index) })
+}
+
+// jump applies a non-sequential navigator move. Navigator errors are shown
+// to the user; only checkout failures are returned.
+func (s *session) jump(move func() error) error {
+	if err := move(); err != nil {
+		s.flash(err.Error())
+		return nil
+	}
+	return s.moved()
+}
+
+// setMark sets the mark named by k, the key typed after the set-mark keys.
+func (s *session) setMark(k ui.Key) error {
+	if !k.IsPrintable() {
+		return nil
+	}
+	if err := s.nav.SetMark([]rune(string(k))[0]); err != nil {
+		s.flash(err.Error())
+		return nil
+	}
+	return s.refresh()
+}
+
+// jumpToMark jumps to the mark named by k, the key typed after the
+// jump-to-mark keys.
+func (s *session) jumpToMark(k ui.Key) error {
+	if !k.IsPrintable() {
+		return nil
+	}
+	name := []rune(string(k))[0]
+	return s.jump(func() error { return s.nav.JumpToMark(name) })
+}
+
+// prompt reads a line of input on the bottom line (detail mode) or the
+// current line (append mode). ok is false if the user cancelled. onChange,
+// if non-nil, runs after every edit and may set the input's hint; it runs
+// again when file lists it started looking up arrive.
+func (s *session) prompt(label, initial string, onChange func(li *ui.LineInput)) (text string, ok bool, err error) {
+	li := ui.NewLineInput(label, initial)
+	if s.fullScreen() {
+		_, termH := s.termSize()
+		fmt.Fprintf(s.out, "\x1b[%d;1H", termH)
+	}
+	if onChange != nil {
+		onChange(li)
+	}
+	li.Render(s.out)
+	for {
+		var k ui.Key
+		select {
+		case ev := <-s.events:
+			if ev.Err != nil {
+				return "", false, ev.Err
+			}
+			k = ev.Key
+		case ev := <-s.files:
+			if err := s.gotFiles(ev); err != nil {
+				li.Hint = err.Error()
+			} else if onChange != nil {
+				onChange(li)
+			}
+			li.Render(s.out)
+			continue
+		}
+		done, cancel := li.Handle(k)
+		if !done && !cancel && onChange != nil {
+			onChange(li)
+		}
+		if done || cancel {
+			fmt.Fprint(s.out, "\r\x1b[2K")
+			if s.fullScreen() {
+				s.renderDetail()
+			}
+			return li.Text(), done, nil
+		}
+		li.Render(s.out)
+	}
+}
+
+// promptGoTo opens the goto prompt, seeded with k, the key typed after the
+// goto keys. Enter opens it empty; other special keys cancel.
+func (s *session) promptGoTo(k ui.Key) error {
+	initial := ""
+	switch {
+	case k.IsPrintable():
+		initial = string(k)
+	case k != ui.KeyEnter:
+		return nil
+	}
+
+	target, ok, err := s.prompt("Go to (position, hash, ref or date): ", initial, nil)
+	if err != nil || !ok {
+		return err
+	}
+	index, err := app.ResolveTarget(s.client, s.nav, target)
+	if err != nil {
+		s.flash(err.Error())
+		return nil
+	}
+	return s.goTo(index)
+}
+
+// promptSearch runs the incremental "/" search. While typing, the hint shows
+// how many commits match and which one Enter would jump to; nothing is
+// checked out until the search is confirmed. An empty search repeats the
+// previous one.
+func (s *session) promptSearch() error {
+	var q navigator.Query
+	var matches []int
+	preview := func(li *ui.LineInput) {
+		if li.Text() == "" {
+			li.Hint = ""
+			return
+		}
+		parsed, err := navigator.ParseQuery(li.Text())
+		if err != nil {
+			li.Hint = "invalid pattern"
+			return
+		}
+		q, matches = parsed, s.nav.Search(parsed)
+		target, ok := navigator.NextMatch(matches, s.nav.Index())
+		if !ok {
+			li.Hint = "no matches"
+			return
+		}
+		c := s.nav.At(target)
+		li.Hint = fmt.Sprintf("%d matches, next %d/%d %s %s", len(matches), target+1, s.nav.Len(), c.Hash, c.Message)
+	}
+
+	text, ok, err := s.prompt("/", "", preview)
+	if err != nil || !ok {
+		return err
+	}
+	if text != "" {
+		if _, err := navigator.ParseQuery(text); err != nil {
+			s.flash(err.Error())
+			return nil
+		}
+		s.search, s.matches = q, matches
+	}
+	return s.nextMatch(true, 1)
+}
+
+// nextMatch jumps count matches forward (or backward) through the commits
+// matching the active search.
+func (s *session) nextMatch(forward bool, count int) error {
+	if s.search.Empty() {
+		s.flash("no active search (use /)")
+		return nil
+	}
+	find := navigator.NextMatch
+	if !forward {
+		find = navigator.PrevMatch
+	}
+	index, ok := s.nav.Index(), false
+	for i := 0; i < count; i++ {
+		if index, ok = find(s.matches, index); !ok {
+			s.flash("no matches for " + s.search.Raw)
+			return nil
+		}
+	}
+	if index == s.nav.Index() {
+		// Only match is the current commit; redraw to show the highlight.
+		return s.refresh()
+	}
+	return s.goTo(index)
+}
+
+// applyFilter combines the skip rules with the typed filter and applies
+// them, checking out a new commit only if the current one became hidden.
+func (s *session) applyFilter() error {
+	before := s.nav.Index()
+	if err := s.nav.SetFilter(s.skip.And(s.typed)); err != nil {
+		return err
+	}
+	s.matches = s.nav.Search(s.search)
+	if s.nav.Index() != before {
+		return s.moved()
+	}
+	return s.refresh()
+}
+
+// promptFilter edits the typed filter, showing a live count of the commits
+// it would leave visible.
+func (s *session) promptFilter() error {
+	preview := func(li *ui.LineInput) {
+		f, err := navigator.ParseFilter(li.Text())
+		if err != nil {
+			li.Hint = err.Error()
+			return
+		}
+		combined := s.skip.And(f)
+		if missing := s.nav.FilesMissing(combined); len(missing) > 0 {
+			if s.files == nil {
+				s.startFiles(missing)
+			}
+			li.Hint = "loading file lists…"
+			return
+		}
+		n := 0
+		for i := 0; i < s.nav.Len(); i++ {
+			if combined.Match(s.nav.At(i)) {
+				n++
+			}
+		}
+		li.Hint = fmt.Sprintf("%d of %d commits", n, s.nav.Len())
+	}
+
+	text, ok, err := s.prompt("Filter: ", s.typed.String(), preview)
+	if err != nil || !ok {
+		return err
+	}
+	f, err := navigator.ParseFilter(text)
+	if err != nil {
+		s.flash(err.Error())
+		return nil
+	}
+	if s.files != nil {
+		s.gotFiles(<-s.files) // on failure, applying looks them up again
+	}
+	prev := s.typed
+	s.typed = f
+	if err := s.applyFilter(); err != nil {
+		s.typed = prev
+		s.flash(err.Error())
+	}
+	return nil
+}
+
+// startFiles looks up the files of the commits at indices in the
+// background, for gotFiles to record.
+func (s *session) startFiles(indices []int) {
+	revs := make([]string, len(indices))
+	for j, i := range indices {
+		revs[j] = s.nav.At(i).Rev()
+	}
+	files := make(chan filesEvent, 1)
+	s.files = files
+	go func() {
+		lists, err := s.client.CommitFiles(revs)
+		files <- filesEvent{indices: indices, files: lists, err: err}
+	}()
+}
+
+// gotFiles records file lists looked up by startFiles.
+func (s *session) gotFiles(ev filesEvent) error {
+	s.files = nil
+	if ev.err != nil {
+		return ev.err
+	}
+	for j, i := range ev.indices {
+		s.nav.SetFiles(i, ev.files[j])
+	}
+	return nil
+}
+
+// clearFilter drops the typed filter, or the skip rules once no typed
+// filter is left. The current commit is kept.
+func (s *session) clearFilter() error {
+	if !s.typed.Empty() {
+		s.typed = navigator.Filter{}
+	} else {
+		s.skip, s.skipCleared = navigator.Filter{}, true
+	}
+	return s.applyFilter()
+}
+
+// commitsAt returns the commits at the given indices.
+func (s *session) commitsAt(indices []int) []navigator.Commit {
+	commits := make([]navigator.Commit, len(indices))
+	for i, idx := range indices {
+		commits[i] = s.nav.At(idx)
+	}
+	return commits
+}
+
+// showGraph draws the inline graph around the current commit. In detail
+// mode it overlays the top of the diff area until the next redraw.
+func (s *session) showGraph(choose ui.GraphChoice) {
+	idx := s.nav.Index()
+	parents := s.commitsAt(s.nav.Parents(idx))
+	children := s.commitsAt(s.nav.Children(idx))
+	if !s.fullScreen() {
+		s.display.PrintGraph(parents, s.nav.Current(), children, choose)
+		return
+	}
+	fmt.Fprint(s.out, "\x1b[3;1H")
+	for _, line := range ui.GraphLines(parents, s.nav.Current(), children, choose) {
+		fmt.Fprintf(s.out, "\x1b[2K  %s\r\n", line)
+	}
+}
+
+// graphStep follows the history graph forward (to a child) or backward (to
+// a parent). When there are several, the graph is drawn with numbered lines
+// and a digit picks one.
+func (s *session) graphStep(forward bool) error {
+	idx := s.nav.Index()
+	candidates, choose, move := s.nav.Children(idx), ui.ChooseChild, s.nav.Forward
+	if !forward {
+		candidates, choose, move = s.nav.Parents(idx), ui.ChooseParent, s.nav.Backward
+	}
+
+	choice := 0
+	if len(candidates) > 1 {
+		s.showGraph(choose)
+		text := fmt.Sprintf("Follow which line? [1-%d], Esc cancels", len(candidates))
+		if s.fullScreen() {
+			_, termH := s.termSize()
+			fmt.Fprintf(s.out, "\x1b[%d;1H\x1b[2K%s", termH, text)
+		} else {
+			fmt.Fprintf(s.out, "%s", text)
+		}
+		k, err := s.readKey()
+		if err != nil {
+			return err
+		}
+		fmt.Fprint(s.out, "\r\x1b[2K")
+		if len(k) != 1 || k[0] < '1' || int(k[0]-'1') >= len(candidates) {
+			return s.refresh()
+		}
+		choice = int(k[0] - '1')
+	}
+
+	if err := move(choice); err != nil {
+		s.flash(err.Error())
+		return nil
+	}
+	if err := s.moved(); err != nil {
+		return err
+	}
+	if !s.fullScreen() {
+		s.showGraph(ui.ChooseNone)
+	}
+	return nil
+}
+
+// branchJump enters or leaves a merged side branch and shows where it landed.
+func (s *session) branchJump(move func() error) error {
+	if err := s.jump(move); err != nil {
+		return err
+	}
+	if !s.fullScreen() {
+		s.showGraph(ui.ChooseNone)
+	}
+	return nil
+}
+
+// refresh redraws the current view without moving.
+func (s *session) refresh() error {
+	if s.fullScreen() {
+		s.renderDetail()
+	} else {
+		s.printCurrent()
+	}
+	return nil
+}
+
+// scroller is a full-screen view that scrolls.
+type scroller interface {
+	ScrollDown(termH int)
+	ScrollUp(termH int)
+	ScrollHalfDown(termH int)
+	ScrollHalfUp(termH int)
+	ScrollPageDown(termH int)
+	ScrollPageUp(termH int)
+}
+
+// pane returns the full-screen view on top, or nil in append mode.
+func (s *session) pane() scroller {
+	switch {
+	case s.info.Active:
+		return s.info
+	case s.dv.Active:
+		return s.dv
+	}
+	return nil
+}
+
+// scroll applies a scroll to the visible pane count times and redraws once.
+func (s *session) scroll(fn func(scroller, int), count int) {
+	pane := s.pane()
+	if pane == nil {
+		return
+	}
+	_, termH := s.termSize()
+	for i := 0; i < count; i++ {
+		fn(pane, termH)
+	}
+	s.renderDetail()
+}
+
+// scrollSideways scrolls the diff preview's long lines count times.
+func (s *session) scrollSideways(fn func(), count int) {
+	if !s.dv.Active || s.info.Active || s.dv.Wrap {
+		return
+	}
+	for i := 0; i < count; i++ {
+		fn()
+	}
+	s.renderDetail()
+}
+
+// diffShown reports whether the diff preview is showing, not covered by
+// the commit pane.
+func (s *session) diffShown() bool {
+	return s.dv.Active && !s.info.Active
+}
+
+// jumpDiff moves through the diff preview's files or hunks count times
+// and redraws once.
+func (s *session) jumpDiff(fn func(*ui.DiffView, int), count int) {
+	if !s.diffShown() || s.dv.Listing() {
+		return
+	}
+	_, termH := s.termSize()
+	for i := 0; i < count; i++ {
+		fn(s.dv, termH)
+	}
+	s.renderDetail()
+}
+
+// step moves count commits (backward if negative), checking out only the
+// commit it lands on.
+func (s *session) step(count int) error {
+	if _, err := s.nav.Step(count); err != nil {
+		if err == navigator.ErrAtEnd && s.nav.Loading() {
+			s.flash("more commits are still loading")
+		} else if !s.fullScreen() {
+			s.display.PrintError(err.Error())
+		}
+		return nil
+	}
+	return s.moved()
+}
+
+// goToPosition handles a counted G: jump to the given 1-based position.
+func (s *session) goToPosition(pos int) error {
+	index, ok := s.nav.IndexAt(pos)
+	if !ok {
+		s.flash(navigator.ErrOutOfRange.Error())
+		return nil
+	}
+	return s.goTo(index)
+}
+
+// loop is the event loop. Keypresses arrive from a reader goroutine so that
+// autoplay steps and terminal resizes can be interleaved with them. The
+// keymap turns them into commands; any command other than the autoplay
+// controls pauses playback and cancels a running hook before doing its
+// usual job. Autoplay waits for the hook before stepping on.
+func (s *session) loop() error {
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	defer s.cancelHook()
+
+	for {
+		var tick <-chan time.Time
+		if s.play.Playing() {
+			tick = ticker.C
+		}
+
+		var k ui.Key
+		select {
+		case ev := <-s.events:
+			if ev.Err != nil {
+				return ev.Err
+			}
+			k = ev.Key
+		case ev := <-s.loads:
+			s.loaded(ev)
+			continue
+		case ev := <-s.files:
+			s.gotFiles(ev) // the prompt was closed; applying a filter retries
+			continue
+		case err := <-s.hookDone():
+			s.hookFinished(err)
+			continue
+		case <-s.resized:
+			s.resize()
+			continue
+		case now := <-tick:
+			if !s.play.Due(now) || s.running != nil {
+				s.drawStatus()
+				continue
+			}
+			if err := s.playStep(); err != nil {
+				return err
+			}
+			continue
+		}
+
+		for _, cmd := range s.parser.Feed(k) {
+			if !playControls[cmd.Action] {
+				s.stopPlay()
+				s.cancelHook()
+			}
+			if cmd.Action == ui.ActionRepeat {
+				if s.last.Action == "" {
+					continue
+				}
+				if cmd.Count > 0 {
+					s.last.Count = cmd.Count
+				}
+				cmd = s.last
+			} else if repeatable[cmd.Action] {
+				s.last = cmd
+			}
+
+			quit, err := s.handleKey(cmd)
+			if quit || err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// loaded adds a page of streamed commits. The views only need redrawing
+// when the next-commit diff was waiting for more commits, and when loading
+// ends so that the final total shows in detail mode.
+func (s *session) loaded(ev loadEvent) {
+	if ev.done {
+		s.nav.Finish()
+		s.loads = nil
+		if ev.err != nil {
+			s.flash(ev.err.Error())
+		}
+		if s.fullScreen() {
+			s.renderDetail()
+		}
+		return
+	}
+	_, hadNext := s.nav.Peek()
+	s.nav.Append(ev.commits)
+	if !s.search.Empty() {
+		s.matches = s.nav.Search(s.search)
+	}
+	if _, hasNext := s.nav.Peek(); s.dv.Active && !hadNext && hasNext {
+		s.loadNextDiff()
+		s.renderDetail()
+	}
+}
+
+// resize redraws the screen for the terminal's new size. The full-screen
+// views lay themselves out again and clamp their scroll offsets as they
+// render; append mode starts over from the banner, since the terminal has
+// rewrapped or cut off the lines already printed.
+func (s *session) resize() {
+	if s.fullScreen() {
+		s.renderDetail()
+		return
+	}
+	s.exitDetail()
+	s.drawStatus()
+}
+
+// handleKey runs the command's action, applying its count where it makes
+// sense. quit is true when the session should end.
+func (s *session) handleKey(cmd ui.Command) (quit bool, err error) {
+	n := cmd.N()
+	switch cmd.Action {
+	case ui.ActionNext:
+		return false, s.step(n)
+
+	case ui.ActionPrev:
+		return false, s.step(-n)
+
+	case ui.ActionAutoplay:
+		s.togglePlay()
+
+	case ui.ActionFaster:
+		for i := 0; i < n; i++ {
+			s.play.Faster()
+		}
+		s.reschedule()
+
+	case ui.ActionSlower:
+		for i := 0; i < n; i++ {
+			s.play.Slower()
+		}
+		s.reschedule()
+
+	case ui.ActionTimeLapse:
+		s.toggleTimeLapse()
+
+	case ui.ActionGoTo:
+		return false, s.promptGoTo(cmd.Arg)
+
+	case ui.ActionFirst:
+		return false, s.goTo(s.nav.First())
+
+	case ui.ActionLast:
+		if cmd.Count > 0 {
+			return false, s.goToPosition(cmd.Count)
+		}
+		return false, s.goTo(s.nav.Last())
+
+	case ui.ActionSetMark:
+		return false, s.setMark(cmd.Arg)
+
+	case ui.ActionJumpToMark:
+		return false, s.jumpToMark(cmd.Arg)
+
+	case ui.ActionJumpBack:
+		return false, s.jump(s.nav.JumpBack)
+
+	case ui.ActionJumpForward:
+		return false, s.jump(s.nav.JumpForward)
+
+	case ui.ActionSearch:
+		return false, s.promptSearch()
+
+	case ui.ActionNextMatch:
+		return false, s.nextMatch(true, n)
+
+	case ui.ActionPrevMatch:
+		return false, s.nextMatch(false, n)
+
+	case ui.ActionGraphForward:
+		return false, s.graphStep(true)
+
+	case ui.ActionGraphBack:
+		return false, s.graphStep(false)
+
+	case ui.ActionEnterBranch:
+		return false, s.branchJump(s.nav.EnterBranch)
+
+	case ui.ActionLeaveBranch:
+		return false, s.branchJump(s.nav.LeaveBranch)
+
+	case ui.ActionFilter:
+		return false, s.promptFilter()
+
+	case ui.ActionClearFilter:
+		return false, s.clearFilter()
+
+	case ui.ActionToggleInfo:
+		s.toggleInfo()
+
+	case ui.ActionToggleDiff:
+		if s.info.Active { // back to the diff from the commit pane
+			s.info.Toggle()
+			if !s.dv.Active {
+				s.dv.Toggle()
+				s.loadNextDiff()
+			}
+			s.renderDetail()
+			break
+		}
+		s.dv.Toggle()
+		if s.dv.Active {
+			s.loadNextDiff()
+			s.renderDetail()
+		} else {
+			s.exitDetail()
+		}
+
+	case ui.ActionToggleSplit:
+		s.toggleSplit()
+
+	case ui.ActionToggleWrap:
+		if s.dv.Active && !s.info.Active {
+			s.dv.Wrap = !s.dv.Wrap
+			s.renderDetail()
+		}
+
+	case ui.ActionScrollLeft:
+		s.scrollSideways(s.dv.ScrollLeft, n)
+
+	case ui.ActionScrollRight:
+		s.scrollSideways(s.dv.ScrollRight, n)
+
+	case ui.ActionScrollDown:
+		s.scroll(scroller.ScrollDown, n)
+
+	case ui.ActionScrollUp:
+		s.scroll(scroller.ScrollUp, n)
+
+	case ui.ActionScrollHalfDown:
+		s.scroll(scroller.ScrollHalfDown, n)
+
+	case ui.ActionScrollHalfUp:
+		s.scroll(scroller.ScrollHalfUp, n)
+
+	case ui.ActionScrollPageDown:
+		s.scroll(scroller.ScrollPageDown, n)
+
+	case ui.ActionScrollPageUp:
+		s.scroll(scroller.ScrollPageUp, n)
+
+	case ui.ActionNextFile:
+		s.jumpDiff((*ui.DiffView).NextFile, n)
+
+	case ui.ActionPrevFile:
+		s.jumpDiff((*ui.DiffView).PrevFile, n)
+
+	case ui.ActionNextHunk:
+		s.jumpDiff((*ui.DiffView).NextHunk, n)
+
+	case ui.ActionPrevHunk:
+		s.jumpDiff((*ui.DiffView).PrevHunk, n)
+
+	case ui.ActionFileList:
+		if s.diffShown() {
+			s.dv.ToggleFiles()
+			s.renderDetail()
+		}
+
+	case ui.ActionToggleFold:
+		if s.diffShown() {
+			_, termH := s.termSize()
+			s.dv.ToggleFold(termH)
+			s.renderDetail()
+		}
+
+	case ui.ActionSelect:
+		if s.diffShown() && s.dv.Listing() {
+			_, termH := s.termSize()
+			s.dv.OpenListed(termH)
+			s.renderDetail()
+		}
+
+	case ui.ActionQuit:
+		if s.diffShown() && s.dv.Listing() { // close the file list
+			s.dv.ToggleFiles()
+			s.renderDetail()
+			break
+		}
+		if s.fullScreen() {
+			fmt.Fprint(s.out, "\x1b[2J\x1b[H")
+		}
+		return true, nil
+	}
+	return false, nil
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"github.com/anuchito/replay/internal/app"
+	"github.com/anuchito/replay/internal/git"
+	"github.com/anuchito/replay/internal/navigator"
+	"github.com/anuchito/replay/internal/ui"
+)
+
+func TestSession_HookDoesNotDelayKeys(t *testing.T) {
+	nav, _ := navigator.NewNavigator([]navigator.Commit{{Hash: "a000000"}, {Hash: "b000000"}, {Hash: "c000000"}})
+	events := make(chan ui.KeyEvent)
+	var checkouts []string
+	s := &session{
+		nav:      nav,
+		display:  ui.New(io.Discard),
+		dv:       ui.NewDiffView(),
+		info:     ui.NewCommitPane(),
+		out:      io.Discard,
+		play:     app.NewAutoplay(app.DefaultInterval),
+		hook:     "sleep 5",
+		hookDir:  t.TempDir(),
+		events:   events,
+		checkout: func(ref string) error { checkouts = append(checkouts, ref); return nil },
+	}
+	done := make(chan error, 1)
+	go func() { done <- s.loop() }()
+
+	start := time.Now()
+	for _, k := range []ui.Key{"n", "n", "q"} {
+		select {
+		case events <- ui.KeyEvent{Key: k}:
+		case <-time.After(2 * time.Second):
+			t.Fatalf("key %q waited for the hook", k)
+		}
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected the keys handled while the hook sleeps, took %v", elapsed)
+	}
+	if len(checkouts) != 2 || checkouts[1] != "c000000" {
+		t.Errorf("expected two steps, got checkouts %v", checkouts)
+	}
+}
+
+// slowFiles is a git client whose file lists arrive once release is closed.
+type slowFiles struct {
+	git.GitClient
+	release chan struct{}
+}
+
+func (c slowFiles) CommitFiles(revs []string) ([][]string, error) {
+	<-c.release
+	files := make([][]string, len(revs))
+	for i, rev := range revs {
+		files[i] = []string{rev[:1] + ".go"}
+	}
+	return files, nil
+}
+
+func TestSession_FilterPreviewDoesNotWaitForFiles(t *testing.T) {
+	nav, _ := navigator.NewNavigator([]navigator.Commit{{Hash: "a000000"}, {Hash: "b000000"}, {Hash: "c000000"}})
+	client := slowFiles{release: make(chan struct{})}
+	nav.SetFileLoader(client.CommitFiles)
+	events := make(chan ui.KeyEvent)
+	s := &session{
+		client:   client,
+		nav:      nav,
+		display:  ui.New(io.Discard),
+		dv:       ui.NewDiffView(),
+		info:     ui.NewCommitPane(),
+		out:      io.Discard,
+		play:     app.NewAutoplay(app.DefaultInterval),
+		events:   events,
+		checkout: func(string) error { return nil },
+	}
+	done := make(chan error, 1)
+	go func() { done <- s.promptFilter() }()
+
+	for _, k := range []ui.Key{"p", "a", "t", "h", ":", "b", ".", "g", "o"} {
+		select {
+		case events <- ui.KeyEvent{Key: k}:
+		case <-time.After(2 * time.Second):
+			t.Fatalf("key %q waited for the file lists", k)
+		}
+	}
+	close(client.release)
+	events <- ui.KeyEvent{Key: ui.KeyEnter}
+	if err := <-done; err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := nav.Current().Hash; got != "b000000" {
+		t.Errorf("expected the filter to move to b000000, got %s", got)
+	}
+}
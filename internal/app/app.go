@@ -1,14 +1,23 @@
 package app
 
 import (
+	"errors"
 	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/anuchito/replay/internal/git"
+	"github.com/anuchito/replay/internal/navigator"
 )
 
 type RunOptions struct {
 	StartCommit string
 	EndCommit   string // empty defaults to "HEAD"
+	ExportDir   string // when set, trees are written here instead of checked out
 }
 
 func (o RunOptions) EndRef() string {
@@ -28,12 +37,15 @@ func Validate(client git.GitClient, opts RunOptions) error {
 		return fmt.Errorf("not a git repository")
 	}
 
-	clean, err := client.IsClean()
-	if err != nil {
-		return err
-	}
-	if !clean {
-		return fmt.Errorf("working tree is dirty, please commit or stash your changes")
+	// Exporting never touches the working tree, so local changes are fine.
+	if opts.ExportDir == "" {
+		clean, err := client.IsClean()
+		if err != nil {
+			return err
+		}
+		if !clean {
+			return fmt.Errorf("working tree is dirty, please commit or stash your changes")
+		}
 	}
 
 	if err := client.ValidateCommit(opts.StartCommit); err != nil {
@@ -57,3 +69,97 @@ func Validate(client git.GitClient, opts RunOptions) error {
 
 	return nil
 }
+
+// dateLayouts are the date formats accepted by ResolveTarget, most specific first.
+var dateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// ResolveTarget turns a goto target into a zero-based index in nav.
+// A target is tried, in order, as a 1-based position within the active
+// filter, a date (jumps to the first commit on or after it), a hash prefix
+// within the range, and finally any ref git can resolve (branch, tag,
+// HEAD~3…).
+func ResolveTarget(client git.GitClient, nav *navigator.Navigator, target string) (int, error) {
+	target = strings.TrimSpace(target)
+	if target == "" {
+		return 0, fmt.Errorf("empty target")
+	}
+
+	if n, err := strconv.Atoi(target); err == nil {
+		if i, ok := nav.IndexAt(n); ok {
+			return i, nil
+		}
+	}
+
+	for _, layout := range dateLayouts {
+		t, err := time.ParseInLocation(layout, target, time.Local)
+		if err != nil {
+			continue
+		}
+		i, ok := nav.FindAfter(t)
+		if !ok {
+			return 0, fmt.Errorf("no commit on or after %s", target)
+		}
+		return i, nil
+	}
+
+	if isHex(target) && len(target) >= 4 {
+		if i, ok := nav.FindHash(target); ok {
+			return i, nil
+		}
+	}
+
+	full, err := client.ResolveRef(target)
+	if err != nil {
+		return 0, err
+	}
+	i, ok := nav.FindHash(full)
+	if !ok {
+		return 0, fmt.Errorf("%s is not in the replay range", target)
+	}
+	return i, nil
+}
+
+func isHex(s string) bool {
+	for _, r := range s {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", r) {
+			return false
+		}
+	}
+	return true
+}
+
+// SkipRulesPath returns where the per-repository skip rules live. The file
+// sits inside .git so it never shows up as an untracked change.
+func SkipRulesPath(client git.GitClient) (string, error) {
+	gitDir, err := client.GitDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(gitDir, "replay", "skip"), nil
+}
+
+// LoadSkipRules reads the repository's skip rules. A missing file is not an
+// error and yields an empty filter.
+func LoadSkipRules(client git.GitClient) (navigator.Filter, error) {
+	path, err := SkipRulesPath(client)
+	if err != nil {
+		return navigator.Filter{}, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return navigator.Filter{}, nil
+	}
+	if err != nil {
+		return navigator.Filter{}, err
+	}
+	f, err := navigator.ParseSkipRules(string(data))
+	if err != nil {
+		return navigator.Filter{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return f, nil
+}
@@ -2,8 +2,12 @@ package app
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/anuchito/replay/internal/git"
 	"github.com/anuchito/replay/internal/navigator"
 )
 
@@ -16,6 +20,8 @@ type mockGitClient struct {
 	commits        []navigator.Commit
 	commitRangeErr error
 	branch         string
+	refs           map[string]string
+	gitDir         string
 	checkoutCalls  []string
 }
 
@@ -30,16 +36,40 @@ func (m *mockGitClient) ValidateCommit(hash string) error {
 	return nil
 }
 func (m *mockGitClient) IsAncestor(_, _ string) (bool, error) { return m.isAncestor, nil }
+func (m *mockGitClient) CountRange(_, _ string) (int, error)  { return len(m.commits), nil }
 func (m *mockGitClient) Log(_ int) ([]navigator.Commit, error) { return m.commits, nil }
+func (m *mockGitClient) LogPage(_ git.LogOptions) ([]navigator.Commit, error) {
+	return m.commits, nil
+}
+func (m *mockGitClient) RefsContaining(_ string) ([]string, error) { return nil, nil }
+func (m *mockGitClient) Refs(_ int) ([]navigator.Ref, error)       { return nil, nil }
 func (m *mockGitClient) CommitRange(_, _ string) ([]navigator.Commit, error) {
 	return m.commits, m.commitRangeErr
 }
+func (m *mockGitClient) StreamRange(_, _ string, page func([]navigator.Commit) error) error {
+	if m.commitRangeErr != nil {
+		return m.commitRangeErr
+	}
+	return page(m.commits)
+}
+func (m *mockGitClient) ResolveRef(ref string) (string, error) {
+	if full, ok := m.refs[ref]; ok {
+		return full, nil
+	}
+	return "", fmt.Errorf("unknown revision: %s", ref)
+}
+func (m *mockGitClient) GitDir() (string, error)        { return m.gitDir, nil }
+func (m *mockGitClient) WorkTree() (string, error)      { return filepath.Dir(m.gitDir), nil }
 func (m *mockGitClient) CurrentBranch() (string, error) { return m.branch, nil }
 func (m *mockGitClient) Checkout(ref string) error {
 	m.checkoutCalls = append(m.checkoutCalls, ref)
 	return nil
 }
 func (m *mockGitClient) ShowDiff(_ string) ([]string, error) { return nil, nil }
+func (m *mockGitClient) CommitFiles(_ []string) ([][]string, error) { return nil, nil }
+func (m *mockGitClient) ListTree(_ string) ([]git.TreeEntry, error)     { return nil, nil }
+func (m *mockGitClient) DiffTree(_, _ string) ([]git.FileChange, error) { return nil, nil }
+func (m *mockGitClient) ReadBlobs(_ []string, _ func(int, []byte) error) error { return nil }
 
 func TestValidate_WithEndCommit(t *testing.T) {
 	mock := &mockGitClient{
@@ -152,3 +182,101 @@ func TestValidate_DirtyWorkingTree(t *testing.T) {
 		t.Fatal("expected error for dirty working tree, got nil")
 	}
 }
+
+func TestValidate_DirtyWorkingTree_ExportDir(t *testing.T) {
+	mock := &mockGitClient{
+		isRepo:     true,
+		isClean:    false,
+		isAncestor: true,
+	}
+
+	opts := RunOptions{StartCommit: "abc1234", ExportDir: "/tmp/preview"}
+
+	err := Validate(mock, opts)
+	if err != nil {
+		t.Fatalf("expected dirty tree to be allowed when exporting, got %v", err)
+	}
+}
+
+func goToCommits() []navigator.Commit {
+	day := func(d int) time.Time { return time.Date(2024, 3, d, 9, 0, 0, 0, time.Local) }
+	return []navigator.Commit{
+		{Hash: "abc1234", Message: "first", Date: day(1)},
+		{Hash: "def5678", FullHash: "def5678aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa", Message: "second", Date: day(10)},
+		{Hash: "0123456", Message: "third", Date: day(20)},
+	}
+}
+
+func TestResolveTarget(t *testing.T) {
+	nav, _ := navigator.NewNavigator(goToCommits())
+	mock := &mockGitClient{refs: map[string]string{
+		"v1.0":       "def5678aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa",
+		"other-side": "9999999aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa",
+	}}
+
+	tests := []struct {
+		target string
+		want   int
+	}{
+		{"1", 0},
+		{"3", 2},
+		{"2024-03-05", 1},
+		{"2024-03-10", 1},
+		{"def5", 1},
+		{"0123456", 2},
+		{"v1.0", 1},
+	}
+	for _, tt := range tests {
+		got, err := ResolveTarget(mock, nav, tt.target)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", tt.target, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%q: expected index %d, got %d", tt.target, tt.want, got)
+		}
+	}
+}
+
+func TestResolveTarget_Errors(t *testing.T) {
+	nav, _ := navigator.NewNavigator(goToCommits())
+	mock := &mockGitClient{refs: map[string]string{
+		"other-side": "9999999aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa",
+	}}
+
+	for _, target := range []string{"", "2024-04-01", "other-side", "nope"} {
+		if _, err := ResolveTarget(mock, nav, target); err == nil {
+			t.Errorf("%q: expected error, got nil", target)
+		}
+	}
+}
+
+func TestLoadSkipRules(t *testing.T) {
+	gitDir := t.TempDir()
+	os.MkdirAll(filepath.Join(gitDir, "replay"), 0755)
+	os.WriteFile(filepath.Join(gitDir, "replay", "skip"), []byte("# deps\n^chore: bump\n"), 0644)
+	mock := &mockGitClient{gitDir: gitDir}
+
+	f, err := LoadSkipRules(mock)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if f.Match(navigator.Commit{Message: "chore: bump deps"}) {
+		t.Error("expected skip rule to hide dependency bumps")
+	}
+	if !f.Match(navigator.Commit{Message: "fix parser"}) {
+		t.Error("expected other commits to stay visible")
+	}
+}
+
+func TestLoadSkipRules_MissingFile(t *testing.T) {
+	mock := &mockGitClient{gitDir: t.TempDir()}
+
+	f, err := LoadSkipRules(mock)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !f.Empty() {
+		t.Errorf("expected empty filter, got %v", f)
+	}
+}
@@ -0,0 +1,167 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/anuchito/replay/internal/navigator"
+)
+
+const (
+	DefaultInterval = 2 * time.Second
+	MinInterval     = 100 * time.Millisecond
+	MaxInterval     = time.Minute
+
+	// DefaultScale plays a day of history back in a minute.
+	DefaultScale  = 1440
+	DefaultMaxGap = 10 * time.Second
+	MinScale      = 1
+	MaxScale      = 1 << 24
+)
+
+// Autoplay holds the timing state for stepping through commits on a timer.
+// It is driven by the caller's event loop and never sleeps or reads input
+// itself, so a keypress can stop it at any moment.
+//
+// In time-lapse mode the delay before a step is the real gap between the
+// two commits' author dates divided by Scale, capped at MaxGap; otherwise
+// every step waits Interval.
+type Autoplay struct {
+	Interval  time.Duration
+	TimeLapse bool
+	Scale     float64
+	MaxGap    time.Duration
+	playing   bool
+	due       time.Time
+}
+
+func NewAutoplay(interval time.Duration) *Autoplay {
+	a := &Autoplay{Interval: interval, Scale: DefaultScale, MaxGap: DefaultMaxGap}
+	a.clamp()
+	return a
+}
+
+func (a *Autoplay) Playing() bool { return a.playing }
+
+// Start begins playing; the first step, from cur to next, is scheduled from now.
+func (a *Autoplay) Start(now time.Time, cur, next navigator.Commit) {
+	a.playing = true
+	a.Schedule(now, cur, next)
+}
+
+func (a *Autoplay) Stop() { a.playing = false }
+
+// Schedule sets the time of the step from cur to next, counting from now.
+func (a *Autoplay) Schedule(now time.Time, cur, next navigator.Commit) {
+	a.due = now.Add(a.Delay(cur, next))
+}
+
+// Delay returns how long to wait before stepping from cur to next.
+// Out-of-order dates (e.g. after a rebase) get the shortest delay.
+func (a *Autoplay) Delay(cur, next navigator.Commit) time.Duration {
+	if !a.TimeLapse {
+		return a.Interval
+	}
+	d := time.Duration(float64(next.Date.Sub(cur.Date)) / a.Scale)
+	if d < MinInterval {
+		d = MinInterval
+	}
+	if d > a.MaxGap {
+		d = a.MaxGap
+	}
+	return d
+}
+
+// Due reports whether a step should happen now.
+func (a *Autoplay) Due(now time.Time) bool {
+	return a.playing && !now.Before(a.due)
+}
+
+// Remaining returns the time left until the next step.
+func (a *Autoplay) Remaining(now time.Time) time.Duration {
+	if d := a.due.Sub(now); d > 0 {
+		return d
+	}
+	return 0
+}
+
+// Faster doubles the playback speed: it halves the interval, or doubles
+// the scale in time-lapse mode. The caller reschedules the pending step.
+func (a *Autoplay) Faster() {
+	if a.TimeLapse {
+		a.Scale *= 2
+	} else {
+		a.Interval /= 2
+	}
+	a.clamp()
+}
+
+// Slower halves the playback speed.
+func (a *Autoplay) Slower() {
+	if a.TimeLapse {
+		a.Scale /= 2
+	} else {
+		a.Interval *= 2
+	}
+	a.clamp()
+}
+
+func (a *Autoplay) clamp() {
+	a.Interval = min(max(a.Interval, MinInterval), MaxInterval)
+	a.Scale = min(max(a.Scale, MinScale), MaxScale)
+	a.MaxGap = max(a.MaxGap, MinInterval)
+}
+
+// RunHook runs a user-configured shell command after a step, in dir, with
+// REPLAY_COMMIT set to the commit hash. A non-zero exit is returned as an
+// error carrying the last line of the command's output. Cancelling ctx
+// kills the command and everything it started, and returns ctx's error.
+func RunHook(ctx context.Context, command, dir string, commit navigator.Commit) error {
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "REPLAY_COMMIT="+commit.Rev())
+	killGroup(cmd)
+	out, err := cmd.CombinedOutput()
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if err == nil {
+		return nil
+	}
+	lines := strings.Split(string(bytes.TrimSpace(out)), "\n")
+	if last := strings.TrimSpace(lines[len(lines)-1]); last != "" {
+		return fmt.Errorf("hook failed: %v: %s", err, last)
+	}
+	return fmt.Errorf("hook failed: %v", err)
+}
+
+// Hook is a run of the hook command in the background.
+type Hook struct {
+	done   chan error
+	cancel context.CancelFunc
+}
+
+// StartHook starts RunHook without waiting for it. Its result arrives on
+// Done unless Cancel is called first.
+func StartHook(command, dir string, commit navigator.Commit) *Hook {
+	ctx, cancel := context.WithCancel(context.Background())
+	h := &Hook{done: make(chan error, 1), cancel: cancel}
+	go func() {
+		if err := RunHook(ctx, command, dir, commit); ctx.Err() == nil {
+			h.done <- err
+		}
+		cancel()
+	}()
+	return h
+}
+
+// Done receives the hook's result once it has finished.
+func (h *Hook) Done() <-chan error { return h.done }
+
+// Cancel kills the hook if it is still running.
+func (h *Hook) Cancel() { h.cancel() }
@@ -0,0 +1,125 @@
+package app
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anuchito/replay/internal/navigator"
+)
+
+func TestAutoplay_Schedule(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	a := NewAutoplay(2 * time.Second)
+
+	if a.Due(now) {
+		t.Error("expected stopped autoplay never to be due")
+	}
+	a.Start(now, navigator.Commit{}, navigator.Commit{})
+	if a.Due(now.Add(time.Second)) {
+		t.Error("expected step not due before the interval")
+	}
+	if got := a.Remaining(now.Add(500 * time.Millisecond)); got != 1500*time.Millisecond {
+		t.Errorf("expected 1.5s remaining, got %v", got)
+	}
+	if !a.Due(now.Add(2 * time.Second)) {
+		t.Error("expected step due after the interval")
+	}
+
+	a.Stop()
+	if a.Playing() || a.Due(now.Add(time.Hour)) {
+		t.Error("expected no steps after Stop")
+	}
+}
+
+func TestAutoplay_Speed(t *testing.T) {
+	a := NewAutoplay(time.Second)
+
+	a.Faster()
+	if a.Interval != 500*time.Millisecond {
+		t.Errorf("expected 500ms, got %v", a.Interval)
+	}
+	for i := 0; i < 10; i++ {
+		a.Faster()
+	}
+	if a.Interval != MinInterval {
+		t.Errorf("expected interval clamped to %v, got %v", MinInterval, a.Interval)
+	}
+	for i := 0; i < 20; i++ {
+		a.Slower()
+	}
+	if a.Interval != MaxInterval {
+		t.Errorf("expected interval clamped to %v, got %v", MaxInterval, a.Interval)
+	}
+}
+
+func TestAutoplay_TimeLapse(t *testing.T) {
+	base := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) navigator.Commit { return navigator.Commit{Date: base.Add(d)} }
+
+	a := NewAutoplay(time.Second)
+	a.TimeLapse = true
+
+	tests := []struct {
+		gap  time.Duration
+		want time.Duration
+	}{
+		{2 * time.Hour, 5 * time.Second}, // 2h / 1440
+		{time.Minute, MinInterval},       // too short, raised to the minimum
+		{-time.Hour, MinInterval},        // out of order
+		{72 * time.Hour, DefaultMaxGap},  // long gap, capped
+	}
+	for _, tt := range tests {
+		if got := a.Delay(at(0), at(tt.gap)); got != tt.want {
+			t.Errorf("gap %v: expected delay %v, got %v", tt.gap, tt.want, got)
+		}
+	}
+
+	a.Faster()
+	if got := a.Delay(at(0), at(2*time.Hour)); got != 2500*time.Millisecond {
+		t.Errorf("expected faster time-lapse to halve the delay, got %v", got)
+	}
+
+	a.TimeLapse = false
+	if got := a.Delay(at(0), at(2*time.Hour)); got != time.Second {
+		t.Errorf("expected fixed interval outside time-lapse, got %v", got)
+	}
+}
+
+func TestRunHook(t *testing.T) {
+	dir := t.TempDir()
+	commit := navigator.Commit{Hash: "abc1234"}
+
+	if err := RunHook(context.Background(), `test "$REPLAY_COMMIT" = abc1234`, dir, commit); err != nil {
+		t.Errorf("expected hook to see REPLAY_COMMIT, got %v", err)
+	}
+
+	err := RunHook(context.Background(), "echo building; echo 'tests failed'; exit 3", dir, commit)
+	if err == nil {
+		t.Fatal("expected error for failing hook")
+	}
+	if !strings.Contains(err.Error(), "tests failed") {
+		t.Errorf("expected last output line in error, got %v", err)
+	}
+}
+
+func TestStartHook_Cancel(t *testing.T) {
+	start := time.Now()
+	h := StartHook("sleep 5; echo done", t.TempDir(), navigator.Commit{Hash: "abc1234"})
+	h.Cancel()
+	select {
+	case err := <-h.Done():
+		t.Errorf("expected no result from a cancelled hook, got %v", err)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := RunHook(ctx, "sleep 5", t.TempDir(), navigator.Commit{}); err != context.DeadlineExceeded {
+		t.Errorf("expected the deadline's error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("expected the sleep killed, took %v", elapsed)
+	}
+}
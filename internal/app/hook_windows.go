@@ -0,0 +1,9 @@
+package app
+
+import "os/exec"
+
+// killGroup makes cancelling cmd kill it. Windows has no process groups to
+// signal, so anything the hook started in the background is left running.
+func killGroup(cmd *exec.Cmd) {
+	cmd.Cancel = func() error { return cmd.Process.Kill() }
+}
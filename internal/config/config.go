@@ -0,0 +1,59 @@
+// Package config reads the user's settings from config.json in the
+// replay directory of the user's config directory, usually
+// ~/.config/replay/config.json.
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Config is the contents of the config file. Every setting is optional.
+type Config struct {
+	// Keymap names the preset keymap: "vim" (the default), "emacs" or
+	// "arrows".
+	Keymap string `json:"keymap,omitempty"`
+	// Keys binds actions to key sequences, replacing the preset's
+	// bindings for those actions, e.g. {"next": ["n", "ctrl+n"]}.
+	Keys map[string][]string `json:"keys,omitempty"`
+	// Theme names the color theme: "dark" (the default), "light",
+	// "high-contrast" or one defined in Themes.
+	Theme string `json:"theme,omitempty"`
+	// Themes defines the user's own themes. Each maps roles to styles,
+	// e.g. {"added": "#00af00", "match": "black on yellow"}; its "base"
+	// names the built-in theme the other roles come from.
+	Themes map[string]map[string]string `json:"themes,omitempty"`
+}
+
+// Path returns where the config file is looked for.
+func Path() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "replay", "config.json"), nil
+}
+
+// Load reads the config file at path. A missing file is an empty config;
+// unknown settings are errors, so that typos don't go unnoticed.
+func Load(path string) (Config, error) {
+	var c Config
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return c, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&c); err != nil {
+		return c, fmt.Errorf("%s: %w", path, err)
+	}
+	return c, nil
+}
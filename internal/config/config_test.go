@@ -0,0 +1,88 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	path := writeConfig(t, `{"keymap": "emacs", "keys": {"next": ["n", "ctrl+x n"], "quit": []}}`)
+	c, err := Load(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if c.Keymap != "emacs" {
+		t.Errorf("expected keymap emacs, got %q", c.Keymap)
+	}
+	if got := c.Keys["next"]; len(got) != 2 || got[1] != "ctrl+x n" {
+		t.Errorf("expected next bound to n and ctrl+x n, got %v", got)
+	}
+	if got, ok := c.Keys["quit"]; !ok || len(got) != 0 {
+		t.Errorf("expected quit explicitly unbound, got %v (set %v)", got, ok)
+	}
+}
+
+func TestLoad_MissingFileIsEmpty(t *testing.T) {
+	c, err := Load(filepath.Join(t.TempDir(), "config.json"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if c.Keymap != "" || c.Keys != nil {
+		t.Errorf("expected an empty config, got %+v", c)
+	}
+}
+
+func TestLoad_Errors(t *testing.T) {
+	for _, content := range []string{
+		`{"keymap": "emacs",}`,
+		`{"keymaps": "emacs"}`,
+		`{"keys": {"next": "n"}}`,
+	} {
+		path := writeConfig(t, content)
+		_, err := Load(path)
+		if err == nil {
+			t.Errorf("%s: expected an error", content)
+			continue
+		}
+		if !strings.Contains(err.Error(), path) {
+			t.Errorf("%s: expected the error to name the file, got %v", content, err)
+		}
+	}
+}
+
+func TestPath(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", "/tmp/xdg")
+	t.Setenv("HOME", "/tmp/home")
+	path, err := Path()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if filepath.Base(path) != "config.json" || filepath.Base(filepath.Dir(path)) != "replay" {
+		t.Errorf("expected .../replay/config.json, got %s", path)
+	}
+}
+
+func TestLoad_Themes(t *testing.T) {
+	path := writeConfig(t, `{"theme": "mine", "themes": {"mine": {"base": "light", "added": "#00af00"}}}`)
+	c, err := Load(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if c.Theme != "mine" {
+		t.Errorf("expected theme mine, got %q", c.Theme)
+	}
+	if got := c.Themes["mine"]; got["base"] != "light" || got["added"] != "#00af00" {
+		t.Errorf("expected mine based on light with added #00af00, got %v", got)
+	}
+}
@@ -0,0 +1,239 @@
+package export
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/anuchito/replay/internal/git"
+)
+
+// markerPath is the file recording which commit the export directory dir
+// currently holds, kept in the repository rather than in the tree exported.
+// Its presence also proves a non-empty dir is owned by replay, which is
+// what allows stale files to be deleted.
+func markerPath(gitDir, dir string) string {
+	return filepath.Join(gitDir, "replay", "exports", fmt.Sprintf("%x", sha256.Sum256([]byte(dir))))
+}
+
+var (
+	ErrNotEmpty   = errors.New("export directory is not empty and was not created by replay")
+	ErrInWorkTree = errors.New("export directory is inside the repository's work tree")
+)
+
+// Source is the subset of git operations the exporter needs.
+type Source interface {
+	GitDir() (string, error)
+	WorkTree() (string, error)
+	ListTree(commit string) ([]git.TreeEntry, error)
+	DiffTree(from, to string) ([]git.FileChange, error)
+	ReadBlobs(hashes []string, each func(i int, data []byte) error) error
+}
+
+// Exporter materializes commit trees into a directory outside the repository.
+// After the first full write, each Apply only touches files that changed.
+type Exporter struct {
+	src    Source
+	dir    string
+	marker string // see markerPath
+	last   string // commit currently materialized, "" if the directory is empty
+}
+
+// New prepares dir for exporting. The directory is created if missing; an
+// existing directory must be empty or a previous replay export. It must lie
+// outside the work tree, which checking out would otherwise overwrite.
+func New(src Source, dir string) (*Exporter, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	top, err := src.WorkTree()
+	if err != nil {
+		return nil, err
+	}
+	if within(resolve(abs), resolve(top)) {
+		return nil, ErrInWorkTree
+	}
+	if err := os.MkdirAll(abs, 0755); err != nil {
+		return nil, fmt.Errorf("create export dir: %w", err)
+	}
+
+	gitDir, err := src.GitDir()
+	if err != nil {
+		return nil, err
+	}
+	e := &Exporter{src: src, dir: abs, marker: markerPath(gitDir, abs)}
+	entries, err := os.ReadDir(abs)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return e, nil
+	}
+	marker, err := os.ReadFile(e.marker)
+	if err != nil {
+		return nil, ErrNotEmpty
+	}
+	e.last = strings.TrimSpace(string(marker))
+	return e, nil
+}
+
+// resolve returns path with symlinks resolved in as much of it as exists.
+func resolve(path string) string {
+	if real, err := filepath.EvalSymlinks(path); err == nil {
+		return real
+	}
+	parent := filepath.Dir(path)
+	if parent == path {
+		return path
+	}
+	return filepath.Join(resolve(parent), filepath.Base(path))
+}
+
+// within reports whether path is dir or lies below it.
+func within(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// Dir returns the absolute export directory.
+func (e *Exporter) Dir() string { return e.dir }
+
+// Apply updates the export directory to match the tree of commit.
+func (e *Exporter) Apply(commit string) error {
+	if commit == e.last {
+		return nil
+	}
+	if e.last == "" {
+		if err := e.writeAll(commit); err != nil {
+			return err
+		}
+		return e.setLast(commit)
+	}
+
+	changes, err := e.src.DiffTree(e.last, commit)
+	if err != nil {
+		// The previously exported commit may no longer exist (e.g. after gc);
+		// start over from a clean directory.
+		if err := e.reset(); err != nil {
+			return err
+		}
+		if err := e.writeAll(commit); err != nil {
+			return err
+		}
+		return e.setLast(commit)
+	}
+	var files []git.TreeEntry
+	for _, ch := range changes {
+		if ch.Status == 'D' {
+			if err := e.remove(ch.Path); err != nil {
+				return err
+			}
+			continue
+		}
+		files = append(files, git.TreeEntry{Mode: ch.Mode, Hash: ch.Hash, Path: ch.Path})
+	}
+	if err := e.writeFiles(files); err != nil {
+		return err
+	}
+	return e.setLast(commit)
+}
+
+func (e *Exporter) writeAll(commit string) error {
+	entries, err := e.src.ListTree(commit)
+	if err != nil {
+		return err
+	}
+	return e.writeFiles(entries)
+}
+
+// writeFiles places files, reading all their blobs in one batch.
+func (e *Exporter) writeFiles(files []git.TreeEntry) error {
+	var blobs []git.TreeEntry
+	var hashes []string
+	for _, f := range files {
+		if f.Mode == "160000" { // submodule — nothing to check out, keep an empty directory
+			if err := os.MkdirAll(filepath.Join(e.dir, filepath.FromSlash(f.Path)), 0755); err != nil {
+				return err
+			}
+			continue
+		}
+		blobs = append(blobs, f)
+		hashes = append(hashes, f.Hash)
+	}
+	return e.src.ReadBlobs(hashes, func(i int, data []byte) error {
+		return e.write(blobs[i].Path, blobs[i].Mode, data)
+	})
+}
+
+// write places one blob's contents at path, replacing whatever was there.
+// Regular files are written to a temp file and renamed so watchers never
+// see partial content.
+func (e *Exporter) write(path, mode string, data []byte) error {
+	dst := filepath.Join(e.dir, filepath.FromSlash(path))
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	if mode == "120000" { // symlink — blob content is the link target
+		os.RemoveAll(dst)
+		return os.Symlink(string(data), dst)
+	}
+
+	perm := os.FileMode(0644)
+	if mode == "100755" {
+		perm = 0755
+	}
+	tmp := dst + ".replay-tmp"
+	if err := os.WriteFile(tmp, data, perm); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp, perm); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if info, err := os.Lstat(dst); err == nil && info.IsDir() {
+		os.RemoveAll(dst)
+	}
+	return os.Rename(tmp, dst)
+}
+
+// remove deletes path and any parent directories left empty by it.
+func (e *Exporter) remove(path string) error {
+	dst := filepath.Join(e.dir, filepath.FromSlash(path))
+	if err := os.RemoveAll(dst); err != nil {
+		return err
+	}
+	for dir := filepath.Dir(dst); dir != e.dir && strings.HasPrefix(dir, e.dir); dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			break // not empty (or already gone)
+		}
+	}
+	return nil
+}
+
+// reset empties the export directory. Only called once the marker has
+// shown the directory belongs to replay.
+func (e *Exporter) reset() error {
+	entries, err := os.ReadDir(e.dir)
+	if err != nil {
+		return err
+	}
+	for _, ent := range entries {
+		if err := os.RemoveAll(filepath.Join(e.dir, ent.Name())); err != nil {
+			return err
+		}
+	}
+	e.last = ""
+	return nil
+}
+
+func (e *Exporter) setLast(commit string) error {
+	e.last = commit
+	if err := os.MkdirAll(filepath.Dir(e.marker), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(e.marker, []byte(commit+"\n"), 0644)
+}
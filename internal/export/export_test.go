@@ -0,0 +1,278 @@
+package export
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/anuchito/replay/internal/git"
+)
+
+// fakeSource serves trees from memory. Blob hashes are the file contents.
+type fakeSource struct {
+	gitDir     string
+	workTree   string // "" for one that holds no export directory
+	trees      map[string][]git.TreeEntry
+	diffCalls  int
+	listCalls  int
+	blobCalls  int
+	missingOld bool
+}
+
+func (f *fakeSource) GitDir() (string, error) { return f.gitDir, nil }
+
+func (f *fakeSource) WorkTree() (string, error) {
+	if f.workTree == "" {
+		return filepath.Join(f.gitDir, "work"), nil
+	}
+	return f.workTree, nil
+}
+
+func (f *fakeSource) ListTree(commit string) ([]git.TreeEntry, error) {
+	f.listCalls++
+	t, ok := f.trees[commit]
+	if !ok {
+		return nil, errors.New("no such commit")
+	}
+	return t, nil
+}
+
+func (f *fakeSource) DiffTree(from, to string) ([]git.FileChange, error) {
+	f.diffCalls++
+	if f.missingOld {
+		return nil, errors.New("bad object")
+	}
+	old := map[string]git.TreeEntry{}
+	for _, e := range f.trees[from] {
+		old[e.Path] = e
+	}
+	var changes []git.FileChange
+	seen := map[string]bool{}
+	for _, e := range f.trees[to] {
+		seen[e.Path] = true
+		prev, ok := old[e.Path]
+		switch {
+		case !ok:
+			changes = append(changes, git.FileChange{Status: 'A', Mode: e.Mode, Hash: e.Hash, Path: e.Path})
+		case prev.Hash != e.Hash || prev.Mode != e.Mode:
+			changes = append(changes, git.FileChange{Status: 'M', Mode: e.Mode, Hash: e.Hash, Path: e.Path})
+		}
+	}
+	for p := range old {
+		if !seen[p] {
+			changes = append(changes, git.FileChange{Status: 'D', Mode: "000000", Path: p})
+		}
+	}
+	return changes, nil
+}
+
+func (f *fakeSource) ReadBlobs(hashes []string, each func(i int, data []byte) error) error {
+	f.blobCalls++
+	for i, h := range hashes {
+		if err := each(i, []byte(h)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func file(path, content string) git.TreeEntry {
+	return git.TreeEntry{Mode: "100644", Hash: content, Path: path}
+}
+
+func readFile(t *testing.T, dir, path string) string {
+	t.Helper()
+	b, err := os.ReadFile(filepath.Join(dir, path))
+	if err != nil {
+		t.Fatalf("read %s: %v", path, err)
+	}
+	return string(b)
+}
+
+func TestApply_FullThenIncremental(t *testing.T) {
+	src := &fakeSource{gitDir: t.TempDir(), trees: map[string][]git.TreeEntry{
+		"c1": {file("a.txt", "one"), file("dir/b.txt", "bee")},
+		"c2": {file("a.txt", "two"), file("c.txt", "sea")},
+	}}
+	dir := filepath.Join(t.TempDir(), "out")
+
+	exp, err := New(src, dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := exp.Apply("c1"); err != nil {
+		t.Fatalf("apply c1: %v", err)
+	}
+	if got := readFile(t, dir, "dir/b.txt"); got != "bee" {
+		t.Errorf("expected dir/b.txt = bee, got %q", got)
+	}
+
+	if err := exp.Apply("c2"); err != nil {
+		t.Fatalf("apply c2: %v", err)
+	}
+	if src.listCalls != 1 || src.diffCalls != 1 || src.blobCalls != 2 {
+		t.Errorf("expected one full listing and one diff, each read in a batch, got list=%d diff=%d blobs=%d", src.listCalls, src.diffCalls, src.blobCalls)
+	}
+	if got := readFile(t, dir, "a.txt"); got != "two" {
+		t.Errorf("expected a.txt = two, got %q", got)
+	}
+	if got := readFile(t, dir, "c.txt"); got != "sea" {
+		t.Errorf("expected c.txt = sea, got %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "dir")); !os.IsNotExist(err) {
+		t.Error("expected emptied directory dir/ to be removed")
+	}
+}
+
+func TestApply_FileBecomesDirectoryAndBack(t *testing.T) {
+	src := &fakeSource{gitDir: t.TempDir(), trees: map[string][]git.TreeEntry{
+		"c1": {file("a", "file")},
+		"c2": {file("a/b", "nested")},
+		"c3": {file("a", "file again")},
+	}}
+	dir := t.TempDir()
+	exp, _ := New(src, dir)
+
+	if err := exp.Apply("c1"); err != nil {
+		t.Fatalf("apply c1: %v", err)
+	}
+	if err := exp.Apply("c2"); err != nil {
+		t.Fatalf("apply c2: %v", err)
+	}
+	if got := readFile(t, dir, "a/b"); got != "nested" {
+		t.Errorf("expected a/b = nested, got %q", got)
+	}
+	if err := exp.Apply("c3"); err != nil {
+		t.Fatalf("apply c3: %v", err)
+	}
+	if got := readFile(t, dir, "a"); got != "file again" {
+		t.Errorf("expected a = file again, got %q", got)
+	}
+	if src.diffCalls != 2 {
+		t.Errorf("expected both steps applied incrementally, got %d diffs", src.diffCalls)
+	}
+}
+
+func TestApply_SameCommitIsNoop(t *testing.T) {
+	src := &fakeSource{gitDir: t.TempDir(), trees: map[string][]git.TreeEntry{"c1": {file("a.txt", "one")}}}
+	exp, _ := New(src, t.TempDir())
+	exp.Apply("c1")
+	exp.Apply("c1")
+	if src.listCalls != 1 || src.diffCalls != 0 {
+		t.Errorf("expected a single listing, got list=%d diff=%d", src.listCalls, src.diffCalls)
+	}
+}
+
+func TestApply_ExecutableMode(t *testing.T) {
+	src := &fakeSource{gitDir: t.TempDir(), trees: map[string][]git.TreeEntry{
+		"c1": {{Mode: "100755", Hash: "#!/bin/sh", Path: "run.sh"}},
+	}}
+	dir := t.TempDir()
+	exp, _ := New(src, dir)
+	if err := exp.Apply("c1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	info, err := os.Stat(filepath.Join(dir, "run.sh"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm()&0100 == 0 {
+		t.Errorf("expected run.sh to be executable, got %v", info.Mode())
+	}
+}
+
+func TestNew_RefusesForeignDirectory(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "keep.txt"), []byte("mine"), 0644)
+
+	_, err := New(&fakeSource{gitDir: t.TempDir()}, dir)
+	if !errors.Is(err, ErrNotEmpty) {
+		t.Fatalf("expected ErrNotEmpty, got %v", err)
+	}
+}
+
+func TestNew_RefusesWorkTree(t *testing.T) {
+	top := t.TempDir()
+	src := &fakeSource{gitDir: filepath.Join(top, ".git"), workTree: top}
+	link := filepath.Join(t.TempDir(), "link")
+	os.Symlink(top, link)
+
+	for _, dir := range []string{top, filepath.Join(top, "out"), filepath.Join(link, "out", "deeper")} {
+		if _, err := New(src, dir); !errors.Is(err, ErrInWorkTree) {
+			t.Errorf("%s: expected ErrInWorkTree, got %v", dir, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(top, "out")); !os.IsNotExist(err) {
+		t.Error("expected nothing created in the work tree")
+	}
+	if _, err := New(src, top+"-out"); err != nil {
+		t.Errorf("expected a sibling of the work tree to be allowed, got %v", err)
+	}
+}
+
+func TestNew_ResumesPreviousExport(t *testing.T) {
+	src := &fakeSource{gitDir: t.TempDir(), trees: map[string][]git.TreeEntry{
+		"c1": {file("a.txt", "one")},
+		"c2": {file("a.txt", "two")},
+	}}
+	dir := t.TempDir()
+	exp, _ := New(src, dir)
+	exp.Apply("c1")
+
+	again, err := New(src, dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := again.Apply("c2"); err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	if src.listCalls != 1 || src.diffCalls != 1 {
+		t.Errorf("expected resumed export to diff from c1, got list=%d diff=%d", src.listCalls, src.diffCalls)
+	}
+	if got := readFile(t, dir, "a.txt"); got != "two" {
+		t.Errorf("expected a.txt = two, got %q", got)
+	}
+}
+
+func TestApply_FallsBackWhenPreviousCommitMissing(t *testing.T) {
+	src := &fakeSource{gitDir: t.TempDir(), trees: map[string][]git.TreeEntry{
+		"c1": {file("old.txt", "x")},
+		"c2": {file("new.txt", "y")},
+	}}
+	dir := t.TempDir()
+	exp, _ := New(src, dir)
+	exp.Apply("c1")
+
+	src.missingOld = true
+	if err := exp.Apply("c2"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "old.txt")); !os.IsNotExist(err) {
+		t.Error("expected stale old.txt to be removed after full rewrite")
+	}
+	if got := readFile(t, dir, "new.txt"); got != "y" {
+		t.Errorf("expected new.txt = y, got %q", got)
+	}
+}
+
+func TestApply_KeepsMarkerOutOfExport(t *testing.T) {
+	src := &fakeSource{gitDir: t.TempDir(), trees: map[string][]git.TreeEntry{"c1": {file("a.txt", "one")}}}
+	dir := t.TempDir()
+	exp, _ := New(src, dir)
+	if err := exp.Apply("c1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 || entries[0].Name() != "a.txt" {
+		t.Errorf("expected only the exported tree in the directory, got %v", entries)
+	}
+	if b, err := os.ReadFile(markerPath(src.gitDir, dir)); err != nil || string(b) != "c1\n" {
+		t.Errorf("expected the marker in the git dir to name c1, got %q, %v", b, err)
+	}
+	other := &fakeSource{gitDir: t.TempDir()}
+	if _, err := New(other, dir); !errors.Is(err, ErrNotEmpty) {
+		t.Errorf("expected another repository to refuse the directory, got %v", err)
+	}
+}
@@ -1,9 +1,15 @@
 package git
 
 import (
+	"bufio"
+	"bytes"
+	"errors"
 	"fmt"
+	"io"
 	"os/exec"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/anuchito/replay/internal/navigator"
 )
@@ -13,11 +19,23 @@ type GitClient interface {
 	IsClean() (bool, error)
 	ValidateCommit(hash string) error
 	IsAncestor(commit, of string) (bool, error)
+	CountRange(from, to string) (int, error)
 	CommitRange(from, to string) ([]navigator.Commit, error)
+	StreamRange(from, to string, page func([]navigator.Commit) error) error
 	Log(n int) ([]navigator.Commit, error)
+	LogPage(opts LogOptions) ([]navigator.Commit, error)
+	RefsContaining(commit string) ([]string, error)
+	Refs(reflog int) ([]navigator.Ref, error)
+	ResolveRef(ref string) (string, error)
+	GitDir() (string, error)
+	WorkTree() (string, error)
 	CurrentBranch() (string, error)
 	Checkout(ref string) error
 	ShowDiff(hash string) ([]string, error)
+	CommitFiles(revs []string) ([][]string, error)
+	ListTree(commit string) ([]TreeEntry, error)
+	DiffTree(from, to string) ([]FileChange, error)
+	ReadBlobs(hashes []string, each func(i int, data []byte) error) error
 }
 
 type Client struct {
@@ -38,6 +56,14 @@ func (c *Client) run(args ...string) (string, error) {
 	return strings.TrimRight(string(out), "\n"), err
 }
 
+// runRaw returns stdout untouched, for output that must stay byte-exact
+// (file contents, NUL-separated listings).
+func (c *Client) runRaw(args ...string) ([]byte, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = c.dir
+	return cmd.Output()
+}
+
 func (c *Client) IsRepo() (bool, error) {
 	_, err := c.run("rev-parse", "--git-dir")
 	if err != nil {
@@ -70,52 +96,356 @@ func (c *Client) IsAncestor(commit, of string) (bool, error) {
 	return true, nil
 }
 
-func parseCommitLines(out string) []navigator.Commit {
-	lines := strings.Split(out, "\n")
+// CountRange returns how many commits a replay from from to to covers:
+// those of from^..to, merged side branches included.
+func (c *Client) CountRange(from, to string) (int, error) {
+	spec := []string{to, "^" + from + "^"}
+	if _, err := c.run("rev-parse", "--verify", "--quiet", from+"^"); err != nil {
+		spec = []string{to} // from is a root commit
+	}
+	out, err := c.run(append([]string{"rev-list", "--count"}, spec...)...)
+	if err != nil {
+		return 0, fmt.Errorf("git rev-list: %s", out)
+	}
+	return strconv.Atoi(out)
+}
+
+// logFormat emits one record per commit: a record separator (0x1e) followed
+// by fields separated by unit separators (0x1f). Unlike spaces or newlines,
+// these never appear in names or subjects.
+const logFormat = "--format=%x1e%H%x1f%P%x1f%an%x1f%ae%x1f%at%x1f%cn%x1f%ce%x1f%ct%x1f%s%x1f%b"
+
+func parseCommits(out string) []navigator.Commit {
 	var commits []navigator.Commit
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if line == "" {
-			continue
-		}
-		parts := strings.SplitN(line, " ", 2)
-		hash := parts[0][:7]
-		msg := ""
-		if len(parts) > 1 {
-			msg = parts[1]
+	for _, rec := range strings.Split(out, "\x1e") {
+		if c, ok := parseRecord(rec); ok {
+			commits = append(commits, c)
 		}
-		commits = append(commits, navigator.Commit{Hash: hash, Message: msg})
 	}
 	return commits
 }
 
+// parseRecord parses one logFormat record, without its leading separator.
+func parseRecord(rec string) (navigator.Commit, bool) {
+	rec = strings.TrimSpace(rec)
+	if rec == "" {
+		return navigator.Commit{}, false
+	}
+	f := strings.Split(rec, "\x1f")
+	for len(f) < 10 {
+		f = append(f, "")
+	}
+	c := navigator.Commit{
+		Hash:           navigator.ShortHash(f[0]),
+		FullHash:       f[0],
+		Author:         f[2],
+		Email:          f[3],
+		Date:           parseUnix(f[4]),
+		Committer:      f[5],
+		CommitterEmail: f[6],
+		CommitDate:     parseUnix(f[7]),
+		Message:        f[8],
+		Body:           strings.TrimSpace(f[9]),
+	}
+	for _, p := range strings.Fields(f[1]) {
+		c.Parents = append(c.Parents, p)
+	}
+	return c, true
+}
+
+// parseUnix parses a Unix timestamp, returning the zero time if it is malformed.
+func parseUnix(s string) time.Time {
+	sec, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.Unix(sec, 0)
+}
+
 func (c *Client) CommitRange(from, to string) ([]navigator.Commit, error) {
-	out, err := c.run("log", "--reverse", "--format=%H %s", from+"^.."+to)
+	var commits []navigator.Commit
+	err := c.StreamRange(from, to, func(page []navigator.Commit) error {
+		commits = append(commits, page...)
+		return nil
+	})
 	if err != nil {
-		// Try without ^ (if from is the root commit)
-		out, err = c.run("log", "--reverse", "--format=%H %s", from+".."+to)
+		return nil, err
+	}
+	return commits, nil
+}
+
+// streamFlush is how long StreamRange holds commits back to fill a page.
+const streamFlush = 100 * time.Millisecond
+
+// StreamRange reads the same commits as CommitRange, oldest first, but hands
+// them to page as git produces them: the first commit on its own, then
+// batches collected over up to streamFlush. This lets a caller show the
+// start of a very large range long before git has read all of it.
+// The hashes come from rev-list, piped straight into log with each
+// command's stderr kept apart from its output; files are left for
+// CommitFiles.
+// An error returned by page stops git and is returned.
+func (c *Client) StreamRange(from, to string, page func([]navigator.Commit) error) error {
+	var root string
+	spec := from + "^.." + to
+	if _, err := c.run("rev-parse", "--verify", "--quiet", from+"^"); err != nil {
+		// from is a root commit: there is no parent to exclude, so list it
+		// on its own and the rest after it.
+		out, err := c.run("rev-parse", "--verify", from+"^{commit}")
 		if err != nil {
-			return nil, fmt.Errorf("git log: %w", err)
+			return fmt.Errorf("git rev-parse: %s", out)
 		}
-		// Prepend the from commit itself
-		fromOut, err := c.run("log", "--format=%H %s", "-1", from)
-		if err != nil {
-			return nil, fmt.Errorf("git log: %w", err)
+		root, spec = out+"\n", from+".."+to
+	}
+
+	revList := exec.Command("git", "rev-list", "--reverse", spec)
+	revList.Dir = c.dir
+	var revErr bytes.Buffer
+	revList.Stderr = &revErr
+	revOut, err := revList.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := revList.Start(); err != nil {
+		return fmt.Errorf("git rev-list: %w", err)
+	}
+	revs := bufio.NewReader(revOut)
+	if _, err := revs.Peek(1); err != nil && root == "" {
+		// Nothing to log; log itself would fall back to HEAD on no input.
+		return waitGit("rev-list", revList, &revErr)
+	}
+
+	cmd := exec.Command("git", "log", "--no-walk=unsorted", "--stdin", logFormat)
+	cmd.Dir = c.dir
+	cmd.Stdin = io.MultiReader(strings.NewReader(root), revs)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		revList.Process.Kill()
+		revList.Wait()
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		revList.Process.Kill()
+		revList.Wait()
+		return fmt.Errorf("git log: %w", err)
+	}
+	stop := func() {
+		cmd.Process.Kill()
+		revList.Process.Kill()
+		cmd.Wait()
+		revList.Wait()
+	}
+
+	var batch []navigator.Commit
+	sent := time.Time{} // zero: the first commit is sent at once
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		err := page(batch)
+		batch, sent = nil, time.Now()
+		return err
+	}
+
+	sc := bufio.NewScanner(stdout)
+	sc.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	sc.Split(splitRecords)
+	for sc.Scan() {
+		commit, ok := parseRecord(sc.Text())
+		if !ok {
+			continue
+		}
+		batch = append(batch, commit)
+		if time.Since(sent) >= streamFlush {
+			if err := flush(); err != nil {
+				stop()
+				return err
+			}
 		}
-		out = fromOut + "\n" + out
 	}
-	return parseCommitLines(out), nil
+	if err := sc.Err(); err != nil {
+		stop()
+		return fmt.Errorf("git log: %w", err)
+	}
+	// log reads all of rev-list's output before it exits, so rev-list is
+	// done by now; its error explains a short log better than log's own.
+	logErr := waitGit("log", cmd, &stderr)
+	if err := waitGit("rev-list", revList, &revErr); err != nil {
+		return err
+	}
+	if logErr != nil {
+		return logErr
+	}
+	return flush()
 }
 
+// waitGit waits for a git command started with its stderr in stderr, and
+// reports a failure with what git said.
+func waitGit(name string, cmd *exec.Cmd, stderr *bytes.Buffer) error {
+	err := cmd.Wait()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return fmt.Errorf("git %s: %s", name, strings.TrimSpace(stderr.String()))
+	}
+	if err != nil {
+		return fmt.Errorf("git %s: %w", name, err)
+	}
+	return nil
+}
+
+// splitRecords is a bufio.SplitFunc yielding the text between 0x1e record
+// separators.
+func splitRecords(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	start := 0
+	if len(data) > 0 && data[0] == 0x1e {
+		start = 1
+	}
+	if i := bytes.IndexByte(data[start:], 0x1e); i >= 0 {
+		return start + i, data[start : start+i], nil
+	}
+	if atEOF && len(data) > start {
+		return len(data), data[start:], nil
+	}
+	if atEOF {
+		return len(data), nil, nil
+	}
+	return 0, nil, nil
+}
+
+// Log lists the n newest commits of HEAD's history.
 func (c *Client) Log(n int) ([]navigator.Commit, error) {
-	out, err := c.run("log", "--format=%H %s", fmt.Sprintf("-%d", n))
+	return c.LogPage(LogOptions{Max: n})
+}
+
+// LogOptions selects the commits LogPage lists, newest first.
+type LogOptions struct {
+	Revs []string // whose history to list; HEAD if empty
+	All  bool     // list the history of every ref instead of Revs
+	Skip int      // commits to leave out at the start
+	Max  int      // most commits to list, 0 for no limit
+}
+
+// LogPage lists one page of history, so that long histories can be read
+// a page at a time.
+func (c *Client) LogPage(opts LogOptions) ([]navigator.Commit, error) {
+	args := []string{"log", logFormat}
+	if opts.Skip > 0 {
+		args = append(args, fmt.Sprintf("--skip=%d", opts.Skip))
+	}
+	if opts.Max > 0 {
+		args = append(args, fmt.Sprintf("-%d", opts.Max))
+	}
+	if opts.All {
+		args = append(args, "--all")
+	} else {
+		args = append(args, opts.Revs...)
+	}
+	out, err := c.run(append(args, "--")...)
 	if err != nil {
-		return nil, fmt.Errorf("git log: %w", err)
+		return nil, fmt.Errorf("git log: %s", out)
 	}
 	if out == "" {
 		return nil, nil
 	}
-	return parseCommitLines(out), nil
+	return parseCommits(out), nil
+}
+
+// RefsContaining returns the branches, remote-tracking branches and tags
+// whose history includes commit, the most recently committed to first.
+func (c *Client) RefsContaining(commit string) ([]string, error) {
+	out, err := c.run("for-each-ref", "--contains", commit, "--sort=-committerdate",
+		"--format=%(refname:short)", "refs/heads", "refs/remotes", "refs/tags")
+	if err != nil {
+		return nil, fmt.Errorf("git for-each-ref: %s", out)
+	}
+	if out == "" {
+		return nil, nil
+	}
+	return strings.Split(out, "\n"), nil
+}
+
+// Refs returns the local branches, the remote-tracking branches and the
+// tags, each the most recent first, followed by the latest reflog entries
+// of HEAD, at most reflog of them.
+func (c *Client) Refs(reflog int) ([]navigator.Ref, error) {
+	// A tag's starred fields are those of the commit it points to; they
+	// are empty for lightweight tags, which point to the commit directly.
+	out, err := c.run("for-each-ref", "--sort=-creatordate",
+		"--format=%(refname)%1f%(refname:short)%1f%(objectname:short)%1f%(*objectname:short)%1f%(creatordate:unix)%1f%(subject)%1f%(*subject)",
+		"refs/heads", "refs/remotes", "refs/tags")
+	if err != nil {
+		return nil, fmt.Errorf("git for-each-ref: %s", out)
+	}
+	var branches, remotes, tags []navigator.Ref
+	for _, line := range strings.Split(out, "\n") {
+		f := strings.Split(line, "\x1f")
+		if len(f) < 7 || strings.HasSuffix(f[0], "/HEAD") {
+			continue
+		}
+		r := navigator.Ref{Name: f[1], Hash: f[2], Date: parseUnix(f[4]), Subject: f[5]}
+		if f[3] != "" {
+			r.Hash, r.Subject = f[3], f[6]
+		}
+		switch {
+		case strings.HasPrefix(f[0], "refs/heads/"):
+			r.Kind = navigator.RefBranch
+			branches = append(branches, r)
+		case strings.HasPrefix(f[0], "refs/remotes/"):
+			r.Kind = navigator.RefRemote
+			remotes = append(remotes, r)
+		default:
+			r.Kind = navigator.RefTag
+			tags = append(tags, r)
+		}
+	}
+	refs := append(append(branches, remotes...), tags...)
+	if reflog <= 0 {
+		return refs, nil
+	}
+
+	// A repository without a reflog, such as a fresh bare clone, has
+	// nothing more to add.
+	out, err = c.run("log", "--walk-reflogs", fmt.Sprintf("-%d", reflog), "--format=%gd%x1f%h%x1f%ct%x1f%gs", "HEAD", "--")
+	if err != nil {
+		return refs, nil
+	}
+	for _, line := range strings.Split(out, "\n") {
+		f := strings.Split(line, "\x1f")
+		if len(f) < 4 {
+			continue
+		}
+		refs = append(refs, navigator.Ref{Name: f[0], Kind: navigator.RefReflog, Hash: f[1], Date: parseUnix(f[2]), Subject: f[3]})
+	}
+	return refs, nil
+}
+
+// GitDir returns the absolute path of the repository's .git directory.
+func (c *Client) GitDir() (string, error) {
+	out, err := c.run("rev-parse", "--absolute-git-dir")
+	if err != nil {
+		return "", fmt.Errorf("git rev-parse: %w", err)
+	}
+	return out, nil
+}
+
+// WorkTree returns the absolute path of the top of the work tree.
+func (c *Client) WorkTree() (string, error) {
+	out, err := c.run("rev-parse", "--show-toplevel")
+	if err != nil {
+		return "", fmt.Errorf("git rev-parse: %s", out)
+	}
+	return out, nil
+}
+
+// ResolveRef returns the full commit hash a ref (branch, tag, hash, HEAD~3…) points to.
+func (c *Client) ResolveRef(ref string) (string, error) {
+	out, err := c.run("rev-parse", "--verify", "--quiet", ref+"^{commit}")
+	if err != nil {
+		return "", fmt.Errorf("unknown revision: %s", ref)
+	}
+	return out, nil
 }
 
 func (c *Client) CurrentBranch() (string, error) {
@@ -152,3 +482,149 @@ func (c *Client) ShowDiff(hash string) ([]string, error) {
 	}
 	return all, nil
 }
+
+// CommitFiles returns the paths each of the commits named by revs
+// changed, in the order given. A merge's files are those changed against
+// its first parent. No list is nil.
+func (c *Client) CommitFiles(revs []string) ([][]string, error) {
+	cmd := exec.Command("git", "log", "--no-walk=unsorted", "--stdin", "-z", "--format=%x1e%H", "--name-only", "--diff-merges=first-parent")
+	cmd.Dir = c.dir
+	cmd.Stdin = strings.NewReader(strings.Join(revs, "\n") + "\n")
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git log --name-only: %w", err)
+	}
+	// Each record is "<hash>\x00\n<path>\x00<path>\x00...".
+	var files [][]string
+	for _, rec := range strings.Split(string(out), "\x1e")[1:] {
+		paths := []string{}
+		for _, p := range strings.Split(rec, "\x00")[1:] {
+			if p = strings.TrimPrefix(p, "\n"); p != "" {
+				paths = append(paths, p)
+			}
+		}
+		files = append(files, paths)
+	}
+	if len(files) != len(revs) {
+		return nil, fmt.Errorf("git log --name-only: %d commits listed for %d", len(files), len(revs))
+	}
+	return files, nil
+}
+
+// TreeEntry is a single file in a commit's tree.
+type TreeEntry struct {
+	Mode string // e.g. "100644", "100755", "120000"
+	Hash string // blob hash
+	Path string
+}
+
+// FileChange is one entry of a tree-to-tree diff.
+type FileChange struct {
+	Status byte   // 'A', 'M', 'D' or 'T'
+	Mode   string // mode on the new side ("000000" for deletions)
+	Hash   string // blob hash on the new side
+	Path   string
+}
+
+// ListTree returns every file in the tree of the given commit.
+func (c *Client) ListTree(commit string) ([]TreeEntry, error) {
+	out, err := c.runRaw("ls-tree", "-r", "-z", "--full-tree", commit)
+	if err != nil {
+		return nil, fmt.Errorf("git ls-tree %s: %w", commit, err)
+	}
+	var entries []TreeEntry
+	for _, rec := range strings.Split(string(out), "\x00") {
+		if rec == "" {
+			continue
+		}
+		// "<mode> <type> <hash>\t<path>"
+		meta, path, ok := strings.Cut(rec, "\t")
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(meta)
+		if len(fields) != 3 {
+			continue
+		}
+		entries = append(entries, TreeEntry{Mode: fields[0], Hash: fields[2], Path: path})
+	}
+	return entries, nil
+}
+
+// DiffTree returns the files that differ between two commits. Renames are
+// reported as a deletion plus an addition.
+func (c *Client) DiffTree(from, to string) ([]FileChange, error) {
+	out, err := c.runRaw("diff-tree", "-r", "-z", "--no-renames", from, to)
+	if err != nil {
+		return nil, fmt.Errorf("git diff-tree %s %s: %w", from, to, err)
+	}
+	// Records come in pairs: ":<old mode> <new mode> <old hash> <new hash> <status>" then "<path>".
+	parts := strings.Split(string(out), "\x00")
+	var changes []FileChange
+	for i := 0; i+1 < len(parts); i += 2 {
+		fields := strings.Fields(strings.TrimPrefix(parts[i], ":"))
+		if len(fields) != 5 || fields[4] == "" {
+			continue
+		}
+		changes = append(changes, FileChange{
+			Status: fields[4][0],
+			Mode:   fields[1],
+			Hash:   fields[3],
+			Path:   parts[i+1],
+		})
+	}
+	return changes, nil
+}
+
+// ReadBlobs reads the raw contents of the blobs with the hashes given
+// through a single git process, handing them to each in order. An error
+// returned by each stops git and is returned.
+func (c *Client) ReadBlobs(hashes []string, each func(i int, data []byte) error) error {
+	if len(hashes) == 0 {
+		return nil
+	}
+	cmd := exec.Command("git", "cat-file", "--batch")
+	cmd.Dir = c.dir
+	cmd.Stdin = strings.NewReader(strings.Join(hashes, "\n") + "\n")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("git cat-file: %w", err)
+	}
+	stop := func(err error) error {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return err
+	}
+
+	// Each blob is "<hash> blob <size>\n<contents>\n"; a bad one is
+	// "<hash> missing\n".
+	r := bufio.NewReader(stdout)
+	for i, hash := range hashes {
+		header, err := r.ReadString('\n')
+		if err != nil {
+			return stop(fmt.Errorf("git cat-file %s: %w", hash, err))
+		}
+		f := strings.Fields(header)
+		if len(f) != 3 || f[1] != "blob" {
+			return stop(fmt.Errorf("git cat-file %s: %s", hash, strings.TrimSpace(header)))
+		}
+		size, err := strconv.Atoi(f[2])
+		if err != nil {
+			return stop(fmt.Errorf("git cat-file %s: %s", hash, strings.TrimSpace(header)))
+		}
+		data := make([]byte, size+1)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return stop(fmt.Errorf("git cat-file %s: %w", hash, err))
+		}
+		if err := each(i, data[:size]); err != nil {
+			return stop(err)
+		}
+	}
+	if err := cmd.Wait(); err != nil {
+		return fmt.Errorf("git cat-file: %w", err)
+	}
+	return nil
+}
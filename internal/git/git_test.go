@@ -1,9 +1,12 @@
 package git
 
 import (
+	"errors"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
+	"strings"
 	"testing"
 
 	"github.com/anuchito/replay/internal/navigator"
@@ -51,6 +54,33 @@ func setupTestRepo(t *testing.T, numCommits int) (string, []string) {
 	return dir, hashes
 }
 
+// setupMergeRepo adds a merge to a repo from setupTestRepo: side.txt is
+// committed on a branch from hashes[1] and merged into main. It returns
+// the side commit and the merge.
+func setupMergeRepo(t *testing.T, dir string, hashes []string) (side, merge string) {
+	t.Helper()
+	run := func(args ...string) string {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@test.com"}, args...)...)
+		cmd.Dir = dir
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v failed: %s\n%s", args, err, out)
+		}
+		return trimNewline(string(out))
+	}
+	run("checkout", "-q", "-b", "side", hashes[1])
+	if err := os.WriteFile(filepath.Join(dir, "side.txt"), []byte("side"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	run("add", ".")
+	run("commit", "-q", "-m", "side")
+	side = run("rev-parse", "HEAD")
+	run("checkout", "-q", "main")
+	run("merge", "-q", "--no-ff", "-m", "merge side", "side")
+	return side, run("rev-parse", "HEAD")
+}
+
 func trimNewline(s string) string {
 	if len(s) > 0 && s[len(s)-1] == '\n' {
 		return s[:len(s)-1]
@@ -178,6 +208,74 @@ func TestCommitRange(t *testing.T) {
 	}
 }
 
+func TestStreamRange(t *testing.T) {
+	dir, hashes := setupTestRepo(t, 4)
+	client := NewClient(dir)
+
+	var pages [][]navigator.Commit
+	err := client.StreamRange(hashes[1], hashes[3], func(page []navigator.Commit) error {
+		pages = append(pages, page)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(pages) == 0 || len(pages[0]) != 1 {
+		t.Fatalf("expected the first commit in a page of its own, got %v", pages)
+	}
+	var got []string
+	for _, page := range pages {
+		for _, c := range page {
+			got = append(got, c.Hash)
+		}
+	}
+	want := []string{hashes[1][:7], hashes[2][:7], hashes[3][:7]}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func TestStreamRange_FromRoot(t *testing.T) {
+	dir, hashes := setupTestRepo(t, 3)
+	client := NewClient(dir)
+
+	commits, err := client.CommitRange(hashes[0], hashes[2])
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(commits) != 3 || commits[0].Hash != hashes[0][:7] {
+		t.Errorf("expected 3 commits starting at the root, got %v", commits)
+	}
+}
+
+func TestStreamRange_StopsOnPageError(t *testing.T) {
+	dir, hashes := setupTestRepo(t, 4)
+	client := NewClient(dir)
+
+	stop := errors.New("stop")
+	calls := 0
+	err := client.StreamRange(hashes[1], hashes[3], func([]navigator.Commit) error {
+		calls++
+		return stop
+	})
+	if err != stop {
+		t.Errorf("expected the page error back, got %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("expected streaming to stop after the failing page, got %d calls", calls)
+	}
+}
+
+func TestStreamRange_BadRevision(t *testing.T) {
+	dir, hashes := setupTestRepo(t, 2)
+	client := NewClient(dir)
+
+	err := client.StreamRange(hashes[1], "no-such-branch", func([]navigator.Commit) error { return nil })
+	if err == nil {
+		t.Error("expected error for unknown end revision")
+	}
+}
+
 func TestCurrentBranch(t *testing.T) {
 	dir, _ := setupTestRepo(t, 1)
 	client := NewClient(dir)
@@ -276,3 +374,303 @@ func TestLog_MoreThanAvailable(t *testing.T) {
 		t.Fatalf("expected 2 commits (all available), got %d", len(commits))
 	}
 }
+
+func TestLogPage(t *testing.T) {
+	dir, hashes := setupTestRepo(t, 5)
+	client := NewClient(dir)
+
+	commits, err := client.LogPage(LogOptions{Skip: 2, Max: 2})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(commits) != 2 || commits[0].Message != "commit 3" || commits[1].Message != "commit 2" {
+		t.Fatalf("expected commits 3 and 2, got %+v", commits)
+	}
+
+	// Another branch's history, and every ref's.
+	run := exec.Command("git", "branch", "old", hashes[1])
+	run.Dir = dir
+	if out, err := run.CombinedOutput(); err != nil {
+		t.Fatalf("git branch: %s", out)
+	}
+	run = exec.Command("git", "reset", "-q", "--hard", hashes[0])
+	run.Dir = dir
+	if out, err := run.CombinedOutput(); err != nil {
+		t.Fatalf("git reset: %s", out)
+	}
+	if commits, _ := client.LogPage(LogOptions{Revs: []string{"old"}}); len(commits) != 2 {
+		t.Errorf("expected the 2 commits of old, got %d", len(commits))
+	}
+	if commits, _ := client.LogPage(LogOptions{All: true}); len(commits) != 2 {
+		t.Errorf("expected 2 commits on all refs after the reset, got %d", len(commits))
+	}
+	if commits, _ := client.LogPage(LogOptions{Skip: 5}); len(commits) != 0 {
+		t.Errorf("expected nothing past the end, got %d", len(commits))
+	}
+	if _, err := client.LogPage(LogOptions{Revs: []string{"nope"}}); err == nil {
+		t.Error("expected an error for an unknown branch")
+	}
+
+	refs, err := client.RefsContaining(hashes[1])
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Join(refs, " ") != "old" {
+		t.Errorf("expected only old to contain the second commit, got %v", refs)
+	}
+}
+
+func TestRefs(t *testing.T) {
+	dir, hashes := setupTestRepo(t, 3)
+	client := NewClient(dir)
+	for _, args := range [][]string{
+		{"branch", "feature", hashes[1]},
+		{"tag", "v1", hashes[0]},
+		{"-c", "user.name=test", "-c", "user.email=test@test.com", "tag", "-a", "v2", "-m", "release two", hashes[2]},
+		{"update-ref", "refs/remotes/origin/main", hashes[2]},
+		{"symbolic-ref", "refs/remotes/origin/HEAD", "refs/remotes/origin/main"},
+		{"checkout", "-q", "feature"},
+	} {
+		run := exec.Command("git", args...)
+		run.Dir = dir
+		if out, err := run.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %s", args, out)
+		}
+	}
+
+	refs, err := client.Refs(2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var got []string
+	for _, r := range refs {
+		got = append(got, r.Kind+" "+r.Name+" "+r.Subject)
+	}
+	// Branches and tags are ordered by date, which is the same second for
+	// all the commits here; only the groups' order is certain.
+	sort.Strings(got[:2])
+	sort.Strings(got[3:5])
+	want := []string{
+		"branch feature commit 2", "branch main commit 3",
+		"remote origin/main commit 3",
+		"tag v1 commit 1", "tag v2 commit 3",
+		"reflog HEAD@{0} checkout: moving from main to feature", "reflog HEAD@{1} commit: commit 3",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("expected\n%s\ngot\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+	if refs[len(refs)-1].Rev() != navigator.ShortHash(hashes[2]) || refs[0].Rev() != refs[0].Name {
+		t.Errorf("expected a reflog entry named by its hash and a branch by its name, got %q and %q", refs[len(refs)-1].Rev(), refs[0].Rev())
+	}
+}
+
+func TestListTree(t *testing.T) {
+	dir, hashes := setupTestRepo(t, 1)
+	client := NewClient(dir)
+
+	entries, err := client.ListTree(hashes[0])
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 entry, got %d", len(entries))
+	}
+	if entries[0].Path != "file.txt" || entries[0].Mode != "100644" {
+		t.Errorf("unexpected entry %+v", entries[0])
+	}
+
+	var blobs []string
+	err = client.ReadBlobs([]string{entries[0].Hash, entries[0].Hash}, func(i int, data []byte) error {
+		blobs = append(blobs, string(data))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(blobs) != 2 || blobs[0] != "commit 1" || blobs[1] != "commit 1" {
+		t.Errorf("expected blob content 'commit 1' twice, got %q", blobs)
+	}
+	if err := client.ReadBlobs([]string{hashes[0]}, func(int, []byte) error { return nil }); err == nil {
+		t.Error("expected an error for a commit read as a blob")
+	}
+}
+
+func TestDiffTree(t *testing.T) {
+	dir, hashes := setupTestRepo(t, 2)
+	client := NewClient(dir)
+
+	changes, err := client.DiffTree(hashes[0], hashes[1])
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(changes) != 1 {
+		t.Fatalf("expected 1 change, got %d", len(changes))
+	}
+	if changes[0].Status != 'M' || changes[0].Path != "file.txt" {
+		t.Errorf("unexpected change %+v", changes[0])
+	}
+}
+
+func TestCommitRange_Metadata(t *testing.T) {
+	dir, hashes := setupTestRepo(t, 2)
+	client := NewClient(dir)
+
+	commits, err := client.CommitRange(hashes[0], hashes[1])
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	c := commits[1]
+	if c.Author != "test" || c.Email != "test@test.com" {
+		t.Errorf("expected author test <test@test.com>, got %s <%s>", c.Author, c.Email)
+	}
+	if c.Date.IsZero() {
+		t.Error("expected author date to be set")
+	}
+	if c.Committer != "test" || c.CommitterEmail != "test@test.com" || c.CommitDate.IsZero() {
+		t.Errorf("expected committer test <test@test.com> with a date, got %s <%s> %v", c.Committer, c.CommitterEmail, c.CommitDate)
+	}
+	if c.Message != "commit 2" {
+		t.Errorf("expected message 'commit 2', got %q", c.Message)
+	}
+}
+
+func TestResolveRef(t *testing.T) {
+	dir, hashes := setupTestRepo(t, 3)
+	client := NewClient(dir)
+
+	got, err := client.ResolveRef("HEAD~1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != hashes[1] {
+		t.Errorf("expected %s, got %s", hashes[1], got)
+	}
+
+	if _, err := client.ResolveRef("no-such-branch"); err == nil {
+		t.Error("expected error for unknown ref")
+	}
+}
+
+func TestCommitRange_Body(t *testing.T) {
+	dir, hashes := setupTestRepo(t, 1)
+	client := NewClient(dir)
+
+	cmd := exec.Command("git", "commit", "--allow-empty", "-m", "subject line", "-m", "first paragraph\n\nsecond paragraph")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@test.com",
+		"GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@test.com")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git commit: %v\n%s", err, out)
+	}
+
+	commits, err := client.CommitRange(hashes[0], "HEAD")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(commits) != 2 {
+		t.Fatalf("expected 2 commits, got %d", len(commits))
+	}
+	if commits[1].Message != "subject line" {
+		t.Errorf("expected subject, got %q", commits[1].Message)
+	}
+	if commits[1].Body != "first paragraph\n\nsecond paragraph" {
+		t.Errorf("unexpected body %q", commits[1].Body)
+	}
+}
+
+func TestCommitRange_Parents(t *testing.T) {
+	dir, hashes := setupTestRepo(t, 3)
+	client := NewClient(dir)
+
+	commits, err := client.CommitRange(hashes[0], hashes[2])
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(commits[0].Parents) != 0 {
+		t.Errorf("expected root commit to have no parents, got %v", commits[0].Parents)
+	}
+	if len(commits[2].Parents) != 1 || commits[2].Parents[0] != hashes[1] {
+		t.Errorf("expected parent %s, got %v", hashes[1], commits[2].Parents)
+	}
+	if commits[2].FullHash != hashes[2] || commits[2].Hash != hashes[2][:7] {
+		t.Errorf("expected full hash %s abbreviated to %s, got %q and %q", hashes[2], hashes[2][:7], commits[2].FullHash, commits[2].Hash)
+	}
+}
+
+func TestCommitRange_NoFiles(t *testing.T) {
+	dir, hashes := setupTestRepo(t, 2)
+	client := NewClient(dir)
+
+	commits, err := client.CommitRange(hashes[0], hashes[1])
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for i, c := range commits {
+		if c.Files != nil {
+			t.Errorf("commit %d: expected files left for CommitFiles, got %v", i, c.Files)
+		}
+	}
+}
+
+func TestCommitFiles(t *testing.T) {
+	dir, hashes := setupTestRepo(t, 3)
+	client := NewClient(dir)
+	_, merge := setupMergeRepo(t, dir, hashes)
+
+	files, err := client.CommitFiles([]string{merge, hashes[0]})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("expected files for 2 commits, got %v", files)
+	}
+	if len(files[0]) != 1 || files[0][0] != "side.txt" {
+		t.Errorf("expected the merge's files against its first parent, got %v", files[0])
+	}
+	if len(files[1]) != 1 || files[1][0] != "file.txt" {
+		t.Errorf("expected the root commit's files, got %v", files[1])
+	}
+}
+
+func TestCountRange(t *testing.T) {
+	dir, hashes := setupTestRepo(t, 3)
+	client := NewClient(dir)
+	side, merge := setupMergeRepo(t, dir, hashes)
+
+	// hashes[1], hashes[2], side and the merge; side isn't on the first
+	// parent line between the ends.
+	if n, err := client.CountRange(hashes[1], merge); err != nil || n != 4 {
+		t.Errorf("expected 4 commits with the merged side, got %d, %v", n, err)
+	}
+	if n, err := client.CountRange(hashes[0], side); err != nil || n != 3 {
+		t.Errorf("expected 3 commits from the root, got %d, %v", n, err)
+	}
+}
+
+func TestGitDir(t *testing.T) {
+	dir, _ := setupTestRepo(t, 1)
+	client := NewClient(dir)
+
+	got, err := client.GitDir()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if filepath.Base(got) != ".git" {
+		t.Errorf("expected path ending in .git, got %s", got)
+	}
+}
+
+func TestWorkTree(t *testing.T) {
+	dir, _ := setupTestRepo(t, 1)
+	os.Mkdir(filepath.Join(dir, "sub"), 0755)
+	client := NewClient(filepath.Join(dir, "sub"))
+
+	got, err := client.WorkTree()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want, _ := filepath.EvalSymlinks(dir)
+	if got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+}
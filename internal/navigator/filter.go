@@ -0,0 +1,220 @@
+package navigator
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+var ErrNoMatches = errors.New("filter matches no commits")
+
+// Rule is one filter condition on a commit.
+//
+//	author:<text>    author name or email contains text (case-insensitive)
+//	path:<glob>      changes a file matching the glob ("dir/" or "dir/**" for a subtree)
+//	touches:<regexp> changes a file whose path matches the regexp
+//	msg:<regexp>     subject or body matches (bare text means the same)
+//
+// A leading "-" negates the rule.
+type Rule struct {
+	Field  string
+	Value  string
+	Negate bool
+	re     *regexp.Regexp
+}
+
+// ParseRule parses a single rule such as "author:ana" or "-msg:^chore".
+func ParseRule(s string) (Rule, error) {
+	var r Rule
+	if strings.HasPrefix(s, "-") && len(s) > 1 {
+		r.Negate = true
+		s = s[1:]
+	}
+	r.Field, r.Value = "msg", s
+	if field, value, ok := strings.Cut(s, ":"); ok {
+		switch field {
+		case "author", "path", "touches", "msg":
+			r.Field, r.Value = field, value
+		}
+	}
+	if r.Value == "" {
+		return Rule{}, fmt.Errorf("empty %s: rule", r.Field)
+	}
+
+	switch r.Field {
+	case "msg", "touches":
+		pattern := r.Value
+		if !hasUpper(pattern) {
+			pattern = "(?i)" + pattern
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return Rule{}, err
+		}
+		r.re = re
+	case "path":
+		if _, err := path.Match(strings.TrimSuffix(r.Value, "/**"), ""); err != nil {
+			return Rule{}, fmt.Errorf("bad glob %q: %w", r.Value, err)
+		}
+	}
+	return r, nil
+}
+
+func (r Rule) String() string {
+	s := r.Field + ":" + r.Value
+	if strings.ContainsAny(r.Value, " \t") {
+		s = r.Field + ":" + `"` + r.Value + `"`
+	}
+	if r.Negate {
+		s = "-" + s
+	}
+	return s
+}
+
+// match reports whether c satisfies the rule, ignoring Negate.
+func (r Rule) match(c Commit) bool {
+	switch r.Field {
+	case "author":
+		v := strings.ToLower(r.Value)
+		return strings.Contains(strings.ToLower(c.Author), v) || strings.Contains(strings.ToLower(c.Email), v)
+	case "path":
+		for _, f := range c.Files {
+			if globMatch(r.Value, f) {
+				return true
+			}
+		}
+		return false
+	case "touches":
+		for _, f := range c.Files {
+			if r.re.MatchString(f) {
+				return true
+			}
+		}
+		return false
+	default:
+		return r.re.MatchString(c.Message) || r.re.MatchString(c.Body)
+	}
+}
+
+// globMatch matches a file path against a glob. "dir/" and "dir/**" match
+// everything below dir; a pattern without a slash also matches base names.
+func globMatch(pattern, file string) bool {
+	if prefix, ok := strings.CutSuffix(pattern, "/**"); ok {
+		return strings.HasPrefix(file, prefix+"/")
+	}
+	if strings.HasSuffix(pattern, "/") {
+		return strings.HasPrefix(file, pattern)
+	}
+	if ok, _ := path.Match(pattern, file); ok {
+		return true
+	}
+	if !strings.Contains(pattern, "/") {
+		ok, _ := path.Match(pattern, path.Base(file))
+		return ok
+	}
+	return false
+}
+
+// Filter narrows the commits the navigator steps through. A commit is
+// visible when it satisfies every rule.
+type Filter struct {
+	Rules []Rule
+}
+
+// ParseFilter parses whitespace-separated rules. Double quotes group a
+// value containing spaces: msg:"bump deps".
+func ParseFilter(s string) (Filter, error) {
+	var f Filter
+	for _, tok := range splitQuoted(s) {
+		r, err := ParseRule(tok)
+		if err != nil {
+			return Filter{}, err
+		}
+		f.Rules = append(f.Rules, r)
+	}
+	return f, nil
+}
+
+// ParseSkipRules parses a skip-rules file: one rule per line, blank lines
+// and # comments ignored. Commits matching any line are hidden.
+func ParseSkipRules(text string) (Filter, error) {
+	var f Filter
+	for i, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		r, err := ParseRule(line)
+		if err != nil {
+			return Filter{}, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		r.Negate = !r.Negate
+		f.Rules = append(f.Rules, r)
+	}
+	return f, nil
+}
+
+func splitQuoted(s string) []string {
+	var fields []string
+	var cur strings.Builder
+	inQuote, started := false, false
+	for _, r := range s {
+		switch {
+		case r == '"':
+			inQuote = !inQuote
+			started = true
+		case (r == ' ' || r == '\t') && !inQuote:
+			if started {
+				fields = append(fields, cur.String())
+				cur.Reset()
+				started = false
+			}
+		default:
+			cur.WriteRune(r)
+			started = true
+		}
+	}
+	if started {
+		fields = append(fields, cur.String())
+	}
+	return fields
+}
+
+func (f Filter) Empty() bool { return len(f.Rules) == 0 }
+
+// onPaths reports whether f has a rule on the files a commit changed.
+func (f Filter) onPaths() bool {
+	for _, r := range f.Rules {
+		if r.Field == "path" || r.Field == "touches" {
+			return true
+		}
+	}
+	return false
+}
+
+// And returns a filter requiring both f and g.
+func (f Filter) And(g Filter) Filter {
+	rules := make([]Rule, 0, len(f.Rules)+len(g.Rules))
+	rules = append(rules, f.Rules...)
+	rules = append(rules, g.Rules...)
+	return Filter{Rules: rules}
+}
+
+func (f Filter) Match(c Commit) bool {
+	for _, r := range f.Rules {
+		if r.match(c) == r.Negate {
+			return false
+		}
+	}
+	return true
+}
+
+func (f Filter) String() string {
+	parts := make([]string, len(f.Rules))
+	for i, r := range f.Rules {
+		parts[i] = r.String()
+	}
+	return strings.Join(parts, " ")
+}
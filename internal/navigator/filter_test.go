@@ -0,0 +1,208 @@
+package navigator
+
+import "testing"
+
+func filterCommits() []Commit {
+	return []Commit{
+		{Hash: "a000000", Message: "add parser", Author: "Ana", Files: []string{"parser/lex.go", "README.md"}},
+		{Hash: "b000000", Message: "chore: bump deps", Author: "bot", Email: "bot@ci", Files: []string{"go.mod", "go.sum"}},
+		{Hash: "c000000", Message: "fix lexer", Author: "Bo", Files: []string{"parser/lex.go"}},
+		{Hash: "d000000", Message: "chore: bump deps", Author: "bot", Email: "bot@ci", Files: []string{"go.sum"}},
+		{Hash: "e000000", Message: "docs", Author: "Ana", Files: []string{"docs/intro.md"}},
+	}
+}
+
+func visibleHashes(n *Navigator) []string {
+	var hashes []string
+	for i := 0; i < n.Len(); i++ {
+		if n.Visible(i) {
+			hashes = append(hashes, n.At(i).Hash[:1])
+		}
+	}
+	return hashes
+}
+
+func TestParseFilter_Rules(t *testing.T) {
+	tests := []struct {
+		filter string
+		want   string
+	}{
+		{"author:ana", "ae"},
+		{"path:parser/", "ac"},
+		{"path:parser/**", "ac"},
+		{"path:*.md", "ae"},
+		{"path:go.*", "bd"},
+		{"touches:lex", "ac"},
+		{"msg:^chore", "bd"},
+		{"lexer", "c"},
+		{"-author:bot", "ace"},
+		{`-msg:"bump deps" author:ana`, "ae"},
+	}
+	for _, tt := range tests {
+		f, err := ParseFilter(tt.filter)
+		if err != nil {
+			t.Fatalf("%q: unexpected error: %v", tt.filter, err)
+		}
+		nav, _ := NewNavigator(filterCommits())
+		nav.SetFilter(f)
+		got := ""
+		for _, h := range visibleHashes(nav) {
+			got += h
+		}
+		if got != tt.want {
+			t.Errorf("%q: expected visible %q, got %q", tt.filter, tt.want, got)
+		}
+	}
+}
+
+func TestParseFilter_Errors(t *testing.T) {
+	for _, s := range []string{"msg:(", "author:", "path:[", "touches:*"} {
+		if _, err := ParseFilter(s); err == nil {
+			t.Errorf("%q: expected error, got nil", s)
+		}
+	}
+}
+
+func TestParseSkipRules(t *testing.T) {
+	f, err := ParseSkipRules("# hide dependency bumps\n^chore: bump deps\n\nauthor:nobody\n")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	nav, _ := NewNavigator(filterCommits())
+	nav.SetFilter(f)
+
+	got := ""
+	for _, h := range visibleHashes(nav) {
+		got += h
+	}
+	if got != "ace" {
+		t.Errorf("expected skip rules to hide b and d, got visible %q", got)
+	}
+}
+
+func TestNavigator_FilteredNavigation(t *testing.T) {
+	nav, _ := NewNavigator(filterCommits())
+	f, _ := ParseFilter("-author:bot")
+	nav.SetFilter(f)
+
+	if pos, total := nav.Position(); pos != 1 || total != 3 {
+		t.Errorf("expected 1/3, got %d/%d", pos, total)
+	}
+	if next, _ := nav.Peek(); next.Hash != "c000000" {
+		t.Errorf("expected peek to skip hidden commit, got %s", next.Hash)
+	}
+	nav.Next()
+	if nav.Current().Hash != "c000000" {
+		t.Errorf("expected next to skip hidden commit, got %s", nav.Current().Hash)
+	}
+	if pos, _ := nav.AbsPosition(); pos != 3 {
+		t.Errorf("expected absolute position 3, got %d", pos)
+	}
+	nav.Next()
+	if err := nav.Next(); err != ErrAtEnd {
+		t.Errorf("expected ErrAtEnd at last visible commit, got %v", err)
+	}
+	nav.Prev()
+	nav.Prev()
+	if err := nav.Prev(); err != ErrAtStart {
+		t.Errorf("expected ErrAtStart at first visible commit, got %v", err)
+	}
+	if i, ok := nav.IndexAt(2); !ok || i != 2 {
+		t.Errorf("expected filtered position 2 to be index 2, got %d %v", i, ok)
+	}
+	if nav.First() != 0 || nav.Last() != 4 {
+		t.Errorf("expected first/last 0/4, got %d/%d", nav.First(), nav.Last())
+	}
+}
+
+func TestNavigator_SetFilter_MovesOffHiddenCommit(t *testing.T) {
+	nav, _ := NewNavigator(filterCommits())
+	nav.GoTo(1) // bump deps
+
+	f, _ := ParseFilter("-author:bot")
+	nav.SetFilter(f)
+	if nav.Current().Hash != "c000000" {
+		t.Errorf("expected to move to next visible commit, got %s", nav.Current().Hash)
+	}
+
+	nav.ClearFilter()
+	if nav.Current().Hash != "c000000" {
+		t.Errorf("expected clearing the filter to keep the current commit, got %s", nav.Current().Hash)
+	}
+	if pos, total := nav.Position(); pos != 3 || total != 5 {
+		t.Errorf("expected 3/5 after clearing, got %d/%d", pos, total)
+	}
+}
+
+func TestNavigator_SetFilter_NoMatches(t *testing.T) {
+	nav, _ := NewNavigator(filterCommits())
+	f, _ := ParseFilter("author:nobody")
+
+	if err := nav.SetFilter(f); err != ErrNoMatches {
+		t.Errorf("expected ErrNoMatches, got %v", err)
+	}
+	if _, total := nav.Position(); total != 5 {
+		t.Errorf("expected filter to be left unapplied, got total %d", total)
+	}
+}
+
+func TestNavigator_HiddenCurrentPosition(t *testing.T) {
+	nav, _ := NewNavigator(filterCommits())
+	f, _ := ParseFilter("-author:bot")
+	nav.SetFilter(f)
+	nav.GoTo(3) // hidden, e.g. reached via goto by hash
+
+	if pos, _ := nav.Position(); pos != 0 {
+		t.Errorf("expected position 0 on a hidden commit, got %d", pos)
+	}
+	nav.Next()
+	if nav.Current().Hash != "e000000" {
+		t.Errorf("expected next visible after hidden commit, got %s", nav.Current().Hash)
+	}
+}
+
+func TestNavigator_SetFilter_LoadsFiles(t *testing.T) {
+	var commits, appended []Commit
+	for _, c := range filterCommits() {
+		c.Files = nil
+		commits = append(commits, c)
+	}
+	commits, appended = commits[:3], commits[3:]
+	files := map[string][]string{}
+	for _, c := range filterCommits() {
+		files[c.Hash] = c.Files
+	}
+	var asked [][]string
+	nav, _ := NewLoadingNavigator(commits)
+	nav.SetFileLoader(func(revs []string) ([][]string, error) {
+		asked = append(asked, revs)
+		var out [][]string
+		for _, r := range revs {
+			out = append(out, files[r])
+		}
+		return out, nil
+	})
+
+	f, _ := ParseFilter("author:bot")
+	if missing := nav.FilesMissing(f); missing != nil {
+		t.Errorf("expected no files missing for a filter on authors, got %v", missing)
+	}
+	nav.SetFilter(f)
+	if len(asked) != 0 {
+		t.Errorf("expected no files looked up for a filter on authors, got %v", asked)
+	}
+	f, _ = ParseFilter("path:parser/")
+	if missing := nav.FilesMissing(f); len(missing) != 3 {
+		t.Errorf("expected the first 3 commits' files missing, got %v", missing)
+	}
+	if err := nav.SetFilter(f); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	nav.Append(appended)
+	if got := visibleHashes(nav); len(got) != 2 || got[0] != "a" || got[1] != "c" {
+		t.Errorf("expected a and c visible, got %v", got)
+	}
+	if len(asked) != 2 || len(asked[0]) != 3 || len(asked[1]) != 2 {
+		t.Errorf("expected the files of the first 3 commits, then the 2 appended, got %v", asked)
+	}
+}
@@ -0,0 +1,138 @@
+package navigator
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Fuzzy is a fuzzy filter such as `fxrace ana`. Each space-separated term
+// must appear in the hash, the subject or the author of a commit, its
+// characters in order but not necessarily next to each other.
+//
+// Matching is smart-case: case-insensitive unless the filter has an
+// uppercase letter.
+type Fuzzy struct {
+	Raw   string
+	terms [][]rune
+	fold  bool
+}
+
+// ParseFuzzy parses a fuzzy filter. Every string is a valid filter.
+func ParseFuzzy(s string) Fuzzy {
+	f := Fuzzy{Raw: s, fold: !hasUpper(s)}
+	for _, term := range strings.Fields(s) {
+		if f.fold {
+			term = strings.ToLower(term)
+		}
+		f.terms = append(f.terms, []rune(term))
+	}
+	return f
+}
+
+// Empty reports whether the filter matches everything.
+func (f Fuzzy) Empty() bool {
+	return len(f.terms) == 0
+}
+
+// FuzzyHit holds the byte offsets of the characters a Fuzzy matched in
+// each field of a commit.
+type FuzzyHit struct {
+	Hash, Message, Author []int
+}
+
+// Match reports whether c satisfies every term of the filter, and where.
+// A term that fits several fields counts in the one where its characters
+// are closest together.
+func (f Fuzzy) Match(c Commit) (FuzzyHit, bool) {
+	var hit FuzzyHit
+	fields := []struct {
+		text string
+		hits *[]int
+	}{
+		{c.Message, &hit.Message},
+		{c.Hash, &hit.Hash},
+		{c.Author, &hit.Author},
+	}
+	for _, term := range f.terms {
+		best, bestSpan := -1, 0
+		var bestPos []int
+		for i, field := range fields {
+			pos, span, ok := fuzzyFind(term, field.text, f.fold)
+			if ok && (best < 0 || span < bestSpan) {
+				best, bestSpan, bestPos = i, span, pos
+			}
+		}
+		if best < 0 {
+			return FuzzyHit{}, false
+		}
+		*fields[best].hits = append(*fields[best].hits, bestPos...)
+	}
+	return hit, true
+}
+
+// FilterCommits returns the indices of the commits matching f, in order,
+// with where each matched.
+func FilterCommits(commits []Commit, f Fuzzy) ([]int, []FuzzyHit) {
+	var indices []int
+	var hits []FuzzyHit
+	for i, c := range commits {
+		if hit, ok := f.Match(c); ok {
+			indices = append(indices, i)
+			hits = append(hits, hit)
+		}
+	}
+	return indices, hits
+}
+
+// fuzzyFind looks for the characters of term in order in s. Among the
+// places they appear it picks a short one: it finds where the earliest
+// complete match ends, then walks back from there to the latest start.
+// It returns the byte offsets of the matched characters and the length
+// of the stretch they span.
+func fuzzyFind(term []rune, s string, fold bool) ([]int, int, bool) {
+	if len(term) == 0 {
+		return nil, 0, true
+	}
+	norm := func(r rune) rune {
+		if fold {
+			return unicode.ToLower(r)
+		}
+		return r
+	}
+
+	end, t := -1, 0 // end is just past the earliest complete match
+	for i, r := range s {
+		if norm(r) == term[t] {
+			t++
+			if t == len(term) {
+				_, size := utf8.DecodeRuneInString(s[i:])
+				end = i + size
+				break
+			}
+		}
+	}
+	if end < 0 {
+		return nil, 0, false
+	}
+
+	start := end
+	for j, t := end, len(term)-1; t >= 0; {
+		r, size := utf8.DecodeLastRuneInString(s[:j])
+		j -= size
+		if norm(r) == term[t] {
+			start = j
+			t--
+		}
+	}
+
+	pos := make([]int, 0, len(term))
+	t = 0
+	for i, r := range s[start:] {
+		if t < len(term) && norm(r) == term[t] {
+			pos = append(pos, start+i)
+			t++
+		}
+	}
+	return pos, end - start, true
+}
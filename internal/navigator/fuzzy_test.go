@@ -0,0 +1,61 @@
+package navigator
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestFuzzy_Filter(t *testing.T) {
+	commits := searchCommits()
+	tests := []struct {
+		filter string
+		want   []int
+	}{
+		{"", []int{0, 1, 2, 3}},
+		{"fxrc", []int{1}},          // subject, characters apart
+		{"fix", []int{1, 2}},        // smart-case
+		{"Fix", []int{2}},           // case-sensitive with an uppercase letter
+		{"789", []int{3}},           // hash
+		{"bo", []int{1}},            // author
+		{"ana typo", []int{2}},      // every term must match
+		{"cy docs", []int{3}},       // terms in different fields
+		{"closes", []int{}},         // the body isn't searched
+		{"rcaefix", []int{}},        // order matters
+		{"  fix   race ", []int{1}}, // extra spaces
+	}
+	for _, tt := range tests {
+		got, hits := FilterCommits(commits, ParseFuzzy(tt.filter))
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) && !(len(got) == 0 && len(tt.want) == 0) {
+			t.Errorf("%q: expected %v, got %v", tt.filter, tt.want, got)
+		}
+		if len(hits) != len(got) {
+			t.Errorf("%q: expected a hit per match, got %d for %d", tt.filter, len(hits), len(got))
+		}
+	}
+}
+
+func TestFuzzy_Positions(t *testing.T) {
+	tests := []struct {
+		filter string
+		commit Commit
+		want   FuzzyHit
+	}{
+		// Walking back from the end: "fix" in "fix", not f…i…x spread out.
+		{"fix", Commit{Message: "f i fix"}, FuzzyHit{Message: []int{4, 5, 6}}},
+		{"ab ana", Commit{Hash: "abc", Message: "xyz", Author: "Ana"}, FuzzyHit{Hash: []int{0, 1}, Author: []int{0, 1, 2}}},
+		// Byte offsets of multi-byte characters.
+		{"éz", Commit{Message: "café éz"}, FuzzyHit{Message: []int{6, 8}}},
+		// A term goes to the field where it fits most tightly.
+		{"abc", Commit{Hash: "abc1234", Message: "a big change"}, FuzzyHit{Hash: []int{0, 1, 2}}},
+	}
+	for _, tt := range tests {
+		hit, ok := ParseFuzzy(tt.filter).Match(tt.commit)
+		if !ok {
+			t.Errorf("%q: expected a match in %+v", tt.filter, tt.commit)
+			continue
+		}
+		if fmt.Sprint(hit) != fmt.Sprint(tt.want) {
+			t.Errorf("%q: expected %+v, got %+v", tt.filter, tt.want, hit)
+		}
+	}
+}
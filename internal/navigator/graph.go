@@ -0,0 +1,148 @@
+package navigator
+
+import "errors"
+
+var (
+	ErrNotMerge    = errors.New("current commit is not a merge within the range")
+	ErrNotInBranch = errors.New("not inside a side branch")
+)
+
+// graph is the parent/child structure of the range, restricted to commits
+// inside it. It is built on first use.
+type graph struct {
+	index    map[string]int // commit index by Rev
+	parents  [][]int        // in-range parents of each commit, first parent first
+	children [][]int        // in-range children of each commit, in range order
+}
+
+func (n *Navigator) dag() *graph {
+	if n.graph != nil {
+		return n.graph
+	}
+	index := make(map[string]int, len(n.commits))
+	for i, c := range n.commits {
+		index[c.Rev()] = i
+	}
+	g := &graph{
+		index:    index,
+		parents:  make([][]int, len(n.commits)),
+		children: make([][]int, len(n.commits)),
+	}
+	for i, c := range n.commits {
+		for _, p := range c.Parents {
+			if pi, ok := index[p]; ok {
+				g.parents[i] = append(g.parents[i], pi)
+				g.children[pi] = append(g.children[pi], i)
+			}
+		}
+	}
+	n.graph = g
+	return g
+}
+
+// IndexOf returns the index of the commit with the full hash given, as
+// found in Commit.Parents.
+func (n *Navigator) IndexOf(hash string) (int, bool) {
+	i, ok := n.dag().index[hash]
+	return i, ok
+}
+
+// Parents returns the in-range parents of the commit at index, first parent first.
+func (n *Navigator) Parents(index int) []int {
+	return n.dag().parents[index]
+}
+
+// Children returns the in-range children of the commit at index.
+func (n *Navigator) Children(index int) []int {
+	return n.dag().children[index]
+}
+
+// Forward moves to the choice-th child of the current commit.
+func (n *Navigator) Forward(choice int) error {
+	children := n.Children(n.current)
+	if len(children) == 0 {
+		return ErrAtEnd
+	}
+	if choice < 0 || choice >= len(children) {
+		return ErrOutOfRange
+	}
+	n.current = children[choice]
+	return nil
+}
+
+// Backward moves to the choice-th parent of the current commit.
+func (n *Navigator) Backward(choice int) error {
+	parents := n.Parents(n.current)
+	if len(parents) == 0 {
+		return ErrAtStart
+	}
+	if choice < 0 || choice >= len(parents) {
+		return ErrOutOfRange
+	}
+	n.current = parents[choice]
+	return nil
+}
+
+// EnterBranch steps from a merge commit into the side branch it merged: to
+// the first commit of that branch that isn't already on the mainline. The
+// merge is remembered so LeaveBranch can return to it.
+func (n *Navigator) EnterBranch() error {
+	parents := n.Parents(n.current)
+	if len(parents) < 2 {
+		return ErrNotMerge
+	}
+	mainline := n.ancestors(parents[0])
+	if mainline[parents[1]] {
+		return ErrNotMerge // side parent already reachable from the mainline
+	}
+
+	// Walk the side branch back along first parents until the next step
+	// would land on the mainline (the fork point) or leave the range.
+	start := parents[1]
+	for {
+		ps := n.Parents(start)
+		if len(ps) == 0 || mainline[ps[0]] {
+			break
+		}
+		start = ps[0]
+	}
+
+	merge := n.current
+	if err := n.JumpTo(start); err != nil {
+		return err
+	}
+	n.branchStack = append(n.branchStack, merge)
+	return nil
+}
+
+// LeaveBranch returns to the merge commit the current side branch was entered from.
+func (n *Navigator) LeaveBranch() error {
+	if len(n.branchStack) == 0 {
+		return ErrNotInBranch
+	}
+	merge := n.branchStack[len(n.branchStack)-1]
+	n.branchStack = n.branchStack[:len(n.branchStack)-1]
+	return n.JumpTo(merge)
+}
+
+// BranchDepth returns how many side branches deep EnterBranch has gone.
+func (n *Navigator) BranchDepth() int {
+	return len(n.branchStack)
+}
+
+// ancestors returns the set of in-range commits reachable from index, including itself.
+func (n *Navigator) ancestors(index int) map[int]bool {
+	seen := map[int]bool{index: true}
+	stack := []int{index}
+	for len(stack) > 0 {
+		i := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		for _, p := range n.Parents(i) {
+			if !seen[p] {
+				seen[p] = true
+				stack = append(stack, p)
+			}
+		}
+	}
+	return seen
+}
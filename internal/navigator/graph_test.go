@@ -0,0 +1,119 @@
+package navigator
+
+import "testing"
+
+// mergeHistory builds:
+//
+//	a ─ b ─ c ─────── m ─ e
+//	     ╲           ╱
+//	      x1 ─── x2
+func mergeHistory() []Commit {
+	return []Commit{
+		{Hash: "a000000"},
+		{Hash: "b000000", Parents: []string{"a000000"}},
+		{Hash: "x100000", Parents: []string{"b000000"}},
+		{Hash: "c000000", Parents: []string{"b000000"}},
+		{Hash: "x200000", Parents: []string{"x100000"}},
+		{Hash: "m000000", Parents: []string{"c000000", "x200000"}},
+		{Hash: "e000000", Parents: []string{"m000000"}},
+	}
+}
+
+func TestNavigator_ParentsChildren(t *testing.T) {
+	nav, _ := NewNavigator(mergeHistory())
+
+	if got := nav.Children(1); len(got) != 2 || got[0] != 2 || got[1] != 3 {
+		t.Errorf("expected fork at b with children [2 3], got %v", got)
+	}
+	if got := nav.Parents(5); len(got) != 2 || got[0] != 3 || got[1] != 4 {
+		t.Errorf("expected merge m with parents [3 4], got %v", got)
+	}
+	if got := nav.Parents(0); len(got) != 0 {
+		t.Errorf("expected no in-range parents for a, got %v", got)
+	}
+}
+
+func TestNavigator_ParentsByFullHash(t *testing.T) {
+	// b and c share an abbreviation; c's parent is b, not a.
+	nav, _ := NewNavigator([]Commit{
+		{Hash: "abc1234", FullHash: "abc1234aaaa"},
+		{Hash: "abc1234", FullHash: "abc1234bbbb", Parents: []string{"abc1234aaaa"}},
+		{Hash: "abc1234", FullHash: "abc1234cccc", Parents: []string{"abc1234bbbb"}},
+	})
+
+	if got := nav.Parents(2); len(got) != 1 || got[0] != 1 {
+		t.Errorf("expected c's parent at 1, got %v", got)
+	}
+	if i, ok := nav.IndexOf("abc1234cccc"); !ok || i != 2 {
+		t.Errorf("expected c at 2, got %d, %v", i, ok)
+	}
+	if _, ok := nav.IndexOf("abc1234"); ok {
+		t.Error("expected no commit found by a shared abbreviation")
+	}
+}
+
+func TestNavigator_ForwardBackward(t *testing.T) {
+	nav, _ := NewNavigator(mergeHistory())
+	nav.GoTo(1) // b
+
+	if err := nav.Forward(1); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if nav.Current().Hash != "c000000" {
+		t.Errorf("expected to follow second child to c, got %s", nav.Current().Hash)
+	}
+
+	nav.Forward(0) // m
+	if err := nav.Backward(1); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if nav.Current().Hash != "x200000" {
+		t.Errorf("expected to follow second parent to x2, got %s", nav.Current().Hash)
+	}
+
+	if err := nav.Forward(5); err != ErrOutOfRange {
+		t.Errorf("expected ErrOutOfRange for bad choice, got %v", err)
+	}
+	nav.GoTo(6)
+	if err := nav.Forward(0); err != ErrAtEnd {
+		t.Errorf("expected ErrAtEnd at tip, got %v", err)
+	}
+	nav.GoTo(0)
+	if err := nav.Backward(0); err != ErrAtStart {
+		t.Errorf("expected ErrAtStart at root, got %v", err)
+	}
+}
+
+func TestNavigator_EnterLeaveBranch(t *testing.T) {
+	nav, _ := NewNavigator(mergeHistory())
+	nav.GoTo(5) // m
+
+	if err := nav.EnterBranch(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if nav.Current().Hash != "x100000" {
+		t.Errorf("expected to land on the first side-branch commit x1, got %s", nav.Current().Hash)
+	}
+	if nav.BranchDepth() != 1 {
+		t.Errorf("expected branch depth 1, got %d", nav.BranchDepth())
+	}
+
+	if err := nav.LeaveBranch(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if nav.Current().Hash != "m000000" {
+		t.Errorf("expected to return to merge m, got %s", nav.Current().Hash)
+	}
+	if err := nav.LeaveBranch(); err != ErrNotInBranch {
+		t.Errorf("expected ErrNotInBranch, got %v", err)
+	}
+}
+
+func TestNavigator_EnterBranch_NotMerge(t *testing.T) {
+	nav, _ := NewNavigator(mergeHistory())
+	nav.GoTo(3) // c
+
+	if err := nav.EnterBranch(); err != ErrNotMerge {
+		t.Errorf("expected ErrNotMerge, got %v", err)
+	}
+}
@@ -1,28 +1,156 @@
 package navigator
 
-import "errors"
+import (
+	"errors"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
 
 var (
 	ErrEmptyCommits = errors.New("commits list is empty")
 	ErrAtEnd        = errors.New("already at last commit")
 	ErrAtStart      = errors.New("already at first commit")
+	ErrOutOfRange   = errors.New("position out of range")
+	ErrNoMark       = errors.New("mark not set")
+	ErrInvalidMark  = errors.New("marks must be letters")
+	ErrJumpListEnd  = errors.New("no more jumps")
 )
 
 type Commit struct {
-	Hash    string
-	Message string
+	Hash     string // abbreviated, for display
+	FullHash string // "" if only the abbreviation is known
+	Message  string // subject line
+	Body     string // message body after the subject, may be empty
+	Author   string
+	Email    string
+	Date     time.Time // author date
+	Parents  []string  // full parent hashes, first parent first
+	Files    []string  // paths changed by the commit; nil until looked up
+
+	Committer      string
+	CommitterEmail string
+	CommitDate     time.Time
+}
+
+// Rev names the commit for git and in the history graph: by its full
+// hash, which unlike the abbreviation can't be shared with another
+// commit.
+func (c Commit) Rev() string {
+	if c.FullHash != "" {
+		return c.FullHash
+	}
+	return c.Hash
+}
+
+// ShortHash abbreviates a hash for display.
+func ShortHash(hash string) string {
+	if len(hash) > 7 {
+		return hash[:7]
+	}
+	return hash
+}
+
+// Kinds of Ref.
+const (
+	RefBranch = "branch"
+	RefRemote = "remote" // remote-tracking branch
+	RefTag    = "tag"
+	RefReflog = "reflog"
+)
+
+// Ref is somewhere to pick history from: a branch, a tag or an entry of
+// HEAD's reflog.
+type Ref struct {
+	Name    string // e.g. "main", "origin/login", "v1.2" or "HEAD@{2}"
+	Kind    string
+	Hash    string // abbreviated hash of the commit it points to
+	Subject string // the commit's subject, or the reflog entry's message
+	Date    time.Time
+}
+
+// Rev names the ref's commit for git. A reflog entry is named by its
+// hash, since its name moves on to another entry with the next checkout.
+func (r Ref) Rev() string {
+	if r.Kind == RefReflog {
+		return r.Hash
+	}
+	return r.Name
 }
 
 type Navigator struct {
 	commits []Commit
 	current int
+
+	marks map[rune]int // mark name → commit index
+
+	// jumps holds the positions left by non-sequential moves; jumpPos is
+	// where JumpBack/JumpForward currently stand (len(jumps) = newest).
+	jumps   []int
+	jumpPos int
+
+	graph       *graph
+	branchStack []int // merge commits left by EnterBranch, innermost last
+
+	// filter narrows Next/Prev/Peek/Position to the indices in view
+	// (ascending). view is nil when no filter is active.
+	filter Filter
+	view   []int
+
+	loading bool // more commits are still to be appended
+
+	files FileLoader // fills in Files for filters on paths; nil for none
 }
 
+// FileLoader returns the paths changed by each of the commits named by
+// revs, in order, for commits loaded without them.
+type FileLoader func(revs []string) ([][]string, error)
+
 func NewNavigator(commits []Commit) (*Navigator, error) {
 	if len(commits) == 0 {
 		return nil, ErrEmptyCommits
 	}
-	return &Navigator{commits: commits, current: 0}, nil
+	return &Navigator{commits: commits, current: 0, marks: map[rune]int{}}, nil
+}
+
+// NewLoadingNavigator starts on the first commits of a range that is still
+// being read. The rest arrive through Append; Finish marks the end.
+func NewLoadingNavigator(first []Commit) (*Navigator, error) {
+	n, err := NewNavigator(first)
+	if err != nil {
+		return nil, err
+	}
+	n.loading = true
+	return n, nil
+}
+
+// Append adds commits to the end of the range. An active filter is
+// extended to them and the history graph is rebuilt on next use. If their
+// files can't be loaded for a filter on paths, they match no path.
+func (n *Navigator) Append(commits []Commit) {
+	start := len(n.commits)
+	n.commits = append(n.commits, commits...)
+	if n.view != nil {
+		n.loadFiles(n.filter, start)
+		for i := start; i < len(n.commits); i++ {
+			if n.filter.Match(n.commits[i]) {
+				n.view = append(n.view, i)
+			}
+		}
+	}
+	n.graph = nil
+}
+
+// Finish records that every commit of the range has been appended.
+func (n *Navigator) Finish() {
+	n.loading = false
+}
+
+// Loading reports whether more commits may still be appended, in which
+// case Len and the totals from Position are not final.
+func (n *Navigator) Loading() bool {
+	return n.loading
 }
 
 func (n *Navigator) Current() Commit {
@@ -30,29 +158,363 @@ func (n *Navigator) Current() Commit {
 }
 
 func (n *Navigator) Next() error {
-	if n.current >= len(n.commits)-1 {
+	next, ok := n.nextIndex()
+	if !ok {
 		return ErrAtEnd
 	}
-	n.current++
+	n.current = next
 	return nil
 }
 
 func (n *Navigator) Prev() error {
-	if n.current <= 0 {
+	prev, ok := n.prevIndex()
+	if !ok {
 		return ErrAtStart
 	}
-	n.current--
+	n.current = prev
 	return nil
 }
 
+// Step moves count visible commits forward, or backward if count is
+// negative, stopping early at either end of the range. It returns how many
+// commits it moved; ErrAtEnd or ErrAtStart only when it could not move at all.
+func (n *Navigator) Step(count int) (int, error) {
+	move, edge := n.Next, ErrAtEnd
+	if count < 0 {
+		move, edge, count = n.Prev, ErrAtStart, -count
+	}
+	moved := 0
+	for moved < count && move() == nil {
+		moved++
+	}
+	if moved == 0 && count > 0 {
+		return 0, edge
+	}
+	return moved, nil
+}
+
+// Position returns the 1-based position of the current commit and the
+// number of commits, both counted within the active filter. The position is
+// 0 when the current commit is hidden by the filter.
 func (n *Navigator) Position() (int, int) {
+	if n.view == nil {
+		return n.current + 1, len(n.commits)
+	}
+	p := sort.SearchInts(n.view, n.current)
+	if p < len(n.view) && n.view[p] == n.current {
+		return p + 1, len(n.view)
+	}
+	return 0, len(n.view)
+}
+
+// AbsPosition is Position ignoring any filter.
+func (n *Navigator) AbsPosition() (int, int) {
 	return n.current + 1, len(n.commits)
 }
 
 // Peek returns the next commit without moving. Returns false if at the end.
 func (n *Navigator) Peek() (Commit, bool) {
-	if n.current >= len(n.commits)-1 {
+	next, ok := n.nextIndex()
+	if !ok {
 		return Commit{}, false
 	}
-	return n.commits[n.current+1], true
+	return n.commits[next], true
+}
+
+// nextIndex returns the first visible index after the current commit.
+func (n *Navigator) nextIndex() (int, bool) {
+	if n.view == nil {
+		return n.current + 1, n.current < len(n.commits)-1
+	}
+	p := sort.SearchInts(n.view, n.current+1)
+	if p >= len(n.view) {
+		return 0, false
+	}
+	return n.view[p], true
+}
+
+// prevIndex returns the last visible index before the current commit.
+func (n *Navigator) prevIndex() (int, bool) {
+	if n.view == nil {
+		return n.current - 1, n.current > 0
+	}
+	p := sort.SearchInts(n.view, n.current) - 1
+	if p < 0 {
+		return 0, false
+	}
+	return n.view[p], true
+}
+
+// Len returns the number of commits in the range.
+func (n *Navigator) Len() int {
+	return len(n.commits)
+}
+
+// Index returns the zero-based index of the current commit.
+func (n *Navigator) Index() int {
+	return n.current
+}
+
+// At returns the commit at a zero-based index.
+func (n *Navigator) At(index int) Commit {
+	return n.commits[index]
+}
+
+// SetFiles records the paths changed by the commit at index, for commits
+// loaded without them.
+func (n *Navigator) SetFiles(index int, files []string) {
+	n.commits[index].Files = files
+}
+
+// SetFileLoader sets how the files of commits loaded without them are
+// looked up when a filter matches on paths.
+func (n *Navigator) SetFileLoader(load FileLoader) {
+	n.files = load
+}
+
+// LoadFiles looks up the files of the commits that lack them if f matches
+// on paths, so that f can be matched against the commits.
+func (n *Navigator) LoadFiles(f Filter) error {
+	return n.loadFiles(f, 0)
+}
+
+// FilesMissing returns the indices of the commits LoadFiles would look up
+// for f, so that a caller can look them up in the background and record
+// them with SetFiles.
+func (n *Navigator) FilesMissing(f Filter) []int {
+	return n.filesMissing(f, 0)
+}
+
+// filesMissing is FilesMissing for the commits from index from on.
+func (n *Navigator) filesMissing(f Filter, from int) []int {
+	if n.files == nil || !f.onPaths() {
+		return nil
+	}
+	var missing []int
+	for i := from; i < len(n.commits); i++ {
+		if n.commits[i].Files == nil {
+			missing = append(missing, i)
+		}
+	}
+	return missing
+}
+
+// loadFiles is LoadFiles for the commits from index from on.
+func (n *Navigator) loadFiles(f Filter, from int) error {
+	missing := n.filesMissing(f, from)
+	if len(missing) == 0 {
+		return nil
+	}
+	revs := make([]string, len(missing))
+	for j, i := range missing {
+		revs[j] = n.commits[i].Rev()
+	}
+	files, err := n.files(revs)
+	if err != nil {
+		return err
+	}
+	for j, i := range missing {
+		n.commits[i].Files = files[j]
+	}
+	return nil
+}
+
+// GoTo moves directly to a zero-based index.
+func (n *Navigator) GoTo(index int) error {
+	if index < 0 || index >= len(n.commits) {
+		return ErrOutOfRange
+	}
+	n.current = index
+	return nil
+}
+
+// FindHash returns the index of the first commit whose hash starts with
+// prefix.
+func (n *Navigator) FindHash(prefix string) (int, bool) {
+	prefix = strings.ToLower(prefix)
+	if prefix == "" {
+		return 0, false
+	}
+	for i, c := range n.commits {
+		if strings.HasPrefix(c.Rev(), prefix) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// FindAfter returns the index of the first visible commit dated at or after t.
+func (n *Navigator) FindAfter(t time.Time) (int, bool) {
+	for i, c := range n.commits {
+		if n.Visible(i) && !c.Date.Before(t) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// SetFilter restricts navigation to commits matching f. If the current
+// commit is hidden, the navigator moves to the next visible commit (or the
+// previous one at the end of the range). An empty filter clears it; a filter
+// matching nothing is rejected with ErrNoMatches.
+func (n *Navigator) SetFilter(f Filter) error {
+	if f.Empty() {
+		n.ClearFilter()
+		return nil
+	}
+	if err := n.LoadFiles(f); err != nil {
+		return err
+	}
+	var view []int
+	for i, c := range n.commits {
+		if f.Match(c) {
+			view = append(view, i)
+		}
+	}
+	if len(view) == 0 {
+		return ErrNoMatches
+	}
+	n.filter, n.view = f, view
+	if !n.Visible(n.current) {
+		if next, ok := n.nextIndex(); ok {
+			n.current = next
+		} else if prev, ok := n.prevIndex(); ok {
+			n.current = prev
+		}
+	}
+	return nil
+}
+
+// ClearFilter shows every commit again, staying on the current one.
+func (n *Navigator) ClearFilter() {
+	n.filter, n.view = Filter{}, nil
+}
+
+// Filter returns the active filter.
+func (n *Navigator) Filter() Filter {
+	return n.filter
+}
+
+// Visible reports whether the commit at index passes the active filter.
+func (n *Navigator) Visible(index int) bool {
+	if n.view == nil {
+		return true
+	}
+	p := sort.SearchInts(n.view, index)
+	return p < len(n.view) && n.view[p] == index
+}
+
+// First returns the index of the first visible commit.
+func (n *Navigator) First() int {
+	if n.view == nil {
+		return 0
+	}
+	return n.view[0]
+}
+
+// Last returns the index of the last visible commit.
+func (n *Navigator) Last() int {
+	if n.view == nil {
+		return len(n.commits) - 1
+	}
+	return n.view[len(n.view)-1]
+}
+
+// IndexAt maps a 1-based position within the filter to a commit index.
+func (n *Navigator) IndexAt(pos int) (int, bool) {
+	_, total := n.Position()
+	if pos < 1 || pos > total {
+		return 0, false
+	}
+	if n.view == nil {
+		return pos - 1, true
+	}
+	return n.view[pos-1], true
+}
+
+// JumpTo moves to a zero-based index and records the departure point in the
+// jump list. Any positions ahead of the jump list cursor are discarded.
+func (n *Navigator) JumpTo(index int) error {
+	if index < 0 || index >= len(n.commits) {
+		return ErrOutOfRange
+	}
+	if index == n.current {
+		return nil
+	}
+	n.jumps = append(n.jumps[:n.jumpPos], n.current)
+	n.jumpPos = len(n.jumps)
+	n.current = index
+	return nil
+}
+
+// JumpBack returns to the position before the last jump (Ctrl+O).
+func (n *Navigator) JumpBack() error {
+	if n.jumpPos == 0 {
+		return ErrJumpListEnd
+	}
+	if n.jumpPos == len(n.jumps) {
+		// Remember where we are so JumpForward can come back here.
+		n.jumps = append(n.jumps, n.current)
+	}
+	n.jumpPos--
+	n.current = n.jumps[n.jumpPos]
+	return nil
+}
+
+// JumpForward undoes a JumpBack (Ctrl+I).
+func (n *Navigator) JumpForward() error {
+	if n.jumpPos >= len(n.jumps)-1 {
+		return ErrJumpListEnd
+	}
+	n.jumpPos++
+	n.current = n.jumps[n.jumpPos]
+	return nil
+}
+
+// SetMark records the current commit under a letter, replacing any previous
+// commit with that mark.
+func (n *Navigator) SetMark(name rune) error {
+	return n.SetMarkAt(name, n.current)
+}
+
+// SetMarkAt records the commit at index under a letter.
+func (n *Navigator) SetMarkAt(name rune, index int) error {
+	if !unicode.IsLetter(name) {
+		return ErrInvalidMark
+	}
+	if index < 0 || index >= len(n.commits) {
+		return ErrOutOfRange
+	}
+	n.marks[name] = index
+	return nil
+}
+
+// JumpToMark moves to a marked commit, recording it in the jump list.
+func (n *Navigator) JumpToMark(name rune) error {
+	index, ok := n.marks[name]
+	if !ok {
+		return ErrNoMark
+	}
+	return n.JumpTo(index)
+}
+
+// Marks returns a copy of all marks.
+func (n *Navigator) Marks() map[rune]int {
+	m := make(map[rune]int, len(n.marks))
+	for k, v := range n.marks {
+		m[k] = v
+	}
+	return m
+}
+
+// MarksAt returns the sorted names of the marks set on the commit at index.
+func (n *Navigator) MarksAt(index int) []rune {
+	var names []rune
+	for name, i := range n.marks {
+		if i == index {
+			names = append(names, name)
+		}
+	}
+	sort.Slice(names, func(a, b int) bool { return names[a] < names[b] })
+	return names
 }
@@ -2,6 +2,7 @@ package navigator
 
 import (
 	"testing"
+	"time"
 )
 
 func TestNewNavigator_WithCommits(t *testing.T) {
@@ -110,6 +111,57 @@ func TestNavigator_Prev_AtStart(t *testing.T) {
 	}
 }
 
+func TestNavigator_Step(t *testing.T) {
+	commits := []Commit{
+		{Hash: "a000000"}, {Hash: "b000000"}, {Hash: "c000000"}, {Hash: "d000000"},
+	}
+	nav, _ := NewNavigator(commits)
+
+	if moved, err := nav.Step(2); err != nil || moved != 2 || nav.Index() != 2 {
+		t.Errorf("expected to move 2 to index 2, got %d %v at %d", moved, err, nav.Index())
+	}
+	if moved, err := nav.Step(10); err != nil || moved != 1 || nav.Index() != 3 {
+		t.Errorf("expected to stop at the last commit after 1, got %d %v at %d", moved, err, nav.Index())
+	}
+	if _, err := nav.Step(1); err != ErrAtEnd {
+		t.Errorf("expected ErrAtEnd, got %v", err)
+	}
+	if moved, err := nav.Step(-5); err != nil || moved != 3 || nav.Index() != 0 {
+		t.Errorf("expected to move back 3 to index 0, got %d %v at %d", moved, err, nav.Index())
+	}
+	if _, err := nav.Step(-1); err != ErrAtStart {
+		t.Errorf("expected ErrAtStart, got %v", err)
+	}
+}
+
+func TestNavigator_Append(t *testing.T) {
+	nav, _ := NewLoadingNavigator([]Commit{{Hash: "a000000", Author: "Ana"}})
+	if !nav.Loading() {
+		t.Error("expected navigator to be loading")
+	}
+	f, _ := ParseFilter("author:ana")
+	nav.SetFilter(f)
+
+	nav.Append([]Commit{
+		{Hash: "b000000", Author: "Bo"},
+		{Hash: "c000000", Author: "Ana", Parents: []string{"b000000"}},
+	})
+	if _, total := nav.Position(); total != 2 {
+		t.Errorf("expected appended commits to be filtered, got total %d", total)
+	}
+	if err := nav.Next(); err != nil || nav.Current().Hash != "c000000" {
+		t.Errorf("expected to step onto appended commit, got %s %v", nav.Current().Hash, err)
+	}
+	if parents := nav.Parents(2); len(parents) != 1 || parents[0] != 1 {
+		t.Errorf("expected graph to cover appended commits, got %v", parents)
+	}
+
+	nav.Finish()
+	if nav.Loading() {
+		t.Error("expected loading to end after Finish")
+	}
+}
+
 func TestNavigator_Position(t *testing.T) {
 	commits := []Commit{
 		{Hash: "abc1234", Message: "first commit"},
@@ -136,3 +188,185 @@ func TestNavigator_Position(t *testing.T) {
 		t.Errorf("expected total 3, got %d", total)
 	}
 }
+
+func TestNavigator_GoTo(t *testing.T) {
+	commits := []Commit{
+		{Hash: "abc1234", Message: "first commit"},
+		{Hash: "def5678", Message: "second commit"},
+		{Hash: "ghi9012", Message: "third commit"},
+	}
+
+	nav, _ := NewNavigator(commits)
+
+	if err := nav.GoTo(2); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if cur := nav.Current(); cur.Hash != "ghi9012" {
+		t.Errorf("expected hash ghi9012, got %s", cur.Hash)
+	}
+	if pos, _ := nav.Position(); pos != 3 {
+		t.Errorf("expected position 3, got %d", pos)
+	}
+}
+
+func TestNavigator_GoTo_OutOfRange(t *testing.T) {
+	nav, _ := NewNavigator([]Commit{{Hash: "abc1234"}, {Hash: "def5678"}})
+
+	if err := nav.GoTo(2); err != ErrOutOfRange {
+		t.Errorf("expected ErrOutOfRange, got %v", err)
+	}
+	if err := nav.GoTo(-1); err != ErrOutOfRange {
+		t.Errorf("expected ErrOutOfRange, got %v", err)
+	}
+	if nav.Index() != 0 {
+		t.Errorf("expected index to stay at 0, got %d", nav.Index())
+	}
+}
+
+func TestNavigator_FindHash(t *testing.T) {
+	nav, _ := NewNavigator([]Commit{
+		{Hash: "abc1234"},
+		{Hash: "def5678", FullHash: "def5678aaaabbbbccccddddeeeeffff000011112"},
+	})
+
+	if i, ok := nav.FindHash("def5"); !ok || i != 1 {
+		t.Errorf("expected prefix def5 at 1, got %d %v", i, ok)
+	}
+	if i, ok := nav.FindHash("DEF5678aaaabbbb"); !ok || i != 1 {
+		t.Errorf("expected full hash to match at 1, got %d %v", i, ok)
+	}
+	if _, ok := nav.FindHash("abc1234ffff"); ok {
+		t.Error("expected no match for a prefix longer than the hash")
+	}
+	if _, ok := nav.FindHash("0000"); ok {
+		t.Error("expected no match for unknown hash")
+	}
+}
+
+func TestNavigator_FindAfter(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, 1, d, 12, 0, 0, 0, time.UTC) }
+	nav, _ := NewNavigator([]Commit{
+		{Hash: "abc1234", Date: day(1)},
+		{Hash: "def5678", Date: day(3)},
+		{Hash: "ghi9012", Date: day(5)},
+	})
+
+	if i, ok := nav.FindAfter(day(2)); !ok || i != 1 {
+		t.Errorf("expected first commit after Jan 2 at 1, got %d %v", i, ok)
+	}
+	if i, ok := nav.FindAfter(day(3)); !ok || i != 1 {
+		t.Errorf("expected commit on Jan 3 itself at 1, got %d %v", i, ok)
+	}
+	if _, ok := nav.FindAfter(day(6)); ok {
+		t.Error("expected no commit after the last date")
+	}
+}
+
+func tenCommits() []Commit {
+	var commits []Commit
+	for i := 0; i < 10; i++ {
+		commits = append(commits, Commit{Hash: string(rune('a'+i)) + "000000"})
+	}
+	return commits
+}
+
+func TestNavigator_JumpList(t *testing.T) {
+	nav, _ := NewNavigator(tenCommits())
+
+	nav.JumpTo(5)
+	nav.JumpTo(9)
+
+	steps := []struct {
+		name string
+		move func() error
+		want int
+	}{
+		{"back", nav.JumpBack, 5},
+		{"back", nav.JumpBack, 0},
+		{"forward", nav.JumpForward, 5},
+		{"forward", nav.JumpForward, 9},
+	}
+	for i, st := range steps {
+		if err := st.move(); err != nil {
+			t.Fatalf("step %d (%s): unexpected error %v", i, st.name, err)
+		}
+		if nav.Index() != st.want {
+			t.Fatalf("step %d (%s): expected index %d, got %d", i, st.name, st.want, nav.Index())
+		}
+	}
+
+	if err := nav.JumpForward(); err != ErrJumpListEnd {
+		t.Errorf("expected ErrJumpListEnd past the newest jump, got %v", err)
+	}
+}
+
+func TestNavigator_JumpList_SequentialMovesNotRecorded(t *testing.T) {
+	nav, _ := NewNavigator(tenCommits())
+
+	nav.Next()
+	nav.Next()
+	if err := nav.JumpBack(); err != ErrJumpListEnd {
+		t.Errorf("expected no jumps after sequential moves, got %v", err)
+	}
+
+	nav.JumpTo(7)
+	nav.JumpBack()
+	if nav.Index() != 2 {
+		t.Errorf("expected to return to index 2, got %d", nav.Index())
+	}
+}
+
+func TestNavigator_JumpTo_TruncatesForwardHistory(t *testing.T) {
+	nav, _ := NewNavigator(tenCommits())
+
+	nav.JumpTo(5)
+	nav.JumpTo(9)
+	nav.JumpBack() // at 5
+	nav.JumpTo(3)  // forward history (9) is dropped
+
+	if err := nav.JumpForward(); err != ErrJumpListEnd {
+		t.Errorf("expected forward history to be discarded, got %v", err)
+	}
+	nav.JumpBack()
+	if nav.Index() != 5 {
+		t.Errorf("expected to return to index 5, got %d", nav.Index())
+	}
+}
+
+func TestNavigator_Marks(t *testing.T) {
+	nav, _ := NewNavigator(tenCommits())
+
+	nav.GoTo(4)
+	if err := nav.SetMark('a'); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	nav.SetMark('b')
+	nav.GoTo(8)
+
+	if err := nav.JumpToMark('a'); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if nav.Index() != 4 {
+		t.Errorf("expected index 4, got %d", nav.Index())
+	}
+	if got := string(nav.MarksAt(4)); got != "ab" {
+		t.Errorf("expected marks ab at 4, got %q", got)
+	}
+
+	// Jumping to a mark is recorded in the jump list.
+	nav.JumpBack()
+	if nav.Index() != 8 {
+		t.Errorf("expected jump back to 8, got %d", nav.Index())
+	}
+}
+
+func TestNavigator_Marks_Errors(t *testing.T) {
+	nav, _ := NewNavigator(tenCommits())
+
+	if err := nav.JumpToMark('z'); err != ErrNoMark {
+		t.Errorf("expected ErrNoMark, got %v", err)
+	}
+	if err := nav.SetMark('1'); err != ErrInvalidMark {
+		t.Errorf("expected ErrInvalidMark, got %v", err)
+	}
+}
@@ -0,0 +1,138 @@
+package navigator
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Query is a parsed commit search. Free text is a regular expression matched
+// against the subject and body; "author:" matches the author name or email
+// and "hash:" matches a hash prefix. All given parts must match.
+//
+// Matching is smart-case: case-insensitive unless the text has an uppercase letter.
+type Query struct {
+	Raw    string
+	Text   *regexp.Regexp // nil when the query has no free text
+	Author string         // lowercased substring
+	Hash   string         // lowercased prefix
+}
+
+// ParseQuery parses a search string such as `fix.*race author:ana hash:3f`.
+func ParseQuery(s string) (Query, error) {
+	q := Query{Raw: s}
+	var text []string
+	for _, tok := range strings.Fields(s) {
+		switch {
+		case strings.HasPrefix(tok, "author:"):
+			q.Author = strings.ToLower(strings.TrimPrefix(tok, "author:"))
+		case strings.HasPrefix(tok, "hash:"):
+			q.Hash = strings.ToLower(strings.TrimPrefix(tok, "hash:"))
+		default:
+			text = append(text, tok)
+		}
+	}
+	if len(text) > 0 {
+		pattern := strings.Join(text, " ")
+		if !hasUpper(pattern) {
+			pattern = "(?i)" + pattern
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return Query{}, err
+		}
+		q.Text = re
+	}
+	return q, nil
+}
+
+func hasUpper(s string) bool {
+	for _, r := range s {
+		if unicode.IsUpper(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// Empty reports whether the query matches everything.
+func (q Query) Empty() bool {
+	return q.Text == nil && q.Author == "" && q.Hash == ""
+}
+
+// Match reports whether c satisfies every part of the query.
+func (q Query) Match(c Commit) bool {
+	if q.Hash != "" && !strings.HasPrefix(c.Rev(), q.Hash) && !strings.HasPrefix(q.Hash, c.Rev()) {
+		return false
+	}
+	if q.Author != "" &&
+		!strings.Contains(strings.ToLower(c.Author), q.Author) &&
+		!strings.Contains(strings.ToLower(c.Email), q.Author) {
+		return false
+	}
+	if q.Text != nil && !q.Text.MatchString(c.Message) && !q.Text.MatchString(c.Body) {
+		return false
+	}
+	return true
+}
+
+// Search returns the indices of all visible commits matching q, in order.
+func (n *Navigator) Search(q Query) []int {
+	var matches []int
+	for _, i := range SearchCommits(n.commits, q) {
+		if n.Visible(i) {
+			matches = append(matches, i)
+		}
+	}
+	return matches
+}
+
+// SearchCommits returns the indices of the commits matching q.
+func SearchCommits(commits []Commit, q Query) []int {
+	if q.Empty() {
+		return nil
+	}
+	var matches []int
+	for i, c := range commits {
+		if q.Match(c) {
+			matches = append(matches, i)
+		}
+	}
+	return matches
+}
+
+// NextMatch returns the first match after from, wrapping to the start.
+func NextMatch(matches []int, from int) (int, bool) {
+	if len(matches) == 0 {
+		return 0, false
+	}
+	for _, m := range matches {
+		if m > from {
+			return m, true
+		}
+	}
+	return matches[0], true
+}
+
+// PrevMatch returns the last match before from, wrapping to the end.
+func PrevMatch(matches []int, from int) (int, bool) {
+	if len(matches) == 0 {
+		return 0, false
+	}
+	for i := len(matches) - 1; i >= 0; i-- {
+		if matches[i] < from {
+			return matches[i], true
+		}
+	}
+	return matches[len(matches)-1], true
+}
+
+// MatchNumber returns the 1-based ordinal of index among matches, or 0.
+func MatchNumber(matches []int, index int) int {
+	for i, m := range matches {
+		if m == index {
+			return i + 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,86 @@
+package navigator
+
+import "testing"
+
+func searchCommits() []Commit {
+	return []Commit{
+		{Hash: "abc1234", Message: "add parser", Author: "Ana", Email: "ana@example.com"},
+		{Hash: "def5678", Message: "fix race in watcher", Body: "Closes #12", Author: "Bo", Email: "bo@example.com"},
+		{Hash: "0123456", Message: "Fix typo", Author: "Ana", Email: "ana@example.com"},
+		{Hash: "789abcd", Message: "docs", Body: "mention the race fix", Author: "Cy", Email: "cy@example.com"},
+	}
+}
+
+func TestParseQuery_Match(t *testing.T) {
+	commits := searchCommits()
+	tests := []struct {
+		query string
+		want  []int
+	}{
+		{"fix", []int{1, 2, 3}},         // smart-case: lowercase matches any case, body included
+		{"Fix", []int{2}},               // uppercase makes it case-sensitive
+		{"race.*watcher", []int{1}},     // regexp
+		{"#12", []int{1}},               // body only
+		{"author:ana", []int{0, 2}},     // author name
+		{"author:bo@", []int{1}},        // author email
+		{"hash:789", []int{3}},          // hash prefix
+		{"fix author:ana", []int{2}},    // combined
+		{"hash:abc author:bo", []int{}}, // no match
+	}
+	for _, tt := range tests {
+		q, err := ParseQuery(tt.query)
+		if err != nil {
+			t.Fatalf("%q: unexpected error: %v", tt.query, err)
+		}
+		got := SearchCommits(commits, q)
+		if len(got) != len(tt.want) {
+			t.Errorf("%q: expected %v, got %v", tt.query, tt.want, got)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%q: expected %v, got %v", tt.query, tt.want, got)
+				break
+			}
+		}
+	}
+}
+
+func TestParseQuery_InvalidRegexp(t *testing.T) {
+	if _, err := ParseQuery("fix("); err == nil {
+		t.Error("expected error for invalid regexp")
+	}
+}
+
+func TestParseQuery_Empty(t *testing.T) {
+	q, _ := ParseQuery("   ")
+	if !q.Empty() {
+		t.Error("expected blank query to be empty")
+	}
+	if got := SearchCommits(searchCommits(), q); got != nil {
+		t.Errorf("expected no matches for empty query, got %v", got)
+	}
+}
+
+func TestNextPrevMatch_Wraps(t *testing.T) {
+	matches := []int{2, 5, 9}
+
+	if m, _ := NextMatch(matches, 5); m != 9 {
+		t.Errorf("expected next after 5 to be 9, got %d", m)
+	}
+	if m, _ := NextMatch(matches, 9); m != 2 {
+		t.Errorf("expected next after 9 to wrap to 2, got %d", m)
+	}
+	if m, _ := PrevMatch(matches, 5); m != 2 {
+		t.Errorf("expected prev before 5 to be 2, got %d", m)
+	}
+	if m, _ := PrevMatch(matches, 2); m != 9 {
+		t.Errorf("expected prev before 2 to wrap to 9, got %d", m)
+	}
+	if _, ok := NextMatch(nil, 0); ok {
+		t.Error("expected no match in empty list")
+	}
+	if n := MatchNumber(matches, 9); n != 3 {
+		t.Errorf("expected 9 to be match 3, got %d", n)
+	}
+}
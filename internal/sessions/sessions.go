@@ -0,0 +1,125 @@
+// Package sessions saves replay sessions so that they can be resumed later.
+// Sessions live in the repository's .git/replay/sessions directory, one JSON
+// file per commit range; replaying the same range again overwrites it.
+package sessions
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+var ErrNotFound = errors.New("no saved session")
+
+// Session is the saved state of one replay. Commits are recorded by hash,
+// not position, so that the state survives filters changing the positions.
+type Session struct {
+	Start     string            `json:"start"` // full hash of the first commit of the range
+	End       string            `json:"end"`   // full hash of the last commit of the range
+	Commit    string            `json:"commit"`
+	Position  int               `json:"position"` // 1-based, for listings only
+	Total     int               `json:"total"`
+	Marks     map[string]string `json:"marks,omitempty"`   // mark name → hash
+	Filter    string            `json:"filter,omitempty"`  // filter typed with 'f'
+	NoSkip    bool              `json:"no_skip,omitempty"` // skip rules were cleared
+	Search    string            `json:"search,omitempty"`
+	Diff      bool              `json:"diff,omitempty"`    // detail view was open
+	Scroll    int               `json:"scroll,omitempty"`  // diff scroll offset
+	Unified   bool              `json:"unified,omitempty"` // side-by-side diff was off
+	Wrap      bool              `json:"wrap,omitempty"`    // diff lines were wrapped
+	Folded    []string          `json:"folded,omitempty"`  // paths of the diff's folded files
+	ExportDir string            `json:"export_dir,omitempty"`
+	Saved     time.Time         `json:"saved"`
+}
+
+// Dir returns the sessions directory for a repository.
+func Dir(gitDir string) string {
+	return filepath.Join(gitDir, "replay", "sessions")
+}
+
+// Name identifies a session by its range, e.g. "3f2a1bc..9d0e4f2".
+func (s Session) Name() string {
+	return short(s.Start) + ".." + short(s.End)
+}
+
+func short(hash string) string {
+	if len(hash) > 7 {
+		return hash[:7]
+	}
+	return hash
+}
+
+func (s Session) file(dir string) string {
+	return filepath.Join(dir, short(s.Start)+"-"+short(s.End)+".json")
+}
+
+// Save writes s to dir, replacing any earlier session for the same range.
+func Save(dir string, s Session) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, ".session-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), s.file(dir))
+}
+
+// List returns the saved sessions, most recently saved first. Unreadable
+// files are skipped.
+func List(dir string) ([]Session, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	var list []Session
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		var s Session
+		if err := json.Unmarshal(data, &s); err != nil || s.Start == "" {
+			continue
+		}
+		list = append(list, s)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Saved.After(list[j].Saved) })
+	return list, nil
+}
+
+// Find returns the most recent session whose start commit begins with
+// prefix, or the most recent session of all if prefix is empty.
+func Find(dir, prefix string) (Session, error) {
+	list, err := List(dir)
+	if err != nil {
+		return Session{}, err
+	}
+	for _, s := range list {
+		if strings.HasPrefix(s.Start, prefix) {
+			return s, nil
+		}
+	}
+	if prefix != "" {
+		return Session{}, fmt.Errorf("%w starting at %s", ErrNotFound, prefix)
+	}
+	return Session{}, ErrNotFound
+}
@@ -0,0 +1,91 @@
+package sessions
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSaveAndList(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "sessions")
+	day := time.Date(2024, 5, 1, 18, 0, 0, 0, time.UTC)
+
+	older := Session{Start: "aaaaaaa111", End: "bbbbbbb222", Commit: "ccccccc", Saved: day}
+	newer := Session{
+		Start: "ddddddd333", End: "eeeeeee444", Commit: "fffffff",
+		Marks: map[string]string{"a": "0123456"}, Filter: "-author:bot",
+		Diff: true, Scroll: 12, Unified: true, Wrap: true, Folded: []string{"go.sum"},
+		Saved: day.Add(24 * time.Hour),
+	}
+	for _, s := range []Session{older, newer} {
+		if err := Save(dir, s); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	list, err := List(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(list) != 2 || list[0].Start != newer.Start {
+		t.Fatalf("expected newest session first, got %+v", list)
+	}
+	got := list[0]
+	if got.Marks["a"] != "0123456" || got.Filter != "-author:bot" || !got.Diff || got.Scroll != 12 ||
+		!got.Unified || !got.Wrap || len(got.Folded) != 1 || got.Folded[0] != "go.sum" {
+		t.Errorf("expected state to round-trip, got %+v", got)
+	}
+}
+
+func TestSave_ReplacesSameRange(t *testing.T) {
+	dir := t.TempDir()
+	s := Session{Start: "aaaaaaa", End: "bbbbbbb", Commit: "1111111"}
+	Save(dir, s)
+	s.Commit = "2222222"
+	Save(dir, s)
+
+	list, _ := List(dir)
+	if len(list) != 1 || list[0].Commit != "2222222" {
+		t.Errorf("expected one updated session, got %+v", list)
+	}
+}
+
+func TestList_SkipsBadFiles(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "junk.json"), []byte("{not json"), 0o644)
+	Save(dir, Session{Start: "aaaaaaa", End: "bbbbbbb"})
+
+	list, err := List(dir)
+	if err != nil || len(list) != 1 {
+		t.Errorf("expected the one valid session, got %v %v", list, err)
+	}
+}
+
+func TestFind(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	Save(dir, Session{Start: "abc0000", End: "fff0000", Saved: now.Add(-time.Hour)})
+	Save(dir, Session{Start: "def0000", End: "fff0000", Saved: now})
+
+	if s, err := Find(dir, ""); err != nil || s.Start != "def0000" {
+		t.Errorf("expected latest session, got %+v %v", s, err)
+	}
+	if s, err := Find(dir, "abc"); err != nil || s.Start != "abc0000" {
+		t.Errorf("expected session by start prefix, got %+v %v", s, err)
+	}
+	if _, err := Find(dir, "999"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+	if _, err := Find(filepath.Join(dir, "missing"), ""); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound for a missing directory, got %v", err)
+	}
+}
+
+func TestName(t *testing.T) {
+	s := Session{Start: "3f2a1bc0000", End: "9d0e4f20000"}
+	if got := s.Name(); got != "3f2a1bc..9d0e4f2" {
+		t.Errorf("expected 3f2a1bc..9d0e4f2, got %q", got)
+	}
+}
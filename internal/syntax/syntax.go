@@ -0,0 +1,381 @@
+// Package syntax is a small tokenizer for highlighting code in diffs. It
+// knows just enough about each language to pick out keywords, literals,
+// strings and comments line by line; anything it does not understand is
+// left plain.
+package syntax
+
+import (
+	"path"
+	"strings"
+)
+
+// Kind is the category of a highlighted token.
+type Kind uint8
+
+const (
+	Plain   Kind = iota
+	Keyword      // language keywords
+	Literal      // builtin types and constants: int, nil, true, None, $VAR
+	String
+	Number
+	Comment
+	Key     // YAML and JSON object keys
+	Heading // Markdown headings
+)
+
+// Span marks text[Start:End] of a line as a token of the given kind.
+type Span struct {
+	Start, End int
+	Kind       Kind
+}
+
+// State carries what a line leaves open into the next one: a block
+// comment, a multi-line string or a Markdown code fence. The zero State is
+// the start of a file.
+type State struct {
+	comment bool
+	quote   string // closing delimiter of an open multi-line string
+	fence   bool
+}
+
+// Lang describes one language.
+type Lang struct {
+	Name         string
+	keywords     map[string]bool
+	literals     map[string]bool
+	lineComments []string
+	blockComment [2]string // open and close, empty if none
+	quotes       []string  // string delimiters, longest first
+	multiline    map[string]bool
+	raw          map[string]bool // strings without backslash escapes
+	yamlKeys     bool            // highlight "key:" at the start of a line
+	jsonKeys     bool            // highlight strings followed by ':' as keys
+	vars         bool            // highlight shell $NAME and ${NAME}
+	markdown     bool
+}
+
+func words(s string) map[string]bool {
+	m := map[string]bool{}
+	for _, w := range strings.Fields(s) {
+		m[w] = true
+	}
+	return m
+}
+
+var (
+	golang = &Lang{
+		Name: "go",
+		keywords: words(`break case chan const continue default defer else fallthrough for func go
+			goto if import interface map package range return select struct switch type var`),
+		literals: words(`bool byte complex64 complex128 error float32 float64 int int8 int16 int32 int64
+			rune string uint uint8 uint16 uint32 uint64 uintptr any comparable
+			true false nil iota append cap close copy delete len make new panic print println recover`),
+		lineComments: []string{"//"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       []string{`"`, "'", "`"},
+		multiline:    map[string]bool{"`": true},
+		raw:          map[string]bool{"`": true},
+	}
+	javascript = &Lang{
+		Name: "javascript",
+		keywords: words(`async await break case catch class const continue debugger default delete do
+			else enum export extends finally for from function if implements import in instanceof
+			interface let new of private protected public readonly return static super switch this
+			throw try type typeof var void while with yield as declare namespace abstract`),
+		literals: words(`true false null undefined NaN Infinity any boolean number string symbol
+			bigint never unknown object`),
+		lineComments: []string{"//"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       []string{`"`, "'", "`"},
+		multiline:    map[string]bool{"`": true},
+	}
+	python = &Lang{
+		Name: "python",
+		keywords: words(`and as assert async await break class continue def del elif else except
+			finally for from global if import in is lambda nonlocal not or pass raise return try
+			while with yield match case`),
+		literals: words(`True False None self cls int str float bool list dict set tuple bytes
+			object len print range isinstance super`),
+		lineComments: []string{"#"},
+		quotes:       []string{`"""`, `'''`, `"`, "'"},
+		multiline:    map[string]bool{`"""`: true, `'''`: true},
+	}
+	yaml = &Lang{
+		Name:         "yaml",
+		literals:     words(`true false null yes no on off True False Null ~`),
+		lineComments: []string{"#"},
+		quotes:       []string{`"`, "'"},
+		raw:          map[string]bool{"'": true},
+		yamlKeys:     true,
+	}
+	json = &Lang{
+		Name:     "json",
+		literals: words(`true false null`),
+		quotes:   []string{`"`},
+		jsonKeys: true,
+	}
+	markdown = &Lang{Name: "markdown", markdown: true}
+	shell    = &Lang{
+		Name: "shell",
+		keywords: words(`if then else elif fi for in do done case esac while until function return
+			local export readonly declare set unset shift exit break continue source alias`),
+		literals:     words(`true false echo printf cd test read eval exec trap`),
+		lineComments: []string{"#"},
+		quotes:       []string{`"`, "'"},
+		raw:          map[string]bool{"'": true},
+		vars:         true,
+	}
+)
+
+var byExt = map[string]*Lang{
+	".go":   golang,
+	".js":   javascript,
+	".mjs":  javascript,
+	".cjs":  javascript,
+	".jsx":  javascript,
+	".ts":   javascript,
+	".tsx":  javascript,
+	".mts":  javascript,
+	".py":   python,
+	".pyi":  python,
+	".yaml": yaml,
+	".yml":  yaml,
+	".json": json,
+	".md":   markdown,
+	".sh":   shell,
+	".bash": shell,
+	".zsh":  shell,
+}
+
+// ForFile picks a language by file name, or returns nil if it is unknown.
+func ForFile(name string) *Lang {
+	if l, ok := byExt[strings.ToLower(path.Ext(name))]; ok {
+		return l
+	}
+	switch path.Base(name) {
+	case ".bashrc", ".zshrc", ".profile":
+		return shell
+	}
+	return nil
+}
+
+// Highlight tokenizes one line, picking up from and updating st.
+func (l *Lang) Highlight(line string, st *State) []Span {
+	if l.markdown {
+		return highlightMarkdown(line, st)
+	}
+	var spans []Span
+	add := func(start, end int, kind Kind) {
+		if end > start {
+			spans = append(spans, Span{start, end, kind})
+		}
+	}
+
+	i := 0
+	if st.comment {
+		end := strings.Index(line, l.blockComment[1])
+		if end < 0 {
+			add(0, len(line), Comment)
+			return spans
+		}
+		i = end + len(l.blockComment[1])
+		add(0, i, Comment)
+		st.comment = false
+	}
+	if st.quote != "" {
+		end, closed := l.closeString(line, 0, st.quote)
+		add(0, end, String)
+		if !closed {
+			return spans
+		}
+		i = end
+		st.quote = ""
+	}
+	if l.yamlKeys {
+		if start, end, ok := yamlKey(line, i); ok {
+			add(start, end, Key)
+			i = end
+		}
+	}
+
+	for i < len(line) {
+		c := line[i]
+		switch {
+		case l.lineComment(line, i):
+			add(i, len(line), Comment)
+			return spans
+
+		case l.blockComment[0] != "" && strings.HasPrefix(line[i:], l.blockComment[0]):
+			end := strings.Index(line[i+len(l.blockComment[0]):], l.blockComment[1])
+			if end < 0 {
+				add(i, len(line), Comment)
+				st.comment = true
+				return spans
+			}
+			end += i + len(l.blockComment[0]) + len(l.blockComment[1])
+			add(i, end, Comment)
+			i = end
+
+		case l.quoteAt(line, i) != "":
+			q := l.quoteAt(line, i)
+			end, closed := l.closeString(line, i+len(q), q)
+			kind := String
+			if l.jsonKeys && strings.HasPrefix(strings.TrimLeft(line[end:], " \t"), ":") {
+				kind = Key
+			}
+			add(i, end, kind)
+			if !closed && l.multiline[q] {
+				st.quote = q
+			}
+			i = end
+
+		case l.vars && c == '$':
+			end := shellVar(line, i)
+			add(i, end, Literal)
+			i = max(end, i+1)
+
+		case isDigit(c) && (i == 0 || !isIdent(line[i-1])):
+			end := i + 1
+			for end < len(line) && (isIdent(line[end]) || line[end] == '.') {
+				end++
+			}
+			add(i, end, Number)
+			i = end
+
+		case isIdent(c):
+			end := i + 1
+			for end < len(line) && isIdent(line[end]) {
+				end++
+			}
+			word := line[i:end]
+			switch {
+			case l.keywords[word]:
+				add(i, end, Keyword)
+			case l.literals[word]:
+				add(i, end, Literal)
+			}
+			i = end
+
+		default:
+			i++
+		}
+	}
+	return spans
+}
+
+// lineComment reports whether a line comment starts at i. A '#' only
+// starts one at the start of a word, so that "a#b" in shell and YAML and
+// "${#x}" stay code.
+func (l *Lang) lineComment(line string, i int) bool {
+	for _, p := range l.lineComments {
+		if strings.HasPrefix(line[i:], p) {
+			return p != "#" || i == 0 || line[i-1] == ' ' || line[i-1] == '\t'
+		}
+	}
+	return false
+}
+
+// quoteAt returns the string delimiter starting at i, if any.
+func (l *Lang) quoteAt(line string, i int) string {
+	for _, q := range l.quotes {
+		if strings.HasPrefix(line[i:], q) {
+			return q
+		}
+	}
+	return ""
+}
+
+// closeString finds the end of a string whose body starts at i. It
+// returns the index just past the closing delimiter, or the end of the
+// line if the string is still open.
+func (l *Lang) closeString(line string, i int, q string) (end int, closed bool) {
+	for i < len(line) {
+		if line[i] == '\\' && !l.raw[q] {
+			i += 2
+			continue
+		}
+		if strings.HasPrefix(line[i:], q) {
+			return i + len(q), true
+		}
+		i++
+	}
+	return len(line), false
+}
+
+// yamlKey finds a "key:" mapping key at the start of a YAML line, after
+// any indentation and list dash.
+func yamlKey(line string, i int) (start, end int, ok bool) {
+	for i < len(line) && (line[i] == ' ' || line[i] == '\t' || line[i] == '-') {
+		i++
+	}
+	start = i
+	for i < len(line) && line[i] != ':' && line[i] != '#' && line[i] != '"' && line[i] != '\'' {
+		i++
+	}
+	if i == start || i >= len(line) || line[i] != ':' {
+		return 0, 0, false
+	}
+	if i+1 < len(line) && line[i+1] != ' ' && line[i+1] != '\t' {
+		return 0, 0, false // "http://..." is a value
+	}
+	return start, i, true
+}
+
+// shellVar returns the end of a $NAME, ${...} or special $1/$? reference
+// starting at i.
+func shellVar(line string, i int) int {
+	j := i + 1
+	switch {
+	case j < len(line) && line[j] == '{':
+		if end := strings.IndexByte(line[j:], '}'); end >= 0 {
+			return j + end + 1
+		}
+		return len(line)
+	case j < len(line) && isIdent(line[j]):
+		for j < len(line) && isIdent(line[j]) {
+			j++
+		}
+		return j
+	case j < len(line) && strings.IndexByte("?#@*$!-0123456789", line[j]) >= 0:
+		return j + 1
+	}
+	return i
+}
+
+// highlightMarkdown marks headings, quotes, code fences and their
+// contents, and inline `code`.
+func highlightMarkdown(line string, st *State) []Span {
+	trimmed := strings.TrimLeft(line, " ")
+	switch {
+	case strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~"):
+		st.fence = !st.fence
+		return []Span{{0, len(line), Comment}}
+	case st.fence:
+		return []Span{{0, len(line), String}}
+	case len(line) == 0:
+		return nil
+	case strings.HasPrefix(line, "#"):
+		return []Span{{0, len(line), Heading}}
+	case strings.HasPrefix(trimmed, ">"):
+		return []Span{{0, len(line), Comment}}
+	}
+	var spans []Span
+	for i := 0; i < len(line); i++ {
+		if line[i] != '`' {
+			continue
+		}
+		end := strings.IndexByte(line[i+1:], '`')
+		if end < 0 {
+			break
+		}
+		spans = append(spans, Span{i, i + end + 2, String})
+		i += end + 1
+	}
+	return spans
+}
+
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
+
+func isIdent(c byte) bool {
+	return c == '_' || isDigit(c) || (c|0x20 >= 'a' && c|0x20 <= 'z')
+}
@@ -0,0 +1,84 @@
+package syntax
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// tokens renders the highlighted parts of a line as "kind:text" for
+// compact comparisons.
+func tokens(line string, spans []Span) string {
+	names := map[Kind]string{Keyword: "kw", Literal: "lit", String: "str", Number: "num", Comment: "com", Key: "key", Heading: "h"}
+	var parts []string
+	for _, s := range spans {
+		parts = append(parts, fmt.Sprintf("%s:%s", names[s.Kind], line[s.Start:s.End]))
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestHighlight(t *testing.T) {
+	tests := []struct {
+		file, line, want string
+	}{
+		{"a.go", `func f(n int) error { return nil } // done`, "kw:func lit:int lit:error kw:return lit:nil com:// done"},
+		{"a.go", `s := "a \"b\" // c" + x2 + 0x1F`, `str:"a \"b\" // c" num:0x1F`},
+		{"a.go", "r := `C:\\`", "str:`C:\\`"},
+		{"a.ts", `const x: number = await f('y') /* z */ ?? null`, "kw:const lit:number kw:await str:'y' com:/* z */ lit:null"},
+		{"a.py", `def f(self): return None  # why`, "kw:def lit:self kw:return lit:None com:# why"},
+		{"c.yaml", `  - name: "web" # front`, `key:name str:"web" com:# front`},
+		{"c.yml", `url: http://x.io/a:b`, "key:url"},
+		{"c.yml", `enabled: true`, "key:enabled lit:true"},
+		{"p.json", `{"port": 8080, "tls": false, "name": "x"}`, `key:"port" num:8080 key:"tls" lit:false key:"name" str:"x"`},
+		{"run.sh", `if [ -n "$HOME" ]; then echo ${X:-1} $1; fi # ok`, `kw:if str:"$HOME" kw:then lit:echo lit:${X:-1} lit:$1 kw:fi com:# ok`},
+		{"run.sh", `echo a#b 'it''s'`, `lit:echo str:'it' str:'s'`},
+		{"README.md", "## Usage", "h:## Usage"},
+		{"README.md", "Run `make` then `go test`.", "str:`make` str:`go test`"},
+		{"a.go", "x2 := y", ""},
+	}
+	for _, tt := range tests {
+		lang := ForFile(tt.file)
+		var st State
+		if got := tokens(tt.line, lang.Highlight(tt.line, &st)); got != tt.want {
+			t.Errorf("%s %q:\nexpected %s\n     got %s", tt.file, tt.line, tt.want, got)
+		}
+	}
+}
+
+func TestHighlight_CarriesStateAcrossLines(t *testing.T) {
+	tests := []struct {
+		file  string
+		lines []string
+		want  []string
+	}{
+		{"a.go", []string{"x /* start", "still */ if", "y"}, []string{"com:/* start", "com:still */ kw:if", ""}},
+		{"a.py", []string{`doc = """Open`, `still`, `done""" and x`}, []string{`str:"""Open`, "str:still", `str:done""" kw:and`}},
+		{"a.js", []string{"s = `a", "b` + 1"}, []string{"str:`a", "str:b` num:1"}},
+		{"a.md", []string{"```go", "func f()", "```", "# Title"}, []string{"com:```go", "str:func f()", "com:```", "h:# Title"}},
+	}
+	for _, tt := range tests {
+		lang := ForFile(tt.file)
+		var st State
+		for i, line := range tt.lines {
+			if got := tokens(line, lang.Highlight(line, &st)); got != tt.want[i] {
+				t.Errorf("%s line %d %q: expected %s, got %s", tt.file, i, line, tt.want[i], got)
+			}
+		}
+	}
+}
+
+func TestForFile(t *testing.T) {
+	for name, want := range map[string]string{
+		"main.go": "go", "web/app.TSX": "javascript", "x.mjs": "javascript", "setup.py": "python",
+		".github/ci.yml": "yaml", "package.json": "json", "README.md": "markdown", "build.sh": "shell",
+	} {
+		if l := ForFile(name); l == nil || l.Name != want {
+			t.Errorf("%s: expected %s, got %v", name, want, l)
+		}
+	}
+	for _, name := range []string{"Makefile", "data.bin", "noext"} {
+		if l := ForFile(name); l != nil {
+			t.Errorf("%s: expected no language, got %s", name, l.Name)
+		}
+	}
+}
@@ -0,0 +1,289 @@
+package ui
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/anuchito/replay/internal/navigator"
+)
+
+// CommitPane is a full-screen view of the current commit: the whole message
+// and its metadata. Like DiffView it is toggled on/off and scrolls.
+type CommitPane struct {
+	Active bool
+	Keys   *Keymap // for the controls line; nil for the default
+	offset int
+	hash   string       // commit shown; the scroll resets when it changes
+	lines  []styledLine // content from the last Render
+}
+
+// styledLine is a line of plain text plus the color it is drawn in. Color
+// is applied after truncation so escape codes are never cut in half.
+type styledLine struct {
+	text  string
+	style string
+}
+
+func NewCommitPane() *CommitPane {
+	return &CommitPane{}
+}
+
+func (p *CommitPane) Toggle() {
+	p.Active = !p.Active
+	p.offset = 0
+}
+
+func (p *CommitPane) visibleLines(termH int) int {
+	// same frame as DiffView: two header lines, separator and controls
+	return max(termH-4, 0)
+}
+
+func (p *CommitPane) scrollBy(n, termH int) {
+	p.offset = min(p.offset+n, len(p.lines)-p.visibleLines(termH))
+	p.offset = max(p.offset, 0)
+}
+
+func (p *CommitPane) ScrollDown(termH int)     { p.scrollBy(1, termH) }
+func (p *CommitPane) ScrollUp(termH int)       { p.scrollBy(-1, termH) }
+func (p *CommitPane) ScrollHalfDown(termH int) { p.scrollBy(p.visibleLines(termH)/2, termH) }
+func (p *CommitPane) ScrollHalfUp(termH int)   { p.scrollBy(-p.visibleLines(termH)/2, termH) }
+func (p *CommitPane) ScrollPageDown(termH int) { p.scrollBy(p.visibleLines(termH), termH) }
+func (p *CommitPane) ScrollPageUp(termH int)   { p.scrollBy(-p.visibleLines(termH), termH) }
+
+// Render clears the screen and draws the pane for commit c. parents are
+// the commit's parents, with only the hash set for those outside the range.
+func (p *CommitPane) Render(out io.Writer, termW, termH int, c navigator.Commit, parents []navigator.Commit, prog Progress) {
+	if c.Hash != p.hash {
+		p.hash, p.offset = c.Hash, 0
+	}
+	p.lines = detailLines(c, parents, time.Now(), termW)
+	p.scrollBy(0, termH)
+
+	fmt.Fprint(out, "\x1b[2J\x1b[H")
+	fmt.Fprintf(out, "%s\r\n", commitLine(c, prog, termW))
+	label := "── DETAILS "
+	fmt.Fprintf(out, "%s%s%s\r\n", styleTitle, label+strings.Repeat("─", max(termW-StringWidth(label), 0)), styleReset)
+
+	va := p.visibleLines(termH)
+	for i := 0; i < va; i++ {
+		fmt.Fprint(out, "\x1b[2K")
+		if j := p.offset + i; j < len(p.lines) {
+			l := p.lines[j]
+			text := Truncate(l.text, termW)
+			if l.style != "" {
+				text = l.style + text + styleReset
+			}
+			fmt.Fprint(out, text)
+		}
+		fmt.Fprint(out, "\r\n")
+	}
+
+	fmt.Fprintf(out, "%s\r\n", strings.Repeat("─", termW))
+	scrollInfo := ""
+	if len(p.lines) > va && va > 0 {
+		scrollInfo = fmt.Sprintf("(%d/%d) ", min(p.offset+va, len(p.lines)), len(p.lines))
+	}
+	km := p.Keys.orDefault()
+	controls := km.hints(scrollHints()...) + "  " + scrollInfo +
+		km.hints(hintFor("next", ActionNext), hintFor("prev", ActionPrev), hintFor("close", ActionToggleInfo), hintFor("quit", ActionQuit))
+	fmt.Fprintf(out, "%s\r", Truncate(controls, termW))
+}
+
+// detailLines lays out everything known about c for the detail pane:
+// author and committer with absolute and relative dates, parents, the
+// message body with simple Markdown rendered, trailers and changed files.
+func detailLines(c navigator.Commit, parents []navigator.Commit, now time.Time, width int) []styledLine {
+	var lines []styledLine
+	add := func(style, format string, args ...any) {
+		lines = append(lines, styledLine{fmt.Sprintf(format, args...), style})
+	}
+
+	add(styleEmphasis, "commit %s", c.Hash)
+	add("", "Author:     %s <%s>", c.Author, c.Email)
+	add(styleMuted, "            %s", formatDate(c.Date, now))
+	if c.Committer != "" && (c.Committer != c.Author || c.CommitterEmail != c.Email || !c.CommitDate.Equal(c.Date)) {
+		add("", "Committer:  %s <%s>", c.Committer, c.CommitterEmail)
+		add(styleMuted, "            %s", formatDate(c.CommitDate, now))
+	}
+	for i, parent := range parents {
+		label := "Parent:     "
+		if len(parents) > 1 {
+			label = fmt.Sprintf("Parent %d:   ", i+1)
+		}
+		add("", "%s%s %s", label, parent.Hash, parent.Message)
+	}
+
+	add("", "")
+	for _, l := range wrap(c.Message, width-4) {
+		add(styleEmphasis, "    %s", l)
+	}
+
+	text, trailers := splitTrailers(c.Body)
+	if text != "" {
+		add("", "")
+		for _, l := range renderMarkdown(text, width-4) {
+			l.text = "    " + l.text
+			lines = append(lines, l)
+		}
+	}
+	if len(trailers) > 0 {
+		add("", "")
+		for _, t := range trailers {
+			add(styleAccent, "    %s", t)
+		}
+	}
+
+	add("", "")
+	if len(c.Parents) > 1 {
+		add(styleEmphasis, "Files changed against parent 1 (%d)", len(c.Files))
+	} else {
+		add(styleEmphasis, "Files changed (%d)", len(c.Files))
+	}
+	for _, f := range c.Files {
+		add("", "    %s", f)
+	}
+	return lines
+}
+
+// formatDate shows an absolute and a relative date, e.g.
+// "Wed 2024-05-01 14:05 -0700 (3 days ago)".
+func formatDate(t, now time.Time) string {
+	if t.IsZero() {
+		return "unknown date"
+	}
+	return fmt.Sprintf("%s (%s)", t.Format("Mon 2006-01-02 15:04 -0700"), RelativeTime(t, now))
+}
+
+// RelativeTime describes t relative to now in the largest whole unit,
+// e.g. "5 minutes ago", "3 weeks ago".
+func RelativeTime(t, now time.Time) string {
+	d := now.Sub(t)
+	if d < 0 {
+		return "in the future"
+	}
+	units := []struct {
+		name string
+		size time.Duration
+	}{
+		{"year", 365 * 24 * time.Hour},
+		{"month", 30 * 24 * time.Hour},
+		{"week", 7 * 24 * time.Hour},
+		{"day", 24 * time.Hour},
+		{"hour", time.Hour},
+		{"minute", time.Minute},
+	}
+	for _, u := range units {
+		if n := int(d / u.size); n >= 1 {
+			if n == 1 {
+				return "1 " + u.name + " ago"
+			}
+			return fmt.Sprintf("%d %ss ago", n, u.name)
+		}
+	}
+	return "just now"
+}
+
+var trailerRe = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9-]*: \S`)
+
+// splitTrailers separates git trailers ("Signed-off-by: …") from the rest
+// of a message body. Trailers are recognized, as git does, only in the last
+// paragraph, and only if every line of it is a trailer or a continuation.
+func splitTrailers(body string) (text string, trailers []string) {
+	body = strings.TrimRight(body, "\n ")
+	start := strings.LastIndex(body, "\n\n")
+	last := body[start+1:] // the whole body if there is one paragraph
+	for _, line := range strings.Split(strings.TrimLeft(last, "\n"), "\n") {
+		switch {
+		case trailerRe.MatchString(line):
+			trailers = append(trailers, line)
+		case len(trailers) > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")):
+			trailers[len(trailers)-1] += " " + strings.TrimSpace(line)
+		default:
+			return body, nil
+		}
+	}
+	if start < 0 {
+		return "", trailers
+	}
+	return strings.TrimRight(body[:start], "\n "), trailers
+}
+
+var (
+	headingRe  = regexp.MustCompile(`^#{1,6}\s+(.*)$`)
+	bulletRe   = regexp.MustCompile(`^(\s*)[-*+]\s+(.*)$`)
+	numberedRe = regexp.MustCompile(`^(\s*)(\d+[.)])\s+(.*)$`)
+)
+
+// renderMarkdown renders the block-level Markdown commonly found in commit
+// messages: headings, bulleted and numbered lists, block quotes and fenced
+// code. Other text is word-wrapped to width.
+func renderMarkdown(text string, width int) []styledLine {
+	var lines []styledLine
+	inCode := false
+	for _, line := range strings.Split(text, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			inCode = !inCode
+			continue
+		}
+		if inCode {
+			lines = append(lines, styledLine{"  " + line, styleAccent})
+			continue
+		}
+		if m := headingRe.FindStringSubmatch(line); m != nil {
+			lines = append(lines, styledLine{m[1], styleEmphasis})
+			continue
+		}
+		if strings.HasPrefix(line, ">") {
+			for _, l := range wrap(strings.TrimSpace(strings.TrimPrefix(line, ">")), width-2) {
+				lines = append(lines, styledLine{"│ " + l, styleMuted})
+			}
+			continue
+		}
+		indent, marker, rest := "", "", line
+		if m := bulletRe.FindStringSubmatch(line); m != nil {
+			indent, marker, rest = m[1], "•", m[2]
+		} else if m := numberedRe.FindStringSubmatch(line); m != nil {
+			indent, marker, rest = m[1], m[2], m[3]
+		}
+		if marker == "" {
+			for _, l := range wrap(line, width) {
+				lines = append(lines, styledLine{l, ""})
+			}
+			continue
+		}
+		hang := strings.Repeat(" ", StringWidth(indent+marker)+1)
+		for i, l := range wrap(rest, width-len(hang)) {
+			if i == 0 {
+				lines = append(lines, styledLine{indent + marker + " " + l, ""})
+			} else {
+				lines = append(lines, styledLine{hang + l, ""})
+			}
+		}
+	}
+	return lines
+}
+
+// wrap breaks s into lines of at most width runes at spaces. Words longer
+// than width are left whole for the renderer to truncate.
+func wrap(s string, width int) []string {
+	if width <= 0 || StringWidth(s) <= width {
+		return []string{s}
+	}
+	var lines []string
+	cur := ""
+	for _, word := range strings.Fields(s) {
+		switch {
+		case cur == "":
+			cur = word
+		case StringWidth(cur)+1+StringWidth(word) <= width:
+			cur += " " + word
+		default:
+			lines = append(lines, cur)
+			cur = word
+		}
+	}
+	return append(lines, cur)
+}
@@ -0,0 +1,181 @@
+package ui
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anuchito/replay/internal/navigator"
+)
+
+func TestSplitTrailers(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		text     string
+		trailers []string
+	}{
+		{"no trailers", "Explain the change.\n\nMore detail.", "Explain the change.\n\nMore detail.", nil},
+		{
+			"trailers after text",
+			"Explain.\n\nSigned-off-by: Ana <ana@example.com>\nReviewed-by: Bo",
+			"Explain.",
+			[]string{"Signed-off-by: Ana <ana@example.com>", "Reviewed-by: Bo"},
+		},
+		{"only trailers", "Fixes: #12", "", []string{"Fixes: #12"}},
+		{
+			"continuation line",
+			"Explain.\n\nCo-authored-by: Ana\n  <ana@example.com>",
+			"Explain.",
+			[]string{"Co-authored-by: Ana <ana@example.com>"},
+		},
+		{"mixed last paragraph", "Explain.\n\nNote: this is prose\nnot a trailer block", "Explain.\n\nNote: this is prose\nnot a trailer block", nil},
+		{"empty", "", "", nil},
+	}
+	for _, tt := range tests {
+		text, trailers := splitTrailers(tt.body)
+		if text != tt.text {
+			t.Errorf("%s: expected text %q, got %q", tt.name, tt.text, text)
+		}
+		if strings.Join(trailers, "|") != strings.Join(tt.trailers, "|") {
+			t.Errorf("%s: expected trailers %q, got %q", tt.name, tt.trailers, trailers)
+		}
+	}
+}
+
+func TestRenderMarkdown(t *testing.T) {
+	text := "## Why\nThe lexer dropped tokens.\n\n- first point\n* second point\n1. numbered\n> quoted\n```\nfunc main() {}\n```"
+	lines := renderMarkdown(text, 80)
+
+	want := []styledLine{
+		{"Why", styleEmphasis},
+		{"The lexer dropped tokens.", ""},
+		{"", ""},
+		{"• first point", ""},
+		{"• second point", ""},
+		{"1. numbered", ""},
+		{"│ quoted", styleMuted},
+		{"  func main() {}", styleAccent},
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("expected %d lines, got %d: %q", len(want), len(lines), lines)
+	}
+	for i := range want {
+		if lines[i] != want[i] {
+			t.Errorf("line %d: expected %q, got %q", i, want[i], lines[i])
+		}
+	}
+}
+
+func TestRenderMarkdown_WrapsListItems(t *testing.T) {
+	lines := renderMarkdown("- one two three four", 10)
+	got := []string{}
+	for _, l := range lines {
+		got = append(got, l.text)
+	}
+	if strings.Join(got, "|") != "• one two|  three|  four" {
+		t.Errorf("expected hanging indent, got %q", got)
+	}
+}
+
+func TestRelativeTime(t *testing.T) {
+	now := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		ago  time.Duration
+		want string
+	}{
+		{10 * time.Second, "just now"},
+		{time.Minute, "1 minute ago"},
+		{5 * time.Hour, "5 hours ago"},
+		{3 * 24 * time.Hour, "3 days ago"},
+		{15 * 24 * time.Hour, "2 weeks ago"},
+		{400 * 24 * time.Hour, "1 year ago"},
+		{-time.Hour, "in the future"},
+	}
+	for _, tt := range tests {
+		if got := RelativeTime(now.Add(-tt.ago), now); got != tt.want {
+			t.Errorf("%v ago: expected %q, got %q", tt.ago, tt.want, got)
+		}
+	}
+}
+
+func detailText(lines []styledLine) string {
+	var b strings.Builder
+	for _, l := range lines {
+		b.WriteString(l.text + "\n")
+	}
+	return b.String()
+}
+
+func TestDetailLines(t *testing.T) {
+	date := time.Date(2024, 5, 1, 14, 5, 0, 0, time.UTC)
+	c := navigator.Commit{
+		Hash: "abc1234", Message: "fix lexer", Author: "Ana", Email: "ana@example.com", Date: date,
+		Committer: "Bo", CommitterEmail: "bo@example.com", CommitDate: date.Add(time.Hour),
+		Body:  "Tokens were dropped.\n\nSigned-off-by: Ana <ana@example.com>",
+		Files: []string{"parser/lex.go"},
+	}
+	parents := []navigator.Commit{{Hash: "def5678", Message: "add lexer"}}
+	out := detailText(detailLines(c, parents, date.Add(48*time.Hour), 80))
+
+	for _, want := range []string{
+		"Author:     Ana <ana@example.com>",
+		"Wed 2024-05-01 14:05 +0000 (2 days ago)",
+		"Committer:  Bo <bo@example.com>",
+		"Parent:     def5678 add lexer",
+		"    fix lexer",
+		"    Tokens were dropped.",
+		"    Signed-off-by: Ana <ana@example.com>",
+		"Files changed (1)",
+		"    parser/lex.go",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in:\n%s", want, out)
+		}
+	}
+}
+
+func TestDetailLines_MergeFiles(t *testing.T) {
+	c := navigator.Commit{Hash: "abc1234", Parents: []string{"def5678", "0123456"}, Files: []string{"side.go"}}
+	parents := []navigator.Commit{{Hash: "def5678"}, {Hash: "0123456"}}
+	out := detailText(detailLines(c, parents, time.Now(), 80))
+	if !strings.Contains(out, "Files changed against parent 1 (1)\n    side.go") {
+		t.Errorf("expected the merge's files labelled by parent, got:\n%s", out)
+	}
+}
+
+func TestDetailLines_HidesCommitterWhenSameAsAuthor(t *testing.T) {
+	date := time.Date(2024, 5, 1, 14, 5, 0, 0, time.UTC)
+	c := navigator.Commit{
+		Hash: "abc1234", Author: "Ana", Email: "a@x", Date: date,
+		Committer: "Ana", CommitterEmail: "a@x", CommitDate: date,
+	}
+	if out := detailText(detailLines(c, nil, date, 80)); strings.Contains(out, "Committer:") {
+		t.Errorf("expected no committer line, got:\n%s", out)
+	}
+}
+
+func TestCommitPane_Scroll(t *testing.T) {
+	p := NewCommitPane()
+	p.Toggle()
+	c := navigator.Commit{Hash: "abc1234", Message: "long", Body: strings.Repeat("line\n", 50)}
+
+	var buf bytes.Buffer
+	p.Render(&buf, 80, 24, c, nil, Progress{Pos: 1, Total: 1})
+	p.ScrollPageDown(24)
+	if p.offset != 20 {
+		t.Errorf("expected offset 20 after a page, got %d", p.offset)
+	}
+	for i := 0; i < 10; i++ {
+		p.ScrollPageDown(24)
+	}
+	if max := len(p.lines) - 20; p.offset != max {
+		t.Errorf("expected offset clamped to %d, got %d", max, p.offset)
+	}
+
+	p.Render(&buf, 80, 24, navigator.Commit{Hash: "def5678"}, nil, Progress{})
+	if p.offset != 0 {
+		t.Errorf("expected scroll to reset for a new commit, got %d", p.offset)
+	}
+}
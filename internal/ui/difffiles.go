@@ -0,0 +1,182 @@
+package ui
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// NextFile and PrevFile scroll the start of the next or previous file of
+// the diff to the top of the screen; NextHunk and PrevHunk do the same
+// for hunks. Hunks in folded or collapsed files are skipped.
+func (dv *DiffView) NextFile(termH int) { dv.jump(dv.fileStops(), true, termH) }
+func (dv *DiffView) PrevFile(termH int) { dv.jump(dv.fileStops(), false, termH) }
+func (dv *DiffView) NextHunk(termH int) { dv.jump(dv.hunkStops(), true, termH) }
+func (dv *DiffView) PrevHunk(termH int) { dv.jump(dv.hunkStops(), false, termH) }
+
+// jump scrolls to the first of stops below the top of the screen, or
+// with forward unset the last one above it. Past the last, it stays.
+func (dv *DiffView) jump(stops []int, forward bool, termH int) {
+	if forward {
+		for _, row := range stops {
+			if row > dv.scrollOffset {
+				dv.ScrollTo(row, termH)
+				return
+			}
+		}
+		return
+	}
+	for i := len(stops) - 1; i >= 0; i-- {
+		if stops[i] < dv.scrollOffset {
+			dv.ScrollTo(stops[i], termH)
+			return
+		}
+	}
+}
+
+// fileStops are the display rows each file starts at.
+func (dv *DiffView) fileStops() []int {
+	var stops []int
+	prev := -1
+	for i, d := range dv.layout {
+		if f := dv.fileOf[dv.rowSource(d.row)]; f != prev {
+			stops = append(stops, i)
+			prev = f
+		}
+	}
+	return stops
+}
+
+// hunkStops are the display rows of the hunk headers.
+func (dv *DiffView) hunkStops() []int {
+	var stops []int
+	for i, d := range dv.layout {
+		if d.part == 0 && strings.HasPrefix(dv.diffLines[dv.rowSource(d.row)], "@@") {
+			stops = append(stops, i)
+		}
+	}
+	return stops
+}
+
+// topFile is the index of the file at the top of the screen, -1 if the
+// diff is empty.
+func (dv *DiffView) topFile() int {
+	if len(dv.layout) == 0 {
+		return -1
+	}
+	return dv.fileOf[dv.rowSource(dv.layout[dv.scrollOffset].row)]
+}
+
+// fileRow is the display row file f starts at.
+func (dv *DiffView) fileRow(f int) int {
+	for i, d := range dv.layout {
+		if dv.fileOf[dv.rowSource(d.row)] == f {
+			return i
+		}
+	}
+	return 0
+}
+
+// fileLabel names the file at the top of the screen and its place among
+// the diff's files, e.g. " main.go (2/5) ", with the path cut to half of
+// width. It is "" before the first file.
+func (dv *DiffView) fileLabel(width int) string {
+	top := dv.topFile()
+	if top < 0 || dv.files[top].stat.path == "" {
+		return ""
+	}
+	n, at := 0, 0
+	for i, f := range dv.files {
+		if f.stat.path != "" {
+			n++
+		}
+		if i == top {
+			at = n
+		}
+	}
+	return fmt.Sprintf(" %s (%d/%d) ", Truncate(dv.files[top].stat.path, max(width/2, 1)), at, n)
+}
+
+// ToggleFold folds the file at the top of the screen down to its header
+// line, or unfolds it, and scrolls its header to the top. With the file
+// list open, it folds the file under the list's cursor instead.
+func (dv *DiffView) ToggleFold(termH int) {
+	f := dv.topFile()
+	if dv.listing {
+		f = dv.listCursor
+	}
+	if f < 0 {
+		return
+	}
+	dv.folded[f] = !dv.folded[f]
+	dv.relayout(dv.laidOut.width)
+	if !dv.listing {
+		dv.ScrollTo(dv.fileRow(f), termH)
+	}
+}
+
+// FoldedFiles returns the paths of the folded files.
+func (dv *DiffView) FoldedFiles() []string {
+	var paths []string
+	for i, f := range dv.files {
+		if dv.folded[i] && f.stat.path != "" {
+			paths = append(paths, f.stat.path)
+		}
+	}
+	return paths
+}
+
+// FoldFiles folds the files with the paths given, as FoldedFiles returns
+// them.
+func (dv *DiffView) FoldFiles(paths []string) {
+	for i, f := range dv.files {
+		dv.folded[i] = f.stat.path != "" && slices.Contains(paths, f.stat.path)
+	}
+	dv.relayout(dv.laidOut.width)
+}
+
+// ToggleFiles opens the list of the diff's files over it, its cursor on
+// the file at the top of the screen, or closes the list.
+func (dv *DiffView) ToggleFiles() {
+	if !dv.listing && len(dv.files) == 0 {
+		return
+	}
+	dv.listing = !dv.listing
+	dv.listCursor = max(dv.topFile(), 0)
+}
+
+// Listing reports whether the file list is open.
+func (dv *DiffView) Listing() bool { return dv.listing }
+
+// OpenListed closes the file list and scrolls the file under its cursor
+// to the top of the screen.
+func (dv *DiffView) OpenListed(termH int) {
+	dv.listing = false
+	dv.ScrollTo(dv.fileRow(dv.listCursor), termH)
+}
+
+// listLines draws the file list in height rows of width columns: a line
+// of stats for each file, scrolled to keep the cursor in view. Folded
+// files are marked.
+func (dv *DiffView) listLines(width, height int) []string {
+	dv.listOffset = min(dv.listOffset, dv.listCursor)
+	dv.listOffset = max(dv.listOffset, dv.listCursor-height+1)
+	stats := make([]fileStat, len(dv.files))
+	for i, f := range dv.files {
+		stats[i] = f.stat
+	}
+	var lines []string
+	for i := dv.listOffset; i < min(dv.listOffset+height, len(dv.files)); i++ {
+		marker := "  "
+		if i == dv.listCursor {
+			marker = "> "
+		}
+		if dv.folded[i] {
+			marker += styleMuted + "▸" + styleReset
+		} else {
+			marker += " "
+		}
+		lines = append(lines, marker+statLine(stats[i], stats, max(width-3, 0)))
+	}
+	return lines
+}
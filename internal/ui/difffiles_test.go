@@ -0,0 +1,145 @@
+package ui
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/anuchito/replay/internal/navigator"
+)
+
+// filesDiff has three files: a.go with two hunks, then gen.go and b.go
+// with one long hunk each.
+func filesDiff() []string {
+	diff := []string{
+		"diff --git a/a.go b/a.go", "--- a/a.go", "+++ b/a.go",
+		"@@ -1,2 +1,2 @@", "-one", "+uno",
+		"@@ -9,2 +9,2 @@", "-nine", "+nueve",
+		"diff --git a/gen.go b/gen.go", "--- /dev/null", "+++ b/gen.go",
+		"@@ -0,0 +1,30 @@",
+	}
+	for i := range 30 {
+		diff = append(diff, fmt.Sprintf("+gen %d", i))
+	}
+	diff = append(diff, "diff --git a/b.go b/b.go", "--- a/b.go", "+++ b/b.go", "@@ -1,20 +1,20 @@", "-x", "+y")
+	for i := range 19 {
+		diff = append(diff, fmt.Sprintf(" b %d", i))
+	}
+	return diff
+}
+
+// renderHeader renders dv and returns its NEXT line without colors.
+func renderHeader(dv *DiffView, termW, termH int) string {
+	var buf bytes.Buffer
+	dv.Render(&buf, termW, termH, navigator.Commit{}, navigator.Commit{Hash: "abc1234", Message: "msg"}, true, Progress{Pos: 1, Total: 2})
+	return strings.Split(ansiRe.ReplaceAllString(buf.String(), ""), "\r\n")[1]
+}
+
+func TestDiffView_FileAndHunkJumps(t *testing.T) {
+	dv := NewDiffView()
+	dv.SetDiff(filesDiff())
+	renderDiff(dv, 80, 14)
+
+	dv.NextHunk(14)
+	if dv.Offset() != 3 {
+		t.Errorf("expected the first hunk on top, got offset %d", dv.Offset())
+	}
+	dv.NextHunk(14)
+	dv.NextFile(14)
+	if rows := renderDiff(dv, 80, 14); rows[0] != "diff --git a/gen.go b/gen.go" {
+		t.Errorf("expected gen.go on top, got %q", rows[0])
+	}
+	if got := renderHeader(dv, 80, 14); !strings.HasSuffix(got, "── gen.go (2/3) ──") || StringWidth(got) != 80 {
+		t.Errorf("expected the file on top named in the header, got %q", got)
+	}
+
+	// Past the last file, the jump stays; back from it goes to gen.go.
+	dv.NextFile(14)
+	dv.NextFile(14)
+	if dv.Offset() != 43 {
+		t.Errorf("expected b.go on top, got offset %d", dv.Offset())
+	}
+	dv.PrevFile(14)
+	dv.PrevHunk(14)
+	if dv.Offset() != 6 {
+		t.Errorf("expected a.go's second hunk on top, got offset %d", dv.Offset())
+	}
+}
+
+func TestDiffView_Fold(t *testing.T) {
+	dv := NewDiffView()
+	dv.SetDiff(filesDiff())
+	renderDiff(dv, 80, 14)
+	dv.NextFile(14)
+	dv.ScrollDown(14) // inside gen.go
+
+	dv.ToggleFold(14)
+	rows := renderDiff(dv, 80, 14)
+	if rows[0] != "diff --git a/gen.go b/gen.go" || rows[1] != "⋯ 33 lines folded  za unfolds" || rows[2] != "diff --git a/b.go b/b.go" || dv.Offset() != 9 {
+		t.Errorf("expected gen.go folded to its header on top, got %q", rows[:3])
+	}
+	dv.NextHunk(14)
+	if rows := renderDiff(dv, 80, 14); rows[0] != "@@ -1,20 +1,20 @@" {
+		t.Errorf("expected the folded file's hunk skipped, got %q", rows[0])
+	}
+
+	dv.PrevFile(14) // b.go's start
+	dv.PrevFile(14)
+	dv.ToggleFold(14)
+	if rows := renderDiff(dv, 80, 14); rows[0] != "diff --git a/gen.go b/gen.go" || rows[4] != "+gen 0" {
+		t.Errorf("expected gen.go unfolded, got %q", rows[:5])
+	}
+}
+
+func TestDiffView_RestoreFoldsAndScroll(t *testing.T) {
+	dv := NewDiffView()
+	dv.SetDiff(filesDiff())
+	renderDiff(dv, 80, 14)
+	dv.NextFile(14)
+	dv.ToggleFold(14)
+	dv.NextFile(14)
+	dv.ScrollDown(14)
+	folded, offset := dv.FoldedFiles(), dv.Offset()
+	if len(folded) != 1 || folded[0] != "gen.go" {
+		t.Fatalf("expected gen.go folded, got %v", folded)
+	}
+
+	// As a resumed session does it: before the first Render.
+	restored := NewDiffView()
+	restored.SetDiff(filesDiff())
+	restored.FoldFiles(folded)
+	restored.Layout(80)
+	restored.ScrollTo(offset, 14)
+	if got, want := renderDiff(restored, 80, 14), renderDiff(dv, 80, 14); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("expected the same screen restored, got %q, want %q", got[:3], want[:3])
+	}
+}
+
+func TestDiffView_FileList(t *testing.T) {
+	dv := NewDiffView()
+	dv.SetDiff(filesDiff())
+	renderDiff(dv, 80, 14)
+	dv.NextFile(14)
+
+	dv.ToggleFiles()
+	dv.ToggleFold(14)
+	dv.ScrollDown(14)
+	rows := renderDiff(dv, 80, 14)
+	want := []string{"    a.go   |  4 ++--", "  ▸ gen.go | 30 ++++++++++++++++++++++++++++++", ">   b.go   |  2 +-"}
+	for i, w := range want {
+		if rows[i] != w {
+			t.Errorf("row %d: expected %q, got %q", i, w, rows[i])
+		}
+	}
+	if dv.Offset() != 9 {
+		t.Errorf("expected the list to leave the diff's scroll alone, got %d", dv.Offset())
+	}
+
+	dv.ScrollUp(14)
+	dv.ScrollUp(14)
+	dv.OpenListed(14)
+	if dv.Listing() || dv.Offset() != 0 {
+		t.Errorf("expected a.go on top with the list closed, got offset %d", dv.Offset())
+	}
+}
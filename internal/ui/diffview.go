@@ -6,38 +6,89 @@ import (
 	"strings"
 
 	"github.com/anuchito/replay/internal/navigator"
-)
-
-const (
-	colorReset = "\x1b[0m"
-	colorRed   = "\x1b[31m"
-	colorGreen = "\x1b[32m"
-	colorCyan  = "\x1b[36m"
-	colorBold  = "\x1b[1m"
-	colorDim   = "\x1b[2m"
+	"github.com/anuchito/replay/internal/syntax"
 )
 
 // DiffView renders a full-screen view of the next commit's diff.
 // It is toggled on/off with Toggle() and renders via Render().
+//
+// With Split set, terminals at least SplitWidth columns wide show the diff
+// side by side, old on the left and new on the right; narrower ones get
+// the unified diff. With Wrap set, long lines wrap onto continuation rows
+// instead of being cut off, and scrolling counts screen rows.
+//
+// The diff can be walked a file or a hunk at a time, its files folded to
+// their header line, and a list of them opened over it to pick one from;
+// the header names the file at the top of the screen.
 type DiffView struct {
 	Active       bool
-	scrollOffset int
+	Split        bool
+	SplitWidth   int
+	Wrap         bool
+	Keys         *Keymap // for the controls line; nil for the default
+	scrollOffset int     // first display row shown
 	diffLines    []string
+	spans        [][]syntax.Span // syntax highlighting of diffLines
+	sideRows     []sideRow       // diffLines laid out side by side
+	numW         int             // line number gutter width for sideRows
+	split        bool            // layout of the last Render
+	hOffset      int             // columns scrolled to the right
+	longest      int             // width of the longest diff line
+
+	layout    []displayRow // screen rows for the current layout
+	laidOut   layoutKey    // what layout was computed for
+	fileOf    []int        // file index of each diff line
+	files     []diffFile
+	collapsed []bool // diff lines hidden by the wrap mode's collapse guard
+	folded    []bool // files shown as their header line only
+
+	listing    bool // the file list is shown instead of the diff
+	listCursor int  // file under the list's cursor
+	listOffset int  // first file shown in the list
+}
+
+// layoutKey identifies a layout; it is redone when any of these change.
+type layoutKey struct {
+	width       int
+	split, wrap bool
 }
 
 func NewDiffView() *DiffView {
-	return &DiffView{}
+	return &DiffView{Split: true, SplitWidth: DefaultSplitWidth}
 }
 
 func (dv *DiffView) Toggle() {
 	dv.Active = !dv.Active
-	dv.scrollOffset = 0
+	dv.scrollOffset, dv.hOffset = 0, 0
+	dv.listing = false
 }
 
 // SetDiff replaces the cached diff and resets scroll to top.
 func (dv *DiffView) SetDiff(lines []string) {
 	dv.diffLines = lines
-	dv.scrollOffset = 0
+	dv.spans = highlightDiff(lines)
+	dv.sideRows = splitDiff(lines)
+	dv.numW = gutterWidth(dv.sideRows)
+	dv.scrollOffset, dv.hOffset = 0, 0
+	dv.listing = false
+	dv.longest = 0
+	for _, l := range lines {
+		dv.longest = max(dv.longest, StringWidth(expandTabs(l, tabWidth)))
+	}
+	dv.scanFiles(lines)
+	dv.layout = nil
+	dv.relayout(dv.laidOut.width)
+}
+
+// SplitFits reports whether a terminal termW columns wide is wide enough
+// for the side-by-side layout.
+func (dv *DiffView) SplitFits(termW int) bool {
+	return termW >= dv.SplitWidth
+}
+
+// rows is the number of display rows in the current layout.
+func (dv *DiffView) rows() int {
+	return len(dv.layout)
 }
 
 func (dv *DiffView) visibleLines(termH int) int {
@@ -49,9 +100,15 @@ func (dv *DiffView) visibleLines(termH int) int {
 	return v
 }
 
+// scrollBy scrolls n rows, or moves the file list's cursor n files when
+// the list is open.
 func (dv *DiffView) scrollBy(n, termH int) {
+	if dv.listing {
+		dv.listCursor = max(min(dv.listCursor+n, len(dv.files)-1), 0)
+		return
+	}
 	va := dv.visibleLines(termH)
-	max := len(dv.diffLines) - va
+	max := dv.rows() - va
 	if max < 0 {
 		max = 0
 	}
@@ -69,46 +126,87 @@ func (dv *DiffView) ScrollUp(termH int)       { dv.scrollBy(-1, termH) }
 func (dv *DiffView) ScrollHalfDown(termH int) { dv.scrollBy(dv.visibleLines(termH)/2, termH) }
 func (dv *DiffView) ScrollHalfUp(termH int)   { dv.scrollBy(-dv.visibleLines(termH)/2, termH) }
 func (dv *DiffView) ScrollPageDown(termH int) { dv.scrollBy(dv.visibleLines(termH), termH) }
+func (dv *DiffView) ScrollPageUp(termH int)   { dv.scrollBy(-dv.visibleLines(termH), termH) }
+
+// ScrollRight and ScrollLeft scroll long lines sideways by hScrollStep
+// columns. Hunk lines keep their +/- marker and line numbers in place.
+func (dv *DiffView) ScrollRight() {
+	dv.hOffset = min(dv.hOffset+hScrollStep, max(dv.longest-hScrollStep, 0))
+}
+
+func (dv *DiffView) ScrollLeft() {
+	dv.hOffset = max(dv.hOffset-hScrollStep, 0)
+}
+
+// Offset returns the index of the first display row shown.
+func (dv *DiffView) Offset() int { return dv.scrollOffset }
+
+// ScrollTo scrolls so that row offset is at the top, within bounds.
+func (dv *DiffView) ScrollTo(offset, termH int) {
+	dv.scrollOffset = 0
+	dv.scrollBy(offset, termH)
+}
+
+// Layout lays the diff out for a terminal termW columns wide, as Render
+// does, so that it can be scrolled before it is first drawn.
+func (dv *DiffView) Layout(termW int) {
+	if (layoutKey{termW, dv.Split && dv.SplitFits(termW), dv.Wrap}) != dv.laidOut {
+		dv.relayout(termW)
+	}
+}
 
 // Render clears the screen and draws the full-screen detail view.
 // termW and termH are the current terminal dimensions.
-func (dv *DiffView) Render(out io.Writer, termW, termH int, cur, next navigator.Commit, hasNext bool, pos, total int) {
+func (dv *DiffView) Render(out io.Writer, termW, termH int, cur, next navigator.Commit, hasNext bool, prog Progress) {
+	dv.Layout(termW)
+	dv.scrollBy(0, termH)
 	// Clear screen, cursor home
 	fmt.Fprint(out, "\x1b[2J\x1b[H")
 
 	// Line 1: current commit
-	curLine := fmt.Sprintf("[%d/%d] %s  %s", pos, total, cur.Hash, cur.Message)
-	fmt.Fprintf(out, "%s\r\n", limitWidth(curLine, termW))
+	fmt.Fprintf(out, "%s\r\n", commitLine(cur, prog, termW))
 
 	// Line 2: next commit header
 	if hasNext {
-		label := fmt.Sprintf(" NEXT [%d/%d] %s  %s ", pos+1, total, next.Hash, next.Message)
-		pad := termW - len(label) - 2 // 2 for leading "──"
+		label := fmt.Sprintf(" NEXT [%d/%d] %s  %s ", prog.Pos+1, prog.Total, next.Hash, next.Message)
+		file := dv.fileLabel(termW) // the file on screen, on the right
+		if file != "" {
+			file += "──"
+			label = Truncate(label, max(termW-StringWidth(file)-4, 0))
+		}
+		pad := termW - StringWidth(label) - StringWidth(file) - 2 // 2 for leading "──"
 		if pad < 0 {
 			pad = 0
 		}
-		header := "──" + label + strings.Repeat("─", pad)
-		fmt.Fprintf(out, "%s%s%s\r\n", colorCyan+colorBold, limitWidth(header, termW), colorReset)
+		header := "──" + label + strings.Repeat("─", pad) + file
+		fmt.Fprintf(out, "%s%s%s\r\n", styleTitle, Truncate(header, termW), styleReset)
 	} else {
 		label := "── NEXT ── (end of range)"
-		pad := termW - len(label)
+		pad := termW - StringWidth(label)
 		if pad > 0 {
 			label += strings.Repeat("─", pad)
 		}
-		fmt.Fprintf(out, "%s%s%s\r\n", colorDim, limitWidth(label, termW), colorReset)
+		fmt.Fprintf(out, "%s%s%s\r\n", styleMuted, Truncate(label, termW), styleReset)
 	}
 
 	// Diff area
 	va := dv.visibleLines(termH)
 	end := dv.scrollOffset + va
-	if end > len(dv.diffLines) {
-		end = len(dv.diffLines)
+	if end > dv.rows() {
+		end = dv.rows()
 	}
 
+	var rows []string
+	if dv.listing {
+		rows = dv.listLines(termW, va)
+	} else {
+		for i := dv.scrollOffset; i < end; i++ {
+			rows = append(rows, dv.renderRow(dv.layout[i], termW))
+		}
+	}
 	rendered := 0
-	for i := dv.scrollOffset; i < end; i++ {
-		raw := limitWidth(dv.diffLines[i], termW)
-		fmt.Fprintf(out, "\x1b[2K%s\r\n", colorizeDiffLine(raw))
+	for _, row := range rows {
+		fmt.Fprintf(out, "\x1b[2K%s\r\n", row)
 		rendered++
 	}
 	// Fill any remaining lines in the diff area with blank cleared lines
@@ -122,40 +220,90 @@ func (dv *DiffView) Render(out io.Writer, termW, termH int, cur, next navigator.
 
 	// Controls / status bar
 	scrollInfo := ""
-	if len(dv.diffLines) > va && va > 0 {
+	if dv.rows() > va && va > 0 {
 		shown := dv.scrollOffset + va
-		if shown > len(dv.diffLines) {
-			shown = len(dv.diffLines)
+		if shown > dv.rows() {
+			shown = dv.rows()
 		}
-		scrollInfo = fmt.Sprintf("(%d/%d) ", shown, len(dv.diffLines))
+		scrollInfo = fmt.Sprintf("(%d/%d) ", shown, dv.rows())
+	}
+	km := dv.Keys.orDefault()
+	if dv.listing {
+		controls := km.hints(hintFor("", ActionScrollDown), hintFor("", ActionScrollUp),
+			hintFor("go to file", ActionSelect), hintFor("fold", ActionToggleFold), hintFor("close", ActionFileList, ActionQuit))
+		fmt.Fprintf(out, "%s\r", Truncate(controls, termW))
+		return
+	}
+	sideways := []hint{hintFor("", ActionScrollLeft), hintFor("", ActionScrollRight), hintFor("wrap", ActionToggleWrap)}
+	if dv.Wrap {
+		sideways = []hint{hintFor("unwrap", ActionToggleWrap)}
+	} else if dv.hOffset > 0 {
+		scrollInfo += fmt.Sprintf("→%d ", dv.hOffset)
+	}
+	layout := "split"
+	if dv.split {
+		layout = "unified"
+	}
+	controls := km.hints(append(scrollHints(), sideways...)...) + "  " + scrollInfo +
+		km.hints(hintFor("next", ActionNext), hintFor("prev", ActionPrev), hintFor(layout, ActionToggleSplit),
+			hintFor("details:off", ActionToggleDiff), hintFor("quit", ActionQuit),
+			hintFor("file", ActionNextFile, ActionPrevFile), hintFor("hunk", ActionNextHunk, ActionPrevHunk), hintFor("files", ActionFileList))
+	fmt.Fprintf(out, "%s\r", Truncate(controls, termW))
+}
+
+// scrollHints are the controls line's vertical scrolling keys.
+func scrollHints() []hint {
+	return []hint{
+		hintFor("", ActionScrollDown), hintFor("", ActionScrollUp),
+		hintFor("⇟", ActionScrollHalfDown, ActionScrollPageDown), hintFor("⇞", ActionScrollHalfUp, ActionScrollPageUp),
 	}
-	controls := fmt.Sprintf("j↓ k↑  ^D/spc ⇟  ^U ⇞  %s n next  p prev  d details:off  q quit", scrollInfo)
-	fmt.Fprintf(out, "%s\r", limitWidth(controls, termW))
 }
 
-// colorizeDiffLine applies ANSI color to a raw diff line.
-// The line must already be width-limited so color codes don't interfere with truncation.
-func colorizeDiffLine(line string) string {
+// commitLine is the top line of the full-screen views: position, hash and
+// subject, with search matches highlighted.
+func commitLine(c navigator.Commit, prog Progress, termW int) string {
+	prefix := fmt.Sprintf("[%s] %s  ", prog, c.Hash)
+	line := Truncate(prefix+c.Message, termW)
+	if len(line) > len(prefix) {
+		line = prefix + highlight(line[len(prefix):], prog.Highlight)
+	}
+	return line
+}
+
+// diffLineColor picks the theme's style for a raw diff line.
+func diffLineColor(line string) string {
 	switch {
-	case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"):
-		return colorBold + line + colorReset
+	case isFileHeader(line):
+		return styleFileHeader
 	case strings.HasPrefix(line, "+"):
-		return colorGreen + line + colorReset
+		return styleAdded
 	case strings.HasPrefix(line, "-"):
-		return colorRed + line + colorReset
+		return styleRemoved
 	case strings.HasPrefix(line, "@@"):
-		return colorCyan + line + colorReset
+		return styleHunk
 	case strings.HasPrefix(line, "diff "), strings.HasPrefix(line, "index "):
-		return colorBold + line + colorReset
+		return styleFileHeader
 	default:
-		return line
+		return ""
 	}
 }
 
-// limitWidth truncates s to at most maxW bytes (not runes — good enough for ASCII diff output).
-func limitWidth(s string, maxW int) string {
-	if maxW <= 0 || len(s) <= maxW {
-		return s
+// isFileHeader reports whether line names the old or new file of a diff.
+func isFileHeader(line string) bool {
+	return strings.HasPrefix(line, "+++") || strings.HasPrefix(line, "---")
+}
+
+// renderDiffLine draws a unified diff line in termW columns, scrolled skip
+// columns to the right. Hunk lines keep their marker in place while the
+// code scrolls, with syntax highlighting (if spans is non-nil) layered
+// over the diff color; the color is applied after cutting the line to
+// size so that escape codes are never cut in half.
+func renderDiffLine(line string, spans []syntax.Span, termW, skip int) string {
+	color := diffLineColor(line)
+	if line == "" || strings.IndexByte(" +-", line[0]) < 0 || isFileHeader(line) || termW <= 1 {
+		text, _ := paint(line, nil, color, skip, termW, tabWidth)
+		return text
 	}
-	return s[:maxW]
+	text, _ := paint(line[1:], spans, color, skip, termW-1, tabWidth)
+	return color + line[:1] + text
 }
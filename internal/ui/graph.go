@@ -0,0 +1,82 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/anuchito/replay/internal/navigator"
+)
+
+// GraphChoice selects which side of the graph gets numbered [1], [2]… labels.
+type GraphChoice int
+
+const (
+	ChooseNone GraphChoice = iota
+	ChooseParent
+	ChooseChild
+)
+
+// GraphLines draws the neighbourhood of cur: its in-range parents above
+// (oldest lane first) and its in-range children below, one lane per line
+// of history:
+//
+//	○     1a2b3c4 fix foo          [1]
+//	│ ○   5d6e7f8 add bar          [2]
+//	├─╯
+//	●     abc1234 merge branch x
+func GraphLines(parents []navigator.Commit, cur navigator.Commit, children []navigator.Commit, choose GraphChoice) []string {
+	var lines []string
+	lanes := func(n int) int { // width of the lane column
+		if n < 1 {
+			n = 1
+		}
+		return 2*n + 1
+	}
+
+	w := lanes(len(parents))
+	if cw := lanes(len(children)); cw > w {
+		w = cw
+	}
+
+	for i, c := range parents {
+		cells := strings.Repeat("│ ", i) + "○"
+		lines = append(lines, graphEntry(cells, w, c, choose == ChooseParent, i))
+	}
+	if len(parents) > 1 {
+		lines = append(lines, "├"+strings.Repeat("─┴", len(parents)-2)+"─╯")
+	} else if len(parents) == 1 {
+		lines = append(lines, "│")
+	}
+
+	lines = append(lines, graphEntry("●", w, cur, false, 0))
+
+	if len(children) > 1 {
+		lines = append(lines, "├"+strings.Repeat("─┬", len(children)-2)+"─╮")
+	} else if len(children) == 1 {
+		lines = append(lines, "│")
+	}
+	for i, c := range children {
+		cells := strings.Repeat("  ", i) + "○" + strings.Repeat(" │", len(children)-i-1)
+		lines = append(lines, graphEntry(cells, w, c, choose == ChooseChild, i))
+	}
+	return lines
+}
+
+func graphEntry(cells string, width int, c navigator.Commit, numbered bool, i int) string {
+	pad := width - len([]rune(cells))
+	if pad < 1 {
+		pad = 1
+	}
+	line := fmt.Sprintf("%s%s%s %s", cells, strings.Repeat(" ", pad), c.Hash, c.Message)
+	if numbered {
+		line += fmt.Sprintf("  %s[%d]%s", styleEmphasis, i+1, styleReset)
+	}
+	return line
+}
+
+// PrintGraph writes GraphLines in append mode.
+func (u *UI) PrintGraph(parents []navigator.Commit, cur navigator.Commit, children []navigator.Commit, choose GraphChoice) {
+	for _, line := range GraphLines(parents, cur, children, choose) {
+		fmt.Fprintf(u.out, "  %s\r\n", line)
+	}
+}
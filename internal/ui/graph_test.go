@@ -0,0 +1,55 @@
+package ui
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/anuchito/replay/internal/navigator"
+)
+
+func TestGraphLines_Merge(t *testing.T) {
+	parents := []navigator.Commit{
+		{Hash: "1a2b3c4", Message: "fix foo"},
+		{Hash: "5d6e7f8", Message: "add bar"},
+	}
+	cur := navigator.Commit{Hash: "abc1234", Message: "merge branch x"}
+
+	lines := GraphLines(parents, cur, nil, ChooseParent)
+	if len(lines) != 4 {
+		t.Fatalf("expected 4 lines (2 parents, join, current), got %d: %q", len(lines), lines)
+	}
+	if !strings.HasPrefix(lines[1], "│ ○") {
+		t.Errorf("expected second parent on its own lane, got %q", lines[1])
+	}
+	if lines[2] != "├─╯" {
+		t.Errorf("expected join line, got %q", lines[2])
+	}
+	if !strings.HasPrefix(lines[3], "●") || !strings.Contains(lines[3], "abc1234") {
+		t.Errorf("expected current commit marker, got %q", lines[3])
+	}
+	if !strings.Contains(lines[0], "[1]") || !strings.Contains(lines[1], "[2]") {
+		t.Errorf("expected numbered parent choices, got %q", lines[:2])
+	}
+}
+
+func TestGraphLines_Fork(t *testing.T) {
+	cur := navigator.Commit{Hash: "abc1234", Message: "base"}
+	children := []navigator.Commit{
+		{Hash: "9a8b7c6", Message: "mainline"},
+		{Hash: "1234567", Message: "feature"},
+	}
+
+	lines := GraphLines(nil, cur, children, ChooseNone)
+	if len(lines) != 4 {
+		t.Fatalf("expected 4 lines (current, split, 2 children), got %d: %q", len(lines), lines)
+	}
+	if lines[1] != "├─╮" {
+		t.Errorf("expected split line, got %q", lines[1])
+	}
+	if !strings.HasPrefix(lines[2], "○ │") || !strings.HasPrefix(lines[3], "  ○") {
+		t.Errorf("expected children on separate lanes, got %q", lines[2:])
+	}
+	if strings.Contains(strings.Join(lines, ""), "[1]") {
+		t.Error("expected no choice labels when not choosing")
+	}
+}
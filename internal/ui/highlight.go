@@ -0,0 +1,128 @@
+package ui
+
+import (
+	"strings"
+
+	"github.com/anuchito/replay/internal/syntax"
+)
+
+// highlightDiff tokenizes the code in a diff's hunks, returning spans for
+// each line (relative to the text after the diff marker). Lines outside
+// hunks, and files in languages syntax doesn't know, get nil. Removed and
+// added lines each continue their own side's state, so a comment opened
+// on one side doesn't spill into the other.
+func highlightDiff(lines []string) [][]syntax.Span {
+	spans := make([][]syntax.Span, len(lines))
+	var lang *syntax.Lang
+	var oldSt, newSt syntax.State
+	inHunk := false
+	for i, line := range lines {
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			lang, inHunk = nil, false
+			if j := strings.LastIndex(line, " b/"); j >= 0 {
+				lang = syntax.ForFile(line[j+3:])
+			}
+			continue
+		case strings.HasPrefix(line, "diff "): // combined diff of a merge
+			lang, inHunk = nil, false
+			continue
+		case strings.HasPrefix(line, "@@ "):
+			inHunk = lang != nil
+			oldSt, newSt = syntax.State{}, syntax.State{}
+			continue
+		case !inHunk || line == "":
+			continue
+		}
+		switch line[0] {
+		case ' ':
+			spans[i] = lang.Highlight(line[1:], &newSt)
+			oldSt = newSt
+		case '-':
+			spans[i] = lang.Highlight(line[1:], &oldSt)
+		case '+':
+			spans[i] = lang.Highlight(line[1:], &newSt)
+		}
+	}
+	return spans
+}
+
+// diffColor is the color of a hunk line with the given marker.
+func diffColor(marker byte) string {
+	switch marker {
+	case '-':
+		return styleRemoved
+	case '+':
+		return styleAdded
+	}
+	return ""
+}
+
+// paint colors text with base, switching to the token color inside spans.
+// It drops the first skip columns (for horizontal scrolling), cuts the
+// rest off at width columns and returns the columns used. Tabs are
+// expanded to the next multiple of tabW; a wide character cut by the left
+// edge is replaced by spaces.
+func paint(text string, spans []syntax.Span, base string, skip, width, tabW int) (string, int) {
+	var b strings.Builder
+	b.WriteString(base)
+	col, used, si, inSpan := 0, 0, 0, false
+loop:
+	for i := 0; i < len(text); {
+		if inSpan && i >= spans[si].End {
+			b.WriteString(styleReset + base)
+			inSpan = false
+		}
+		for si < len(spans) && i >= spans[si].End {
+			si++
+		}
+		if !inSpan && si < len(spans) && i >= spans[si].Start {
+			b.WriteString(syntaxColors[spans[si].Kind])
+			inSpan = true
+		}
+
+		n, w := nextCluster(text[i:])
+		cluster := text[i : i+n]
+		if text[i] == '\t' {
+			w = tabW - col%tabW
+			cluster = strings.Repeat(" ", w)
+		}
+		switch {
+		case col >= skip:
+			if used+w > width {
+				break loop
+			}
+			b.WriteString(cluster)
+			used += w
+		case col+w > skip: // straddles the left edge
+			part := min(col+w-skip, width-used)
+			b.WriteString(strings.Repeat(" ", part))
+			used += part
+		}
+		col += w
+		i += n
+	}
+	b.WriteString(styleReset)
+	return b.String(), used
+}
+
+// expandTabs replaces tabs with spaces up to the next multiple of tabW.
+func expandTabs(s string, tabW int) string {
+	if !strings.Contains(s, "\t") {
+		return s
+	}
+	var b strings.Builder
+	col := 0
+	for i := 0; i < len(s); {
+		n, w := nextCluster(s[i:])
+		if s[i] == '\t' {
+			w = tabW - col%tabW
+			b.WriteString(strings.Repeat(" ", w))
+		} else {
+			b.WriteString(s[i : i+n])
+		}
+		col += w
+		i += n
+	}
+	return b.String()
+}
@@ -0,0 +1,87 @@
+package ui
+
+import (
+	"testing"
+
+	"github.com/anuchito/replay/internal/syntax"
+)
+
+func TestHighlightDiff(t *testing.T) {
+	lines := []string{
+		"diff --git a/main.go b/main.go",
+		"--- a/main.go",
+		"+++ b/main.go",
+		"@@ -1,2 +1,3 @@",
+		" func main() {",
+		"-\t/* old",
+		"+\treturn",
+		"+}",
+		"diff --git a/data.bin b/data.bin",
+		"@@ -1 +1 @@",
+		"-if x",
+	}
+	spans := highlightDiff(lines)
+
+	for _, i := range []int{0, 1, 2, 3, 8, 9, 10} {
+		if spans[i] != nil {
+			t.Errorf("line %d %q: expected no highlighting, got %v", i, lines[i], spans[i])
+		}
+	}
+	if len(spans[4]) != 1 || spans[4][0] != (syntax.Span{Start: 0, End: 4, Kind: syntax.Keyword}) {
+		t.Errorf("expected 'func' keyword, got %v", spans[4])
+	}
+	// the comment opened on the removed line must not swallow added lines
+	if len(spans[6]) != 1 || spans[6][0].Kind != syntax.Keyword {
+		t.Errorf("expected 'return' keyword on the added line, got %v", spans[6])
+	}
+}
+
+func TestPaint(t *testing.T) {
+	spans := []syntax.Span{{Start: 0, End: 2, Kind: syntax.Keyword}, {Start: 3, End: 4, Kind: syntax.Number}}
+	got, cols := paint("if 1 x", spans, styleAdded, 0, 80, 8)
+	want := styleAdded + syntaxColors[syntax.Keyword] + "if" + styleReset + styleAdded + " " +
+		syntaxColors[syntax.Number] + "1" + styleReset + styleAdded + " x" + styleReset
+	if got != want || cols != 6 {
+		t.Errorf("expected %q (6 cols), got %q (%d)", want, got, cols)
+	}
+}
+
+func TestPaint_TruncatesAndExpandsTabs(t *testing.T) {
+	if got, cols := paint("a\tbc\td", nil, "", 0, 80, 4); got != "a   bc  d"+styleReset || cols != 9 {
+		t.Errorf("expected tabs to the next stop, got %q (%d)", got, cols)
+	}
+	if got, cols := paint("abcdef", nil, "", 0, 3, 8); got != "abc"+styleReset || cols != 3 {
+		t.Errorf("expected truncation to 3 columns, got %q (%d)", got, cols)
+	}
+	if got, cols := paint("ab漢字", nil, "", 0, 5, 8); got != "ab漢"+styleReset || cols != 4 {
+		t.Errorf("expected a wide character not to be split, got %q (%d)", got, cols)
+	}
+}
+
+func TestPaint_Skip(t *testing.T) {
+	tests := []struct {
+		text     string
+		skip     int
+		want     string
+		wantCols int
+	}{
+		{"abcdef", 2, "cdef", 4},
+		{"漢字かな", 3, " かな", 5}, // 字 is cut by the left edge
+		{"\tx", 4, "    x", 5},
+		{"ab", 5, "", 0},
+	}
+	for _, tt := range tests {
+		got, cols := paint(tt.text, nil, "", tt.skip, 80, 8)
+		if got != tt.want+styleReset || cols != tt.wantCols {
+			t.Errorf("%q skip %d: expected %q (%d), got %q (%d)", tt.text, tt.skip, tt.want, tt.wantCols, got, cols)
+		}
+	}
+}
+
+func TestPaint_SkipKeepsSpanColors(t *testing.T) {
+	spans := []syntax.Span{{Start: 0, End: 6, Kind: syntax.String}}
+	got, _ := paint(`"abcd"`, spans, "", 2, 80, 8)
+	if want := syntaxColors[syntax.String] + `bcd"` + styleReset; got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
@@ -0,0 +1,695 @@
+package ui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Action names something a key sequence can be bound to. The names are
+// the ones used in the config file.
+type Action string
+
+const (
+	ActionNext            Action = "next"
+	ActionPrev            Action = "prev"
+	ActionFirst           Action = "first"
+	ActionLast            Action = "last"
+	ActionGoTo            Action = "goto"
+	ActionSetMark         Action = "set-mark"
+	ActionJumpToMark      Action = "jump-to-mark"
+	ActionJumpBack        Action = "jump-back"
+	ActionJumpForward     Action = "jump-forward"
+	ActionSearch          Action = "search"
+	ActionNextMatch       Action = "next-match"
+	ActionPrevMatch       Action = "prev-match"
+	ActionGraphForward    Action = "graph-forward"
+	ActionGraphBack       Action = "graph-back"
+	ActionEnterBranch     Action = "enter-branch"
+	ActionLeaveBranch     Action = "leave-branch"
+	ActionFilter          Action = "filter"
+	ActionClearFilter     Action = "clear-filter"
+	ActionAutoplay        Action = "autoplay"
+	ActionFaster          Action = "faster"
+	ActionSlower          Action = "slower"
+	ActionTimeLapse       Action = "time-lapse"
+	ActionToggleDiff      Action = "toggle-diff"
+	ActionToggleSplit     Action = "toggle-split"
+	ActionToggleInfo      Action = "toggle-info"
+	ActionToggleWrap      Action = "toggle-wrap"
+	ActionScrollDown      Action = "scroll-down"
+	ActionScrollUp        Action = "scroll-up"
+	ActionScrollHalfDown  Action = "scroll-half-down"
+	ActionScrollHalfUp    Action = "scroll-half-up"
+	ActionScrollPageDown  Action = "scroll-page-down"
+	ActionScrollPageUp    Action = "scroll-page-up"
+	ActionScrollLeft      Action = "scroll-left"
+	ActionScrollRight     Action = "scroll-right"
+	ActionNextFile        Action = "next-file"
+	ActionPrevFile        Action = "prev-file"
+	ActionNextHunk        Action = "next-hunk"
+	ActionPrevHunk        Action = "prev-hunk"
+	ActionFileList        Action = "file-list"
+	ActionToggleFold      Action = "toggle-fold"
+	ActionPreviewDown     Action = "preview-down"
+	ActionPreviewUp       Action = "preview-up"
+	ActionPreviewPageDown Action = "preview-page-down"
+	ActionPreviewPageUp   Action = "preview-page-up"
+	ActionRepeat          Action = "repeat"
+	ActionMarkRange       Action = "mark-range"
+	ActionSelect          Action = "select"
+	ActionQuit            Action = "quit"
+)
+
+// actionHelp describes an action in the usage text.
+type actionHelp struct {
+	action Action
+	arg    string // placeholder for the key the action reads after it, or ""
+	text   string // lines after the first are indented to line up
+}
+
+// replayHelp lists the replay mode's actions in usage order.
+var replayHelp = []actionHelp{
+	{ActionNext, "", "Next commit"},
+	{ActionPrev, "", "Previous commit"},
+	{ActionFirst, "", "First commit"},
+	{ActionLast, "", "Last commit (after a count: go to that position)"},
+	{ActionGoTo, "<target>", "Go to a position, hash prefix, ref or date (YYYY-MM-DD)"},
+	{ActionSetMark, "<letter>", "Set a mark on the current commit"},
+	{ActionJumpToMark, "<letter>", "Jump to a mark"},
+	{ActionJumpBack, "", "Jump back (after goto, mark jumps, …)"},
+	{ActionJumpForward, "", "Jump forward"},
+	{ActionSearch, "", "Search commits: regexp on subject/body, author:<name>, hash:<prefix>"},
+	{ActionNextMatch, "", "Next search match"},
+	{ActionPrevMatch, "", "Previous search match"},
+	{ActionGraphForward, "", "Follow the graph to a child (choose a line at forks)"},
+	{ActionGraphBack, "", "Follow the graph to a parent (choose a line at merges)"},
+	{ActionEnterBranch, "", "Step into the side branch merged at this commit"},
+	{ActionLeaveBranch, "", "Step back out to the merge on the mainline"},
+	{ActionFilter, "", "Filter commits: author:<text> path:<glob> touches:<regexp>\nmsg:<regexp> (or bare text); prefix a rule with - to negate"},
+	{ActionClearFilter, "", "Clear the filter (press again to also drop skip rules)"},
+	{ActionAutoplay, "", "Start / pause autoplay (stops at the end, at marks and\nwhen the hook fails; any other command also pauses it)"},
+	{ActionFaster, "", "Autoplay faster"},
+	{ActionSlower, "", "Autoplay slower"},
+	{ActionTimeLapse, "", "Toggle time-lapse pacing; the header shows each commit's date\nand the project time elapsed since the start of the range"},
+	{ActionToggleDiff, "", "Toggle next-commit diff preview (on/off)"},
+	{ActionToggleSplit, "", "Toggle side-by-side / unified diff   (detail mode)"},
+	{ActionToggleInfo, "", "Toggle the commit pane: full message, author and committer,\nparents, trailers and changed files"},
+	{ActionScrollDown, "", "Scroll diff down            (detail mode)"},
+	{ActionScrollUp, "", "Scroll diff up              (detail mode)"},
+	{ActionScrollHalfDown, "", "Scroll half page down       (detail mode)"},
+	{ActionScrollHalfUp, "", "Scroll half page up         (detail mode)"},
+	{ActionScrollPageDown, "", "Scroll full page down       (detail mode)"},
+	{ActionScrollPageUp, "", "Scroll full page up         (detail mode)"},
+	{ActionScrollLeft, "", "Scroll long lines left      (detail mode)"},
+	{ActionScrollRight, "", "Scroll long lines right     (detail mode)"},
+	{ActionToggleWrap, "", "Wrap long lines instead     (detail mode)"},
+	{ActionNextFile, "", "Next file of the diff       (detail mode)"},
+	{ActionPrevFile, "", "Previous file of the diff   (detail mode)"},
+	{ActionNextHunk, "", "Next hunk                   (detail mode)"},
+	{ActionPrevHunk, "", "Previous hunk               (detail mode)"},
+	{ActionFileList, "", "List the files to jump to   (detail mode)"},
+	{ActionToggleFold, "", "Fold / unfold the file on top of the screen to its header line\n(detail mode; in the file list, the file under the cursor)"},
+	{ActionRepeat, "", "Repeat the last move or scroll (after a count: with that count)"},
+	{ActionQuit, "", "Quit and restore original state"},
+}
+
+// pickerHelp lists the picker's actions in usage order.
+var pickerHelp = []actionHelp{
+	{ActionScrollDown, "", "Move down"},
+	{ActionScrollUp, "", "Move up"},
+	{ActionScrollHalfDown, "", "Half page down"},
+	{ActionScrollHalfUp, "", "Half page up"},
+	{ActionScrollPageDown, "", "Page down"},
+	{ActionScrollPageUp, "", "Page up"},
+	{ActionFilter, "", "Filter: type to narrow the list by fuzzy match on hash,\nsubject and author; Enter keeps the filter, Esc clears it"},
+	{ActionClearFilter, "", "Clear the filter"},
+	{ActionSearch, "", "Search (same syntax as replay mode)"},
+	{ActionNextMatch, "", "Next match"},
+	{ActionPrevMatch, "", "Previous match"},
+	{ActionToggleDiff, "", "Show / hide the preview of the commit under the cursor:\nthe files it changes and its diff"},
+	{ActionPreviewPageDown, "", "Scroll the preview a page down"},
+	{ActionPreviewPageUp, "", "Scroll the preview a page up"},
+	{ActionPreviewDown, "", "Scroll the preview a line down"},
+	{ActionPreviewUp, "", "Scroll the preview a line up"},
+	{ActionMarkRange, "", "Mark the start of a range to replay; move to its other end\nand select it (press again to drop the range)"},
+	{ActionSelect, "", "Select commit"},
+	{ActionQuit, "", "Quit"},
+}
+
+// argActions read the key typed after them as their argument: the mark
+// name, or the first character of the goto target.
+var argActions = map[Action]bool{ActionGoTo: true, ActionSetMark: true, ActionJumpToMark: true}
+
+// vimKeys is the default keymap. Sequences are written the way the config
+// file spells them: key names separated by spaces.
+var vimKeys = map[Action][]string{
+	ActionNext:            {"n"},
+	ActionPrev:            {"p"},
+	ActionFirst:           {"g g"},
+	ActionLast:            {"G"},
+	ActionGoTo:            {"g"},
+	ActionSetMark:         {"m"},
+	ActionJumpToMark:      {"'"},
+	ActionJumpBack:        {"ctrl+o"},
+	ActionJumpForward:     {"tab"},
+	ActionSearch:          {"/"},
+	ActionNextMatch:       {"N"},
+	ActionPrevMatch:       {"P"},
+	ActionGraphForward:    {"]"},
+	ActionGraphBack:       {"["},
+	ActionEnterBranch:     {">"},
+	ActionLeaveBranch:     {"<"},
+	ActionFilter:          {"f"},
+	ActionClearFilter:     {"F"},
+	ActionAutoplay:        {"a"},
+	ActionFaster:          {"+", "="},
+	ActionSlower:          {"-"},
+	ActionTimeLapse:       {"T"},
+	ActionToggleDiff:      {"d"},
+	ActionToggleSplit:     {"s"},
+	ActionToggleInfo:      {"i"},
+	ActionToggleWrap:      {"w"},
+	ActionScrollDown:      {"j", "down"},
+	ActionScrollUp:        {"k", "up"},
+	ActionScrollHalfDown:  {"ctrl+d"},
+	ActionScrollHalfUp:    {"ctrl+u"},
+	ActionScrollPageDown:  {"space", "pgdown"},
+	ActionScrollPageUp:    {"ctrl+b", "pgup"},
+	ActionScrollLeft:      {"h", "left"},
+	ActionScrollRight:     {"l", "right"},
+	ActionNextFile:        {"}"},
+	ActionPrevFile:        {"{"},
+	ActionNextHunk:        {")"},
+	ActionPrevHunk:        {"("},
+	ActionFileList:        {"o"},
+	ActionToggleFold:      {"z a"},
+	ActionPreviewDown:     {"ctrl+e"},
+	ActionPreviewUp:       {"ctrl+y"},
+	ActionPreviewPageDown: {"J"},
+	ActionPreviewPageUp:   {"K"},
+	ActionRepeat:          {"."},
+	ActionMarkRange:       {"v"},
+	ActionSelect:          {"enter"},
+	ActionQuit:            {"q"},
+}
+
+// presets change some of the default keymap's bindings; the other
+// actions keep theirs.
+var presets = map[string]map[Action][]string{
+	"vim": {},
+	// Emacs-style movement and scrolling, with multi-key sequences on
+	// Ctrl+X for the less common commands.
+	"emacs": {
+		ActionFirst:          {"alt+<"},
+		ActionLast:           {"alt+>"},
+		ActionGoTo:           {"alt+g g"},
+		ActionSetMark:        {"ctrl+x r m"},
+		ActionJumpToMark:     {"ctrl+x r b"},
+		ActionSearch:         {"ctrl+s", "/"},
+		ActionScrollDown:     {"ctrl+n", "down"},
+		ActionScrollUp:       {"ctrl+p", "up"},
+		ActionScrollPageDown: {"ctrl+v", "space", "pgdown"},
+		ActionScrollPageUp:   {"alt+v", "pgup"},
+		ActionScrollLeft:     {"ctrl+b", "left"},
+		ActionScrollRight:    {"ctrl+f", "right"},
+		ActionRepeat:         {"ctrl+x z"},
+		ActionQuit:           {"q", "ctrl+x ctrl+c"},
+	},
+	// Arrow and navigation keys only for moving and scrolling, leaving the
+	// letters to the other commands.
+	"arrows": {
+		ActionNext:        {"right"},
+		ActionPrev:        {"left"},
+		ActionFirst:       {"home"},
+		ActionLast:        {"end"},
+		ActionScrollDown:  {"down"},
+		ActionScrollUp:    {"up"},
+		ActionScrollLeft:  {"shift+left"},
+		ActionScrollRight: {"shift+right"},
+	},
+}
+
+// Presets returns the names of the built-in keymaps.
+func Presets() []string {
+	names := make([]string, 0, len(presets))
+	for name := range presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Keymap maps key sequences to actions.
+type Keymap struct {
+	bindings map[Action][][]Key // in the order given; the first is shown in short help
+	actions  map[string]Action  // sequence (see seqID) → action
+	prefixes map[string]bool    // proper prefixes of bound sequences
+}
+
+// NewKeymap builds the keymap of the named preset ("" for the default,
+// "vim") with the bindings in overrides replacing the preset's for the
+// actions they name. A key the overrides bind is dropped from the action
+// the preset had bound it to. Ctrl+C always quits.
+func NewKeymap(preset string, overrides map[string][]string) (*Keymap, error) {
+	if preset == "" {
+		preset = "vim"
+	}
+	changes, ok := presets[preset]
+	if !ok {
+		return nil, fmt.Errorf("unknown keymap %q (one of %s)", preset, strings.Join(Presets(), ", "))
+	}
+
+	spelled := make(map[Action][]string, len(vimKeys))
+	for a, keys := range vimKeys {
+		spelled[a] = keys
+	}
+	for a, keys := range changes {
+		spelled[a] = keys
+	}
+	bindings, err := parseBindings(spelled)
+	if err != nil {
+		return nil, fmt.Errorf("keymap %s: %w", preset, err)
+	}
+
+	user := make(map[Action][]string, len(overrides))
+	for name, keys := range overrides {
+		a := Action(name)
+		if _, ok := vimKeys[a]; !ok {
+			return nil, fmt.Errorf("unknown action %q", name)
+		}
+		user[a] = keys
+	}
+	userBindings, err := parseBindings(user)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkConflicts(userBindings); err != nil {
+		return nil, err
+	}
+	taken := make(map[string]bool)
+	for _, seqs := range userBindings {
+		for _, seq := range seqs {
+			taken[seqID(seq)] = true
+		}
+	}
+	for a, seqs := range bindings {
+		var kept [][]Key
+		for _, seq := range seqs {
+			if !taken[seqID(seq)] {
+				kept = append(kept, seq)
+			}
+		}
+		bindings[a] = kept
+	}
+	for a, seqs := range userBindings {
+		bindings[a] = seqs
+	}
+
+	for a, seqs := range bindings {
+		for _, seq := range seqs {
+			if a != ActionQuit && len(seq) == 1 && seq[0] == KeyCtrlC {
+				return nil, fmt.Errorf("%s: ctrl+c always quits", a)
+			}
+		}
+	}
+	if !bound(bindings[ActionQuit], KeyCtrlC) {
+		bindings[ActionQuit] = append(bindings[ActionQuit], []Key{KeyCtrlC})
+	}
+	if err := checkConflicts(bindings); err != nil {
+		return nil, fmt.Errorf("keymap %s: %w", preset, err)
+	}
+
+	km := &Keymap{bindings: bindings, actions: make(map[string]Action), prefixes: make(map[string]bool)}
+	for a, seqs := range bindings {
+		for _, seq := range seqs {
+			km.actions[seqID(seq)] = a
+			for i := 1; i < len(seq); i++ {
+				km.prefixes[seqID(seq[:i])] = true
+			}
+		}
+	}
+	return km, nil
+}
+
+// defaultKeymap is the keymap used where none is set.
+var defaultKeymap, _ = NewKeymap("", nil)
+
+// DefaultKeymap returns the vim-style keymap.
+func DefaultKeymap() *Keymap { return defaultKeymap }
+
+// orDefault lets views treat a nil *Keymap as the default one.
+func (km *Keymap) orDefault() *Keymap {
+	if km == nil {
+		return defaultKeymap
+	}
+	return km
+}
+
+func bound(seqs [][]Key, k Key) bool {
+	for _, seq := range seqs {
+		if len(seq) == 1 && seq[0] == k {
+			return true
+		}
+	}
+	return false
+}
+
+// parseBindings parses the key sequences of each action.
+func parseBindings(spelled map[Action][]string) (map[Action][][]Key, error) {
+	bindings := make(map[Action][][]Key, len(spelled))
+	for a, keys := range spelled {
+		seqs := make([][]Key, 0, len(keys))
+		for _, s := range keys {
+			seq, err := ParseKeys(s)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", a, err)
+			}
+			if k := seq[0]; len(k) == 1 && k[0] >= '1' && k[0] <= '9' {
+				return nil, fmt.Errorf("%s: %q starts a count", a, s)
+			}
+			seqs = append(seqs, seq)
+		}
+		bindings[a] = seqs
+	}
+	return bindings, nil
+}
+
+// checkConflicts reports a sequence bound to two actions.
+func checkConflicts(bindings map[Action][][]Key) error {
+	owner := make(map[string]Action)
+	for _, a := range sortedActions(bindings) {
+		for _, seq := range bindings[a] {
+			if other, ok := owner[seqID(seq)]; ok && other != a {
+				return fmt.Errorf("%q is bound to both %s and %s", seqName(seq, false), other, a)
+			}
+			owner[seqID(seq)] = a
+		}
+	}
+	return nil
+}
+
+func sortedActions(bindings map[Action][][]Key) []Action {
+	actions := make([]Action, 0, len(bindings))
+	for a := range bindings {
+		actions = append(actions, a)
+	}
+	sort.Slice(actions, func(i, j int) bool { return actions[i] < actions[j] })
+	return actions
+}
+
+// seqID is a sequence's key in the lookup maps.
+func seqID(seq []Key) string {
+	var b strings.Builder
+	for _, k := range seq {
+		b.WriteString(string(k))
+		b.WriteByte(0)
+	}
+	return b.String()
+}
+
+// keyAliases are the other spellings of keys the terminal sends as
+// something else, e.g. Ctrl+I arrives as Tab.
+var keyAliases = map[string]Key{
+	"space": " ", "return": KeyEnter, "escape": KeyEsc, "del": KeyDelete,
+	"ctrl+i": KeyTab, "ctrl+m": KeyEnter, "ctrl+j": KeyEnter, "ctrl+h": KeyBackspace,
+	"pagedown": KeyPgDown, "pageup": KeyPgUp,
+}
+
+// namedKeys are the special keys the config file can name.
+var namedKeys = map[Key]bool{
+	KeyEnter: true, KeyEsc: true, KeyBackspace: true, KeyTab: true,
+	KeyUp: true, KeyDown: true, KeyLeft: true, KeyRight: true,
+	KeyShiftUp: true, KeyShiftDown: true, KeyShiftLeft: true, KeyShiftRight: true,
+	KeyHome: true, KeyEnd: true, KeyPgUp: true, KeyPgDown: true, KeyDelete: true,
+}
+
+// ParseKeys parses a key sequence as written in the config file: key
+// names separated by spaces, e.g. "g g", "ctrl+x r m" or "shift+left".
+// A key is a single character, a special key's name ("enter", "space",
+// "pgdown"…), or ctrl+<letter> or alt+<key>.
+func ParseKeys(s string) ([]Key, error) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("empty key sequence")
+	}
+	seq := make([]Key, len(fields))
+	for i, f := range fields {
+		k, err := parseKey(f)
+		if err != nil {
+			return nil, err
+		}
+		seq[i] = k
+	}
+	return seq, nil
+}
+
+func parseKey(s string) (Key, error) {
+	if utf8.RuneCountInString(s) == 1 {
+		if k := Key(s); k.IsPrintable() {
+			return k, nil
+		}
+		return "", fmt.Errorf("unknown key %q", s)
+	}
+	name := strings.ToLower(s)
+	if k, ok := keyAliases[name]; ok {
+		return k, nil
+	}
+	if namedKeys[Key(name)] {
+		return Key(name), nil
+	}
+	if c, ok := strings.CutPrefix(name, "ctrl+"); ok && len(c) == 1 && c[0] >= 'a' && c[0] <= 'z' {
+		return Key(name), nil
+	}
+	if strings.HasPrefix(name, "alt+") {
+		k, err := parseKey(s[len("alt+"):])
+		if err != nil || k == " " {
+			return "", fmt.Errorf("unknown key %q", s)
+		}
+		return "alt+" + k, nil
+	}
+	return "", fmt.Errorf("unknown key %q", s)
+}
+
+// keyNames spell keys in help text; shortKeyNames in the one-line hints,
+// where Ctrl+X also shortens to ^X.
+var (
+	keyNames = map[Key]string{
+		KeyEnter: "Enter", KeyEsc: "Esc", KeyBackspace: "Backspace", KeyTab: "Tab",
+		KeyUp: "↑", KeyDown: "↓", KeyLeft: "←", KeyRight: "→",
+		KeyShiftUp: "Shift+↑", KeyShiftDown: "Shift+↓", KeyShiftLeft: "Shift+←", KeyShiftRight: "Shift+→",
+		KeyHome: "Home", KeyEnd: "End", KeyPgUp: "PgUp", KeyPgDown: "PgDn", KeyDelete: "Del",
+		" ": "Space",
+	}
+	shortKeyNames = map[Key]string{" ": "spc", KeyShiftLeft: "S-←", KeyShiftRight: "S-→", KeyShiftUp: "S-↑", KeyShiftDown: "S-↓"}
+)
+
+// keyName spells k for help text, e.g. "Ctrl+D", "↓" or "Alt+<".
+func keyName(k Key, short bool) string {
+	if name, ok := shortKeyNames[k]; ok && short {
+		return name
+	}
+	if name, ok := keyNames[k]; ok {
+		return name
+	}
+	if c, ok := strings.CutPrefix(string(k), "ctrl+"); ok {
+		if short {
+			return "^" + strings.ToUpper(c)
+		}
+		return "Ctrl+" + strings.ToUpper(c)
+	}
+	if rest, ok := strings.CutPrefix(string(k), "alt+"); ok {
+		if short {
+			return "M-" + keyName(Key(rest), true)
+		}
+		return "Alt+" + keyName(Key(rest), false)
+	}
+	return string(k)
+}
+
+// seqName spells a key sequence: "gg" when every key is a plain
+// character, else the key names separated by spaces ("Ctrl+X r m").
+func seqName(seq []Key, short bool) string {
+	names := make([]string, len(seq))
+	plain := true
+	for i, k := range seq {
+		names[i] = keyName(k, short)
+		plain = plain && len(seq) > 1 && k.IsPrintable() && k != " "
+	}
+	if plain {
+		return strings.Join(names, "")
+	}
+	return strings.Join(names, " ")
+}
+
+// Bindings returns the key sequences bound to a, spelled for help text.
+func (km *Keymap) Bindings(a Action) []string {
+	km = km.orDefault()
+	names := make([]string, len(km.bindings[a]))
+	for i, seq := range km.bindings[a] {
+		names[i] = seqName(seq, false)
+	}
+	return names
+}
+
+// Key returns a's first binding, spelled for help text, or the action's
+// name in angle brackets if nothing is bound to it.
+func (km *Keymap) Key(a Action) string {
+	if names := km.Bindings(a); len(names) > 0 {
+		return names[0]
+	}
+	return "<" + string(a) + ">"
+}
+
+// Hint is the short form of a's bindings for a controls line: all of
+// them run together when each is a single column wide ("j↓"), else just
+// the first ("spc"). It is "" if a is unbound.
+func (km *Keymap) Hint(a Action) string {
+	km = km.orDefault()
+	names := make([]string, len(km.bindings[a]))
+	for i, seq := range km.bindings[a] {
+		names[i] = seqName(seq, true)
+		if StringWidth(names[i]) > 1 {
+			return names[0]
+		}
+	}
+	return strings.Join(names, "")
+}
+
+// hints builds a controls line from "keys label" pairs, leaving out
+// those whose actions are all unbound. Actions in one pair are shown
+// together, separated by a slash.
+func (km *Keymap) hints(pairs ...hint) string {
+	var parts []string
+	for _, h := range pairs {
+		var keys []string
+		for _, a := range h.actions {
+			if k := km.Hint(a); k != "" {
+				keys = append(keys, k)
+			}
+		}
+		if len(keys) == 0 {
+			continue
+		}
+		part := strings.Join(keys, "/")
+		if h.label != "" {
+			part += " " + h.label
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, "  ")
+}
+
+// hint is one entry of a controls line.
+type hint struct {
+	label   string
+	actions []Action
+}
+
+func hintFor(label string, actions ...Action) hint { return hint{label, actions} }
+
+// PickerHelp is the picker's part of the usage text, one action a line.
+func (km *Keymap) PickerHelp() string {
+	return km.orDefault().help(pickerHelp)
+}
+
+// ReplayHelp is the replay mode's part of the usage text, one action a
+// line, followed by how counts work.
+func (km *Keymap) ReplayHelp() string {
+	km = km.orDefault()
+	var b strings.Builder
+	b.WriteString(km.help(replayHelp))
+	counted := func(n int, a Action) string {
+		k := km.Key(a)
+		if StringWidth(k) > 1 {
+			k = " " + k
+		}
+		return fmt.Sprint(n) + k
+	}
+	fmt.Fprintf(&b, "\n  Moves and scrolls take a count prefix: %s, %s, %s; %s goes to\n", counted(10, ActionNext), counted(5, ActionPrev), counted(3, ActionScrollDown), counted(5, ActionLast))
+	b.WriteString("  position 5. Only the commit a counted move lands on is checked out.\n")
+	return b.String()
+}
+
+// help formats actions as a two-column table: the bindings, then the
+// description. Unbound actions are left out.
+func (km *Keymap) help(actions []actionHelp) string {
+	keys := make([]string, len(actions))
+	width := 9
+	for i, h := range actions {
+		var names []string
+		for _, name := range km.Bindings(h.action) {
+			names = append(names, name+h.arg)
+		}
+		keys[i] = strings.Join(names, " / ")
+		width = max(width, StringWidth(keys[i]))
+	}
+	var b strings.Builder
+	indent := strings.Repeat(" ", width+4)
+	for i, h := range actions {
+		if keys[i] == "" {
+			continue
+		}
+		text := strings.ReplaceAll(h.text, "\n", "\n"+indent)
+		fmt.Fprintf(&b, "  %s%s  %s\n", keys[i], strings.Repeat(" ", width-StringWidth(keys[i])), text)
+	}
+	return b.String()
+}
+
+// bannerEntry is one "keys → text" item of the banner. The keys of its
+// actions are joined with slashes, matching the slashes in text.
+type bannerEntry struct {
+	text    string
+	arg     string // placeholder appended to each key, e.g. "<x>"
+	actions []Action
+}
+
+// bannerLines are the banner's lines of entries.
+var bannerLines = [][]bannerEntry{
+	{{"next", "", []Action{ActionNext}}},
+	{{"previous", "", []Action{ActionPrev}}},
+	{{"go to position/hash/ref/date", "", []Action{ActionGoTo}}, {"first/last", "", []Action{ActionFirst, ActionLast}}},
+	{{"set mark", "<x>", []Action{ActionSetMark}}, {"jump to mark", "<x>", []Action{ActionJumpToMark}}, {"jump back/forward", "", []Action{ActionJumpBack, ActionJumpForward}}},
+	{{"search", "", []Action{ActionSearch}}, {"next/previous match", "", []Action{ActionNextMatch, ActionPrevMatch}}},
+	{{"follow graph forward/back", "", []Action{ActionGraphForward, ActionGraphBack}}, {"enter/leave merged branch", "", []Action{ActionEnterBranch, ActionLeaveBranch}}},
+	{{"filter (author:, path:, touches:, msg:)", "", []Action{ActionFilter}}, {"clear filter", "", []Action{ActionClearFilter}}},
+	{{"autoplay on/off", "", []Action{ActionAutoplay}}, {"faster/slower", "", []Action{ActionFaster, ActionSlower}}, {"time-lapse pacing", "", []Action{ActionTimeLapse}}},
+	{{"toggle next commit diff", "", []Action{ActionToggleDiff}}, {"commit details", "", []Action{ActionToggleInfo}}},
+}
+
+// BannerLines is the replay mode's summary of keys, e.g.
+// "/ → search, N/P → next/previous match". An entry is left out if one of
+// its actions is unbound.
+func (km *Keymap) BannerLines() []string {
+	km = km.orDefault()
+	var lines []string
+	for _, entries := range bannerLines {
+		var parts []string
+	entry:
+		for _, e := range entries {
+			keys := make([]string, len(e.actions))
+			for i, a := range e.actions {
+				seqs := km.bindings[a]
+				if len(seqs) == 0 {
+					continue entry
+				}
+				keys[i] = seqName(seqs[0], true) + e.arg
+			}
+			parts = append(parts, strings.Join(keys, "/")+" → "+e.text)
+		}
+		if len(parts) > 0 {
+			lines = append(lines, strings.Join(parts, ", "))
+		}
+	}
+
+	count := "<count><key> → repeat a move"
+	if next, prev := km.bindings[ActionNext], km.bindings[ActionPrev]; len(next) > 0 && len(prev) > 0 {
+		count += fmt.Sprintf(" (10%s, 5%s)", seqName(next[0], true), seqName(prev[0], true))
+	}
+	if seqs := km.bindings[ActionRepeat]; len(seqs) > 0 {
+		count += ", " + seqName(seqs[0], true) + " → repeat last action"
+	}
+	quit := km.bindings[ActionQuit] // never empty: Ctrl+C always quits
+	return append(lines, count, seqName(quit[0], true)+" → quit")
+}
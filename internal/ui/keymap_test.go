@@ -0,0 +1,224 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anuchito/replay/internal/navigator"
+)
+
+func TestNewKeymap_Presets(t *testing.T) {
+	for _, name := range Presets() {
+		km, err := NewKeymap(name, nil)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", name, err)
+			continue
+		}
+		for _, a := range []Action{ActionNext, ActionPrev, ActionScrollDown, ActionSelect, ActionQuit} {
+			if len(km.Bindings(a)) == 0 {
+				t.Errorf("%s: expected %s to be bound", name, a)
+			}
+		}
+	}
+	if fmt.Sprint(DefaultKeymap().Bindings(ActionFirst)) != "[gg]" {
+		t.Errorf("expected gg for first, got %v", DefaultKeymap().Bindings(ActionFirst))
+	}
+}
+
+func TestNewKeymap_Overrides(t *testing.T) {
+	km, err := NewKeymap("vim", map[string][]string{
+		"next":        {"j", "ctrl+x n"},
+		"toggle-wrap": {},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := fmt.Sprint(km.Bindings(ActionNext)); got != "[j Ctrl+X n]" {
+		t.Errorf("expected next on j and Ctrl+X n, got %s", got)
+	}
+	// j moved to next; scroll-down keeps its other key.
+	if got := fmt.Sprint(km.Bindings(ActionScrollDown)); got != "[↓]" {
+		t.Errorf("expected scroll-down left with ↓, got %s", got)
+	}
+	if len(km.Bindings(ActionToggleWrap)) != 0 {
+		t.Errorf("expected toggle-wrap unbound, got %v", km.Bindings(ActionToggleWrap))
+	}
+	if got := fmt.Sprint(km.Bindings(ActionQuit)); got != "[q Ctrl+C]" {
+		t.Errorf("expected quit on q and Ctrl+C, got %s", got)
+	}
+}
+
+func TestNewKeymap_CtrlCAlwaysQuits(t *testing.T) {
+	km, err := NewKeymap("", map[string][]string{"quit": {}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := fmt.Sprint(km.Bindings(ActionQuit)); got != "[Ctrl+C]" {
+		t.Errorf("expected quit on Ctrl+C alone, got %s", got)
+	}
+	if _, err := NewKeymap("", map[string][]string{"next": {"ctrl+c"}}); err == nil {
+		t.Error("expected an error binding ctrl+c to next")
+	}
+}
+
+func TestNewKeymap_Errors(t *testing.T) {
+	tests := []struct {
+		preset    string
+		overrides map[string][]string
+		want      string
+	}{
+		{"helix", nil, `unknown keymap "helix"`},
+		{"", map[string][]string{"nxet": {"n"}}, `unknown action "nxet"`},
+		{"", map[string][]string{"next": {"ctrl+1"}}, `unknown key "ctrl+1"`},
+		{"", map[string][]string{"next": {""}}, "empty key sequence"},
+		{"", map[string][]string{"next": {"5"}}, "starts a count"},
+		{"", map[string][]string{"next": {"x"}, "prev": {"x"}}, `"x" is bound to both next and prev`},
+	}
+	for _, tt := range tests {
+		_, err := NewKeymap(tt.preset, tt.overrides)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s %v: expected error containing %q, got %v", tt.preset, tt.overrides, tt.want, err)
+		}
+	}
+}
+
+func TestParseKeys(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []Key
+	}{
+		{"n", []Key{"n"}},
+		{"G", []Key{"G"}},
+		{"g g", []Key{"g", "g"}},
+		{"Ctrl+X r m", []Key{"ctrl+x", "r", "m"}},
+		{"space", []Key{" "}},
+		{"ctrl+i", []Key{KeyTab}},
+		{"PgDown", []Key{KeyPgDown}},
+		{"shift+left", []Key{KeyShiftLeft}},
+		{"alt+<", []Key{"alt+<"}},
+		{"Alt+enter", []Key{"alt+enter"}},
+		{"é", []Key{"é"}},
+	}
+	for _, tt := range tests {
+		got, err := ParseKeys(tt.in)
+		if err != nil {
+			t.Errorf("ParseKeys(%q): unexpected error: %v", tt.in, err)
+			continue
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("ParseKeys(%q): expected %q, got %q", tt.in, tt.want, got)
+		}
+	}
+	for _, bad := range []string{"ctrl+", "shift+x", "alt+space", "\x01", "f1"} {
+		if _, err := ParseKeys(bad); err == nil {
+			t.Errorf("ParseKeys(%q): expected an error", bad)
+		}
+	}
+}
+
+func TestKeymap_Help(t *testing.T) {
+	km, err := NewKeymap("", map[string][]string{"set-mark": {"M"}, "toggle-wrap": {}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	help := km.ReplayHelp()
+	for _, want := range []string{
+		"  n              Next commit\n",
+		"  gg             First commit\n",
+		"  g<target>      Go to a position",
+		"  M<letter>      Set a mark",
+		"  Space / PgDn   Scroll full page down",
+		"  q / Ctrl+C     Quit",
+		"10n, 5p, 3j; 5G goes to",
+	} {
+		if !strings.Contains(help, want) {
+			t.Errorf("expected %q in help:\n%s", want, help)
+		}
+	}
+	if strings.Contains(help, "Wrap long lines") {
+		t.Errorf("expected unbound toggle-wrap left out:\n%s", help)
+	}
+	if !strings.Contains(km.PickerHelp(), "  Enter          Select commit\n") {
+		t.Errorf("expected Enter to select in the picker help:\n%s", km.PickerHelp())
+	}
+}
+
+func TestKeymap_BannerLines(t *testing.T) {
+	lines := DefaultKeymap().BannerLines()
+	for _, want := range []string{
+		"n → next",
+		"g → go to position/hash/ref/date, gg/G → first/last",
+		"m<x> → set mark, '<x> → jump to mark, ^O/Tab → jump back/forward",
+		"<count><key> → repeat a move (10n, 5p), . → repeat last action",
+		"q → quit",
+	} {
+		found := false
+		for _, l := range lines {
+			found = found || l == want
+		}
+		if !found {
+			t.Errorf("expected banner line %q, got:\n%s", want, strings.Join(lines, "\n"))
+		}
+	}
+
+	km, err := NewKeymap("", map[string][]string{"last": {}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	banner := strings.Join(km.BannerLines(), "\n")
+	if !strings.Contains(banner, "g → go to position/hash/ref/date\n") || strings.Contains(banner, "first/last") {
+		t.Errorf("expected first/last left out once last is unbound, got:\n%s", banner)
+	}
+}
+
+func TestKeymap_Hint(t *testing.T) {
+	km := DefaultKeymap()
+	tests := map[Action]string{
+		ActionScrollDown:     "j↓",
+		ActionScrollPageDown: "spc",
+		ActionScrollHalfDown: "^D",
+		ActionQuit:           "q",
+	}
+	for a, want := range tests {
+		if got := km.Hint(a); got != want {
+			t.Errorf("Hint(%s): expected %q, got %q", a, want, got)
+		}
+	}
+	emacs, _ := NewKeymap("emacs", nil)
+	if got := emacs.Hint(ActionScrollPageUp); got != "M-v" {
+		t.Errorf("expected M-v, got %q", got)
+	}
+}
+
+func TestHints_FollowKeymap(t *testing.T) {
+	km, err := NewKeymap("emacs", map[string][]string{
+		"faster": {"ctrl+x +"}, "slower": {"ctrl+x -"}, "autoplay": {"ctrl+x a"},
+		"next-match": {"alt+n"}, "prev-match": {"alt+p"}, "toggle-wrap": {"ctrl+x w"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	display := New(nil)
+	display.Keys = km
+	if got, want := display.AutoplayStatus(1400*time.Millisecond, "every 2s"), "▶ next in 1.4s (every 2s)  ^X +/^X - speed  ^X a pauses"; got != want {
+		t.Errorf("autoplay: expected %q, got %q", want, got)
+	}
+
+	p := NewPicker(sampleCommits(), 5)
+	p.keys = km
+	q, _ := navigator.ParseQuery("commit")
+	p.SetQuery(q)
+	if got, want := p.matchStatus(), "/commit  match 1/5  M-n/M-p next/prev"; got != want {
+		t.Errorf("picker: expected %q, got %q", want, got)
+	}
+
+	dv := NewDiffView()
+	dv.Keys, dv.Wrap = km, true
+	dv.SetDiff([]string{"diff --git a/app.min.js b/app.min.js", "@@ -1 +1 @@", "+" + strings.Repeat("b", 3000)})
+	if got := renderDiff(dv, 200, 20)[1]; !strings.HasSuffix(got, "  ^X w turns wrapping off") {
+		t.Errorf("wrap: expected the toggle's keys, got %q", got)
+	}
+}
@@ -0,0 +1,150 @@
+package ui
+
+import (
+	"io"
+	"unicode/utf8"
+)
+
+// Key is a decoded keypress: the character itself for printable input
+// ("n", "G", "/"), or a name for special keys ("enter", "up", "ctrl+d").
+type Key string
+
+const (
+	KeyEnter      Key = "enter"
+	KeyEsc        Key = "esc"
+	KeyBackspace  Key = "backspace"
+	KeyTab        Key = "tab"
+	KeyUp         Key = "up"
+	KeyDown       Key = "down"
+	KeyLeft       Key = "left"
+	KeyRight      Key = "right"
+	KeyShiftUp    Key = "shift+up"
+	KeyShiftDown  Key = "shift+down"
+	KeyShiftLeft  Key = "shift+left"
+	KeyShiftRight Key = "shift+right"
+	KeyHome       Key = "home"
+	KeyEnd        Key = "end"
+	KeyPgUp       Key = "pgup"
+	KeyPgDown     Key = "pgdown"
+	KeyDelete     Key = "delete"
+	KeyCtrlC      Key = "ctrl+c"
+)
+
+// IsPrintable reports whether k is a single printable character.
+func (k Key) IsPrintable() bool {
+	r, size := utf8.DecodeRuneInString(string(k))
+	return size == len(k) && r >= ' ' && r != 0x7f && r != utf8.RuneError
+}
+
+// KeyReader decodes raw-mode terminal input into keys.
+//
+// A terminal delivers an escape sequence (arrow keys, PgUp…) in a single
+// read, so a lone ESC at the end of a read is the Esc key itself. This avoids
+// the timeouts most line editors need to tell the two apart.
+type KeyReader struct {
+	r   io.Reader
+	buf []byte // bytes from the last read not yet decoded
+}
+
+func NewKeyReader(r io.Reader) *KeyReader {
+	return &KeyReader{r: r}
+}
+
+// ReadKey blocks until a key is available.
+func (kr *KeyReader) ReadKey() (Key, error) {
+	for len(kr.buf) == 0 {
+		chunk := make([]byte, 64)
+		n, err := kr.r.Read(chunk)
+		if n > 0 {
+			kr.buf = chunk[:n]
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	k, n := decodeKey(kr.buf)
+	kr.buf = kr.buf[n:]
+	return k, nil
+}
+
+// KeyEvent is a keypress, or the error that ended reading.
+type KeyEvent struct {
+	Key Key
+	Err error
+}
+
+// Events reads keys in the background and delivers them on the returned
+// channel, so that a loop can wait for a key and other events at once.
+// Reading stops after the first error, which is delivered last.
+func (kr *KeyReader) Events() <-chan KeyEvent {
+	events := make(chan KeyEvent)
+	go func() {
+		for {
+			k, err := kr.ReadKey()
+			events <- KeyEvent{k, err}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	return events
+}
+
+// decodeKey decodes the first key in b and returns it with the bytes consumed.
+func decodeKey(b []byte) (Key, int) {
+	switch c := b[0]; {
+	case c == 0x1b:
+		return decodeEscape(b)
+	case c == '\r' || c == '\n':
+		return KeyEnter, 1
+	case c == '\t':
+		return KeyTab, 1
+	case c == 0x7f || c == 0x08:
+		return KeyBackspace, 1
+	case c >= 1 && c <= 26:
+		return Key("ctrl+" + string(rune('a'+c-1))), 1
+	case c < ' ':
+		return Key(""), 1
+	}
+	r, size := utf8.DecodeRune(b)
+	if r == utf8.RuneError {
+		return Key(""), 1
+	}
+	return Key(string(r)), size
+}
+
+var csiKeys = map[string]Key{
+	"A": KeyUp, "B": KeyDown, "C": KeyRight, "D": KeyLeft,
+	"1;2A": KeyShiftUp, "1;2B": KeyShiftDown, "1;2C": KeyShiftRight, "1;2D": KeyShiftLeft,
+	"H": KeyHome, "F": KeyEnd,
+	"1~": KeyHome, "7~": KeyHome, "4~": KeyEnd, "8~": KeyEnd,
+	"3~": KeyDelete, "5~": KeyPgUp, "6~": KeyPgDown,
+}
+
+func decodeEscape(b []byte) (Key, int) {
+	if len(b) == 1 {
+		return KeyEsc, 1
+	}
+	switch b[1] {
+	case '[', 'O':
+		// CSI / SS3: parameter bytes then a final byte in 0x40–0x7e.
+		for i := 2; i < len(b); i++ {
+			if b[i] >= 0x40 && b[i] <= 0x7e {
+				if k, ok := csiKeys[string(b[2:i+1])]; ok {
+					return k, i + 1
+				}
+				return Key(""), i + 1
+			}
+		}
+		return KeyEsc, len(b)
+	case 0x1b:
+		return KeyEsc, 1
+	}
+	// ESC followed by a character is how terminals send Alt+<char>.
+	k, n := decodeKey(b[1:])
+	if k == "" {
+		return KeyEsc, 1
+	}
+	return "alt+" + k, n + 1
+}
@@ -0,0 +1,102 @@
+package ui
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func readAllKeys(t *testing.T, input []byte) []Key {
+	t.Helper()
+	kr := NewKeyReader(bytes.NewReader(input))
+	var keys []Key
+	for {
+		k, err := kr.ReadKey()
+		if err == io.EOF {
+			return keys
+		}
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		keys = append(keys, k)
+	}
+}
+
+func TestKeyReader_Decode(t *testing.T) {
+	input := []byte{
+		'n', 'G',
+		0x1b, '[', 'A', // up
+		0x1b, '[', 'B', // down
+		0x1b, '[', '5', '~', // page up
+		0x1b, 'O', 'C', // right (SS3)
+		0x1b, '[', '1', ';', '2', 'D', // shift+left
+		4, 21, 15, 3, // ctrl+d ctrl+u ctrl+o ctrl+c
+		'\r', '\t', 0x7f,
+	}
+	input = append(input, []byte("é")...)
+	input = append(input, 0x1b) // lone ESC at end of read
+
+	want := []Key{"n", "G", KeyUp, KeyDown, KeyPgUp, KeyRight, KeyShiftLeft,
+		"ctrl+d", "ctrl+u", "ctrl+o", KeyCtrlC, KeyEnter, KeyTab, KeyBackspace, "é", KeyEsc}
+	got := readAllKeys(t, input)
+	if len(got) != len(want) {
+		t.Fatalf("expected %d keys, got %d: %q", len(want), len(got), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("key %d: expected %q, got %q", i, want[i], got[i])
+		}
+	}
+}
+
+func TestKeyReader_Alt(t *testing.T) {
+	got := readAllKeys(t, []byte{0x1b, 'x'})
+	if len(got) != 1 || got[0] != "alt+x" {
+		t.Errorf("expected alt+x, got %q", got)
+	}
+}
+
+func TestKey_IsPrintable(t *testing.T) {
+	for _, k := range []Key{"a", "/", " ", "é"} {
+		if !k.IsPrintable() {
+			t.Errorf("expected %q to be printable", k)
+		}
+	}
+	for _, k := range []Key{KeyEnter, KeyUp, "ctrl+d", ""} {
+		if k.IsPrintable() {
+			t.Errorf("expected %q not to be printable", k)
+		}
+	}
+}
+
+func TestLineInput(t *testing.T) {
+	li := NewLineInput("goto: ", "v")
+	for _, k := range []Key{"1", ".", "x", KeyBackspace, "2"} {
+		if done, cancel := li.Handle(k); done || cancel {
+			t.Fatalf("unexpected finish on %q", k)
+		}
+	}
+	if li.Text() != "v1.2" {
+		t.Errorf("expected text v1.2, got %q", li.Text())
+	}
+	if done, _ := li.Handle(KeyEnter); !done {
+		t.Error("expected Enter to submit")
+	}
+
+	var buf bytes.Buffer
+	li.Render(&buf)
+	if !bytes.Contains(buf.Bytes(), []byte("goto: v1.2")) {
+		t.Errorf("expected rendered prompt, got %q", buf.String())
+	}
+}
+
+func TestLineInput_Cancel(t *testing.T) {
+	li := NewLineInput("/", "")
+	if _, cancel := li.Handle(KeyEsc); !cancel {
+		t.Error("expected Esc to cancel")
+	}
+	li = NewLineInput("/", "")
+	if _, cancel := li.Handle(KeyBackspace); !cancel {
+		t.Error("expected backspace on empty input to cancel")
+	}
+}
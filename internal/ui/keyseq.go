@@ -0,0 +1,111 @@
+package ui
+
+import "strconv"
+
+// maxCount bounds count prefixes so a held-down digit can't overflow.
+const maxCount = 99999
+
+// Command is an action with an optional vim-style count prefix, e.g. "10n".
+type Command struct {
+	Count  int // 0 when no count was typed
+	Action Action
+	Arg    Key // the key typed after an action that takes one, e.g. the mark name
+}
+
+// N returns the count, defaulting to 1.
+func (c Command) N() int {
+	if c.Count == 0 {
+		return 1
+	}
+	return c.Count
+}
+
+func (c Command) String() string {
+	s := string(c.Action)
+	if c.Count > 0 {
+		s = strconv.Itoa(c.Count) + " " + s
+	}
+	if c.Arg != "" {
+		s += " " + string(c.Arg)
+	}
+	return s
+}
+
+// KeyParser assembles keys into commands through a keymap. Digits build up
+// a count (a leading 0 is an ordinary key); then a key sequence bound to
+// an action completes the command, after one more key if the action takes
+// an argument. Esc discards a pending count or sequence.
+//
+// A sequence that is bound and also starts a longer one ("g" and "gg")
+// waits for the next key: if that doesn't continue the longer one, the
+// shorter sequence's action runs, with the key as its argument if it takes
+// one, or else followed by whatever the key is bound to.
+type KeyParser struct {
+	Keymap *Keymap // nil for the default keymap
+
+	count int
+	seq   []Key  // keys of an incomplete sequence
+	arg   Action // action waiting for its argument, or ""
+}
+
+// Feed adds a key and returns the commands it completes, usually none or
+// one. Keys that aren't bound to anything are dropped.
+func (p *KeyParser) Feed(k Key) []Command {
+	km := p.Keymap.orDefault()
+	switch {
+	case p.arg != "":
+		cmd := Command{Count: p.count, Action: p.arg, Arg: k}
+		p.reset()
+		return []Command{cmd}
+	case len(p.seq) == 0 && len(k) == 1 && k[0] >= '0' && k[0] <= '9' && (k[0] != '0' || p.count > 0):
+		p.count = min(p.count*10+int(k[0]-'0'), maxCount)
+		return nil
+	case k == KeyEsc && (p.count > 0 || len(p.seq) > 0):
+		p.reset()
+		return nil
+	}
+
+	seq := append(p.seq[:len(p.seq):len(p.seq)], k)
+	if km.prefixes[seqID(seq)] {
+		p.seq = seq
+		return nil
+	}
+	if a, ok := km.actions[seqID(seq)]; ok {
+		return p.complete(a)
+	}
+	// The sequence leads nowhere. If the keys before k were bound
+	// themselves, they were the command.
+	if a, ok := km.actions[seqID(p.seq)]; ok && len(p.seq) > 0 {
+		if argActions[a] {
+			cmd := Command{Count: p.count, Action: a, Arg: k}
+			p.reset()
+			return []Command{cmd}
+		}
+		return append(p.complete(a), p.Feed(k)...)
+	}
+	p.reset()
+	return nil
+}
+
+// complete ends the sequence bound to a, or waits for a's argument.
+func (p *KeyParser) complete(a Action) []Command {
+	if argActions[a] {
+		p.seq, p.arg = nil, a
+		return nil
+	}
+	cmd := Command{Count: p.count, Action: a}
+	p.reset()
+	return []Command{cmd}
+}
+
+func (p *KeyParser) reset() {
+	p.count, p.seq, p.arg = 0, nil, ""
+}
+
+// Pending returns the count typed so far, or "" if none.
+func (p *KeyParser) Pending() string {
+	if p.count == 0 {
+		return ""
+	}
+	return strconv.Itoa(p.count)
+}
@@ -0,0 +1,109 @@
+package ui
+
+import "testing"
+
+func feedAll(p *KeyParser, keys ...Key) []Command {
+	var cmds []Command
+	for _, k := range keys {
+		cmds = append(cmds, p.Feed(k)...)
+	}
+	return cmds
+}
+
+func TestKeyParser(t *testing.T) {
+	tests := []struct {
+		name string
+		keys []Key
+		want []Command
+	}{
+		{"plain key", []Key{"n"}, []Command{{0, ActionNext, ""}}},
+		{"count", []Key{"1", "0", "n"}, []Command{{10, ActionNext, ""}}},
+		{"count then special key", []Key{"3", KeyDown}, []Command{{3, ActionScrollDown, ""}}},
+		{"leading zero is a key", []Key{"0", "n"}, []Command{{0, ActionNext, ""}}},
+		{"zero inside count", []Key{"2", "0", "0", "G"}, []Command{{200, ActionLast, ""}}},
+		{"esc cancels count", []Key{"5", KeyEsc, "p"}, []Command{{0, ActionPrev, ""}}},
+		{"unbound keys are dropped", []Key{KeyEsc, "x", "p"}, []Command{{0, ActionPrev, ""}}},
+		{"count resets", []Key{"5", "p", "p"}, []Command{{5, ActionPrev, ""}, {0, ActionPrev, ""}}},
+		{"sequence", []Key{"g", "g"}, []Command{{0, ActionFirst, ""}}},
+		{"argument", []Key{"m", "a"}, []Command{{0, ActionSetMark, "a"}}},
+		{"digit argument", []Key{"'", "1"}, []Command{{0, ActionJumpToMark, "1"}}},
+		{"bound prefix takes argument", []Key{"g", "5"}, []Command{{0, ActionGoTo, "5"}}},
+		{"esc cancels sequence", []Key{"g", KeyEsc, "n"}, []Command{{0, ActionNext, ""}}},
+	}
+	for _, tt := range tests {
+		var p KeyParser
+		got := feedAll(&p, tt.keys...)
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+			}
+		}
+	}
+}
+
+func TestKeyParser_BoundPrefixRunsBeforeNextKey(t *testing.T) {
+	km, err := NewKeymap("", map[string][]string{"toggle-info": {"d d"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := KeyParser{Keymap: km}
+	got := feedAll(&p, "d", "n")
+	want := []Command{{0, ActionToggleDiff, ""}, {0, ActionNext, ""}}
+	if len(got) != 2 || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+	if got := feedAll(&p, "d", "d"); len(got) != 1 || got[0].Action != ActionToggleInfo {
+		t.Errorf("expected toggle-info, got %v", got)
+	}
+}
+
+func TestKeyParser_UnboundSequenceIsDropped(t *testing.T) {
+	km, err := NewKeymap("emacs", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := KeyParser{Keymap: km}
+	if got := feedAll(&p, "ctrl+x", "q"); len(got) != 0 {
+		t.Errorf("expected nothing for an unbound sequence, got %v", got)
+	}
+	if got := feedAll(&p, "ctrl+x", "r", "m", "a"); len(got) != 1 || got[0] != (Command{0, ActionSetMark, "a"}) {
+		t.Errorf("expected set-mark a, got %v", got)
+	}
+}
+
+func TestKeyParser_Pending(t *testing.T) {
+	var p KeyParser
+	p.Feed("1")
+	p.Feed("2")
+	if got := p.Pending(); got != "12" {
+		t.Errorf("expected pending 12, got %q", got)
+	}
+	p.Feed("j")
+	if got := p.Pending(); got != "" {
+		t.Errorf("expected nothing pending, got %q", got)
+	}
+}
+
+func TestKeyParser_CountIsBounded(t *testing.T) {
+	var p KeyParser
+	for i := 0; i < 12; i++ {
+		p.Feed("9")
+	}
+	cmds := p.Feed("n")
+	if len(cmds) != 1 || cmds[0].Count != maxCount {
+		t.Errorf("expected count capped at %d, got %v", maxCount, cmds)
+	}
+}
+
+func TestCommand_N(t *testing.T) {
+	if (Command{Action: ActionNext}).N() != 1 {
+		t.Error("expected default count 1")
+	}
+	if (Command{Count: 4, Action: ActionNext}).N() != 4 {
+		t.Error("expected count 4")
+	}
+}
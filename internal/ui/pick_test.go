@@ -2,6 +2,10 @@ package ui
 
 import (
 	"bytes"
+	"errors"
+	"io"
+	"os"
+	"strings"
 	"testing"
 
 	"github.com/anuchito/replay/internal/navigator"
@@ -18,7 +22,7 @@ func TestPickCommit_Enter(t *testing.T) {
 	input := bytes.NewReader([]byte{'j', '\r'})
 	var output bytes.Buffer
 
-	commit, err := PickCommit(commits, input, &output, 10)
+	commit, err := PickCommit(commits, NewKeyReader(input).Events(), &output, PickOptions{PageSize: 10})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -38,7 +42,7 @@ func TestPickCommit_Quit(t *testing.T) {
 	input := bytes.NewReader([]byte{'q'})
 	var output bytes.Buffer
 
-	commit, err := PickCommit(commits, input, &output, 10)
+	commit, err := PickCommit(commits, NewKeyReader(input).Events(), &output, PickOptions{PageSize: 10})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -58,7 +62,7 @@ func TestPickCommit_VimKeys(t *testing.T) {
 	input := bytes.NewReader([]byte{'j', 'j', 'k', '\r'})
 	var output bytes.Buffer
 
-	commit, err := PickCommit(commits, input, &output, 10)
+	commit, err := PickCommit(commits, NewKeyReader(input).Events(), &output, PickOptions{PageSize: 10})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -80,11 +84,11 @@ func TestPickCommit_ArrowKeys(t *testing.T) {
 		0x1b, '[', 'B', // down
 		0x1b, '[', 'B', // down
 		0x1b, '[', 'A', // up
-		'\r',           // enter
+		'\r', // enter
 	})
 	var output bytes.Buffer
 
-	commit, err := PickCommit(commits, input, &output, 10)
+	commit, err := PickCommit(commits, NewKeyReader(input).Events(), &output, PickOptions{PageSize: 10})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -92,3 +96,226 @@ func TestPickCommit_ArrowKeys(t *testing.T) {
 		t.Errorf("expected def5678, got %s", commit.Hash)
 	}
 }
+
+func TestPickCommit_Search(t *testing.T) {
+	commits := []navigator.Commit{
+		{Hash: "abc1234", Message: "first commit"},
+		{Hash: "def5678", Message: "fix second"},
+		{Hash: "ghi9012", Message: "third commit"},
+		{Hash: "jkl3456", Message: "fix fourth"},
+	}
+
+	// "/fix" Enter confirms the search (cursor on def5678), N jumps to the
+	// next match, Enter selects it.
+	input := bytes.NewReader([]byte{'/', 'f', 'i', 'x', '\r', 'N', '\r'})
+	var output bytes.Buffer
+
+	commit, err := PickCommit(commits, NewKeyReader(input).Events(), &output, PickOptions{PageSize: 10})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if commit == nil || commit.Hash != "jkl3456" {
+		t.Fatalf("expected jkl3456, got %+v", commit)
+	}
+	if !bytes.Contains(output.Bytes(), []byte("match 2/2")) {
+		t.Errorf("expected match counter in output, got %q", output.String())
+	}
+}
+
+func TestPickCommit_SearchCancelRestoresCursor(t *testing.T) {
+	commits := []navigator.Commit{
+		{Hash: "abc1234", Message: "first commit"},
+		{Hash: "def5678", Message: "second commit"},
+		{Hash: "ghi9012", Message: "third commit"},
+	}
+
+	input := bytes.NewReader([]byte{'j', '/', 't', 'h', 0x1b})
+	rest := bytes.NewReader([]byte{'\r'})
+	var output bytes.Buffer
+
+	commit, err := PickCommit(commits, NewKeyReader(io.MultiReader(input, rest)).Events(), &output, PickOptions{PageSize: 10})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if commit.Hash != "def5678" {
+		t.Errorf("expected cursor restored to def5678, got %s", commit.Hash)
+	}
+}
+
+func TestPickCommit_ResizeRedraws(t *testing.T) {
+	commits := []navigator.Commit{
+		{Hash: "abc1234", Message: "first commit"},
+		{Hash: "def5678", Message: "second commit"},
+		{Hash: "ghi9012", Message: "third commit"},
+		{Hash: "jkl3456", Message: "fourth commit"},
+	}
+	termW, termH := 80, 24
+	size := func() (int, int) { return termW, termH }
+	keys := make(chan KeyEvent)
+	resized := make(chan os.Signal)
+	var output bytes.Buffer
+
+	done := make(chan *navigator.Commit)
+	go func() {
+		commit, _ := PickCommit(commits, keys, &output, PickOptions{PageSize: 10, Size: size, Resized: resized})
+		done <- commit
+	}()
+	keys <- KeyEvent{Key: "j"}
+	keys <- KeyEvent{Key: "j"}
+	termW, termH = 20, 6 // room for two commits below the header
+	resized <- os.Interrupt
+	keys <- KeyEvent{Key: KeyEnter}
+	commit := <-done
+
+	if commit == nil || commit.Hash != "ghi9012" {
+		t.Fatalf("expected ghi9012, got %+v", commit)
+	}
+	_, redraw, ok := strings.Cut(output.String(), "\x1b[2J\x1b[H")
+	if !ok {
+		t.Fatalf("expected a full redraw after the resize, got %q", output.String())
+	}
+	for _, line := range strings.Split(redraw, "\r\n") {
+		if w := StringWidth(ansiRe.ReplaceAllString(line, "")); w > 20 {
+			t.Errorf("expected lines of at most 20 columns, got %d: %q", w, line)
+		}
+	}
+	if strings.Contains(redraw, "abc1234") || !strings.Contains(redraw, "def5678") || !strings.Contains(redraw, "> ghi9012") {
+		t.Errorf("expected the two commits up to the cursor, got %q", redraw)
+	}
+}
+
+func TestPickCommit_Filter(t *testing.T) {
+	commits := []navigator.Commit{
+		{Hash: "abc1234", Message: "add parser"},
+		{Hash: "def5678", Message: "fix race in watcher"},
+		{Hash: "ghi9012", Message: "docs"},
+		{Hash: "jkl3456", Message: "fix typo in parser"},
+	}
+
+	// "f" opens the filter; "fxq" matches nothing, backspace makes it
+	// "fx", arrow down moves to the second of the two fixes, Enter keeps
+	// the filter and Enter again selects.
+	input := bytes.NewReader([]byte("ffxq\x7f\x1b[B\r\r"))
+	var output bytes.Buffer
+	commit, err := PickCommit(commits, NewKeyReader(input).Events(), &output, PickOptions{PageSize: 10})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if commit == nil || commit.Hash != "jkl3456" {
+		t.Fatalf("expected jkl3456, got %+v", commit)
+	}
+	for _, want := range []string{"filter: fxq  0/4", "filter: fx  2/4"} {
+		if !strings.Contains(output.String(), want) {
+			t.Errorf("expected %q in output", want)
+		}
+	}
+}
+
+func TestPickCommit_FilterEscClears(t *testing.T) {
+	commits := []navigator.Commit{
+		{Hash: "abc1234", Message: "add parser"},
+		{Hash: "def5678", Message: "docs"},
+	}
+
+	// Esc clears the filter: j then moves from abc1234 to def5678.
+	input := bytes.NewReader([]byte{'f', 'p', 'a', 'r', 0x1b})
+	rest := bytes.NewReader([]byte{'j', '\r'})
+	var output bytes.Buffer
+	commit, err := PickCommit(commits, NewKeyReader(io.MultiReader(input, rest)).Events(), &output, PickOptions{PageSize: 10})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if commit == nil || commit.Hash != "def5678" {
+		t.Fatalf("expected def5678, got %+v", commit)
+	}
+}
+
+func TestPickRange(t *testing.T) {
+	commits := sampleCommits()
+
+	// v on the second commit, down two, Enter.
+	start, end, err := PickRange(commits, NewKeyReader(bytes.NewReader([]byte("jvjj\r"))).Events(), io.Discard, PickOptions{PageSize: 10})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if start == nil || end == nil || start.Hash != "jkl3456" || end.Hash != "def5678" {
+		t.Errorf("expected jkl3456..def5678, got %v..%v", start, end)
+	}
+
+	// Without a range, the end is left to the caller.
+	start, end, _ = PickRange(commits, NewKeyReader(bytes.NewReader([]byte("j\r"))).Events(), io.Discard, PickOptions{PageSize: 10})
+	if start == nil || start.Hash != "def5678" || end != nil {
+		t.Errorf("expected def5678 and no end, got %v..%v", start, end)
+	}
+
+	// A range CheckRange refuses is reported, and another can be picked.
+	var checked []string
+	check := func(start, end navigator.Commit) error {
+		checked = append(checked, start.Hash+".."+end.Hash)
+		if start.Hash == "mno7890" {
+			return errors.New("not an ancestor")
+		}
+		return nil
+	}
+	var out bytes.Buffer
+	start, end, _ = PickRange(commits, NewKeyReader(bytes.NewReader([]byte("vjjjj\rk\r"))).Events(), &out, PickOptions{PageSize: 10, CheckRange: check})
+	if start == nil || start.Hash != "jkl3456" || end.Hash != "abc1234" {
+		t.Errorf("expected jkl3456..abc1234 after the first range was refused, got %v..%v", start, end)
+	}
+	if strings.Join(checked, " ") != "mno7890..abc1234 jkl3456..abc1234" {
+		t.Errorf("expected both ranges checked, got %v", checked)
+	}
+	if !strings.Contains(out.String(), "Can't replay that range: not an ancestor") {
+		t.Error("expected the refusal in the status line")
+	}
+}
+
+func TestPickCommit_NoRanges(t *testing.T) {
+	commit, err := PickCommit(sampleCommits(), NewKeyReader(bytes.NewReader([]byte("vj\r"))).Events(), io.Discard, PickOptions{PageSize: 10})
+	if err != nil || commit == nil || commit.Hash != "def5678" {
+		t.Errorf("expected v ignored and def5678 picked, got %v (%v)", commit, err)
+	}
+}
+
+func TestPickRef(t *testing.T) {
+	refs := []navigator.Ref{
+		{Name: "main", Kind: navigator.RefBranch, Hash: "abc1234", Subject: "merge login"},
+		{Name: "feature/login", Kind: navigator.RefBranch, Hash: "def5678", Subject: "add login form"},
+		{Name: "origin/main", Kind: navigator.RefRemote, Hash: "abc1234", Subject: "merge login"},
+		{Name: "v1.0", Kind: navigator.RefTag, Hash: "ghi9012", Subject: "release"},
+		{Name: "HEAD@{1}", Kind: navigator.RefReflog, Hash: "jkl3456", Subject: "checkout: moving from main to feature/login"},
+	}
+
+	// The filter matches the ref's kind and its commit's subject too.
+	var out bytes.Buffer
+	ref, err := PickRef(refs, NewKeyReader(bytes.NewReader([]byte("ftag\r\r"))).Events(), &out, PickOptions{PageSize: 10})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ref == nil || ref.Name != "v1.0" {
+		t.Errorf("expected v1.0, got %+v", ref)
+	}
+	if !strings.Contains(out.String(), "Select a branch, tag or reflog entry") {
+		t.Error("expected the ref picker's title")
+	}
+
+	ref, _ = PickRef(refs, NewKeyReader(bytes.NewReader([]byte("jjjj\r"))).Events(), io.Discard, PickOptions{PageSize: 10})
+	if ref == nil || ref.Rev() != "jkl3456" {
+		t.Errorf("expected the reflog entry, by its hash, got %+v", ref)
+	}
+
+	// A branch and a tag of the same name are told apart.
+	same := []navigator.Ref{
+		{Name: "v1.0", Kind: navigator.RefBranch, Hash: "abc1234"},
+		{Name: "v1.0", Kind: navigator.RefTag, Hash: "def5678"},
+	}
+	ref, _ = PickRef(same, NewKeyReader(bytes.NewReader([]byte("j\r"))).Events(), io.Discard, PickOptions{PageSize: 10})
+	if ref == nil || ref.Kind != navigator.RefTag || ref.Hash != "def5678" {
+		t.Errorf("expected the tag v1.0, got %+v", ref)
+	}
+
+	ref, err = PickRef(refs, NewKeyReader(bytes.NewReader([]byte("q"))).Events(), io.Discard, PickOptions{PageSize: 10})
+	if ref != nil || err != nil {
+		t.Errorf("expected nil on quit, got %+v (%v)", ref, err)
+	}
+}
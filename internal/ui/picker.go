@@ -3,28 +3,133 @@ package ui
 import (
 	"fmt"
 	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/anuchito/replay/internal/navigator"
 )
 
+// Picker is a scrolling list of commits. A fuzzy filter narrows the list
+// to the commits it matches; the cursor, the scroll offset and search
+// matches are all positions in that filtered view.
 type Picker struct {
 	commits  []navigator.Commit
+	view     []int                // indices of the commits shown, in order
+	hits     []navigator.FuzzyHit // where the filter matched each shown commit
 	cursor   int
 	offset   int
 	pageSize int
+	width    int // terminal columns; lines are cut to fit, 0 for no limit
+
+	filter  navigator.Fuzzy
+	query   navigator.Query
+	matches []int
+	status  string // shown on the line below the list instead of describe()
+
+	more    bool // older commits may be left to load
+	loading bool // and are being loaded
+
+	anchor int     // index in commits where a range was marked, -1 for none
+	keys   *Keymap // for the status line; nil for the default
+
+	// countRange counts the commits of a range; nil counts the rows from
+	// one end to the other. counted caches its last result.
+	countRange func(start, end navigator.Commit) (int, error)
+	counted    struct {
+		start, end string
+		n          int
+	}
 }
 
 func NewPicker(commits []navigator.Commit, pageSize int) *Picker {
-	return &Picker{
+	p := &Picker{
 		commits:  commits,
 		cursor:   0,
 		offset:   0,
 		pageSize: pageSize,
+		anchor:   -1,
+	}
+	p.showAll()
+	return p
+}
+
+func (p *Picker) showAll() {
+	p.view, p.hits = make([]int, len(p.commits)), nil
+	for i := range p.view {
+		p.view[i] = i
+	}
+}
+
+// Shown returns the number of commits the filter lets through.
+func (p *Picker) Shown() int {
+	return len(p.view)
+}
+
+// SetFilter shows only the commits matching f and returns how many there
+// are. The cursor stays on the same commit if it is still shown, or else
+// moves to the next one down that is.
+func (p *Picker) SetFilter(f navigator.Fuzzy) int {
+	current := -1
+	if len(p.view) > 0 {
+		current = p.view[p.cursor]
+	}
+	p.filter = f
+	if f.Empty() {
+		p.showAll()
+	} else {
+		p.view, p.hits = navigator.FilterCommits(p.commits, f)
+	}
+	p.cursor, p.offset = max(len(p.view)-1, 0), 0
+	for row, i := range p.view {
+		if i >= current {
+			p.cursor = row
+			break
+		}
 	}
+	p.MoveTo(p.cursor)
+	p.matches = navigator.SearchCommits(p.shown(), p.query)
+	return len(p.view)
+}
+
+// Append adds older commits to the end of the list. Those the filter
+// lets through join the view, below the ones already there.
+func (p *Picker) Append(commits []navigator.Commit) {
+	start := len(p.commits)
+	p.commits = append(p.commits, commits...)
+	if p.filter.Empty() {
+		for i := range commits {
+			p.view = append(p.view, start+i)
+		}
+	} else {
+		indices, hits := navigator.FilterCommits(commits, p.filter)
+		for _, i := range indices {
+			p.view = append(p.view, start+i)
+		}
+		p.hits = append(p.hits, hits...)
+	}
+	p.matches = navigator.SearchCommits(p.shown(), p.query)
+}
+
+// needsMore reports whether to load older commits: when there may be
+// some and the cursor is within a page of the end of the view.
+func (p *Picker) needsMore() bool {
+	return p.more && !p.loading && p.cursor >= len(p.view)-p.pageSize
+}
+
+// shown returns the commits in the view.
+func (p *Picker) shown() []navigator.Commit {
+	commits := make([]navigator.Commit, len(p.view))
+	for row, i := range p.view {
+		commits[row] = p.commits[i]
+	}
+	return commits
 }
 
 func (p *Picker) MoveDown() {
-	if p.cursor < len(p.commits)-1 {
+	if p.cursor < len(p.view)-1 {
 		p.cursor++
 		if p.cursor >= p.offset+p.pageSize {
 			p.offset = p.cursor - p.pageSize + 1
@@ -55,8 +160,150 @@ func (p *Picker) HalfPageUp() {
 	}
 }
 
+// PageDown and PageUp move the cursor a whole page.
+func (p *Picker) PageDown() {
+	for range p.pageSize {
+		p.MoveDown()
+	}
+}
+
+func (p *Picker) PageUp() {
+	for range p.pageSize {
+		p.MoveUp()
+	}
+}
+
+// MoveTo places the cursor on row index of the view, scrolling it into
+// view.
+func (p *Picker) MoveTo(index int) {
+	if index < 0 || index >= len(p.view) {
+		return
+	}
+	p.cursor = index
+	if p.cursor < p.offset {
+		p.offset = p.cursor
+	}
+	if p.cursor >= p.offset+p.pageSize {
+		p.offset = p.cursor - p.pageSize + 1
+	}
+}
+
+// SetQuery sets the active search and returns the number of matches.
+func (p *Picker) SetQuery(q navigator.Query) int {
+	p.query = q
+	p.matches = navigator.SearchCommits(p.shown(), q)
+	return len(p.matches)
+}
+
+// NextMatch moves to the next search match below the cursor, wrapping around.
+func (p *Picker) NextMatch() bool {
+	i, ok := navigator.NextMatch(p.matches, p.cursor)
+	if ok {
+		p.MoveTo(i)
+	}
+	return ok
+}
+
+// PrevMatch moves to the previous search match above the cursor, wrapping around.
+func (p *Picker) PrevMatch() bool {
+	i, ok := navigator.PrevMatch(p.matches, p.cursor)
+	if ok {
+		p.MoveTo(i)
+	}
+	return ok
+}
+
+// scroll applies a cursor movement action, reporting whether a is one.
+func (p *Picker) scroll(a Action) bool {
+	switch a {
+	case ActionScrollDown:
+		p.MoveDown()
+	case ActionScrollUp:
+		p.MoveUp()
+	case ActionScrollHalfDown:
+		p.HalfPageDown()
+	case ActionScrollHalfUp:
+		p.HalfPageUp()
+	case ActionScrollPageDown:
+		p.PageDown()
+	case ActionScrollPageUp:
+		p.PageUp()
+	default:
+		return false
+	}
+	return true
+}
+
+// Selected returns the commit under the cursor. The view must not be
+// empty.
 func (p *Picker) Selected() navigator.Commit {
-	return p.commits[p.cursor]
+	return p.commits[p.view[p.cursor]]
+}
+
+// at returns a copy of commit i, nil for -1.
+func (p *Picker) at(i int) *navigator.Commit {
+	if i < 0 {
+		return nil
+	}
+	c := p.commits[i]
+	return &c
+}
+
+// MarkRange starts a range at the commit under the cursor, or drops the
+// range if one is marked. The view must not be empty.
+func (p *Picker) MarkRange() {
+	if p.anchor >= 0 {
+		p.anchor = -1
+		return
+	}
+	p.anchor = p.view[p.cursor]
+}
+
+// Range returns the marked range, from where it was marked to the commit
+// under the cursor: its oldest and newest commits and how many commits
+// replaying it covers. Without a way to count them, that is how many the
+// list has from one to the other, hidden ones included. ok is false if no
+// range is marked or the view is empty.
+func (p *Picker) Range() (start, end navigator.Commit, n int, ok bool) {
+	if p.anchor < 0 || len(p.view) == 0 {
+		return navigator.Commit{}, navigator.Commit{}, 0, false
+	}
+	newest, oldest := p.span()
+	start, end = p.commits[oldest], p.commits[newest]
+	return start, end, p.count(start, end, oldest-newest+1), true
+}
+
+// count returns countRange's count of the range from start to end, or
+// rows if there is no countRange or it fails.
+func (p *Picker) count(start, end navigator.Commit, rows int) int {
+	if p.countRange == nil {
+		return rows
+	}
+	if p.counted.start != start.Rev() || p.counted.end != end.Rev() {
+		n, err := p.countRange(start, end)
+		if err != nil {
+			return rows
+		}
+		p.counted.start, p.counted.end, p.counted.n = start.Rev(), end.Rev(), n
+	}
+	return p.counted.n
+}
+
+// span returns the indices in commits of the range's newest and oldest
+// commits; the list is newest first.
+func (p *Picker) span() (newest, oldest int) {
+	cur := p.view[p.cursor]
+	return min(p.anchor, cur), max(p.anchor, cur)
+}
+
+// inRange reports whether the commit at row of the view is in the marked
+// range.
+func (p *Picker) inRange(row int) bool {
+	if p.anchor < 0 {
+		return false
+	}
+	newest, oldest := p.span()
+	return p.view[row] >= newest && p.view[row] <= oldest
 }
 
 func (p *Picker) Render(w io.Writer) {
@@ -64,104 +311,734 @@ func (p *Picker) Render(w io.Writer) {
 	fmt.Fprintln(w, "j/↓ down  k/↑ up  Enter select  q quit")
 	fmt.Fprintln(w)
 
-	end := p.offset + p.pageSize
-	if end > len(p.commits) {
-		end = len(p.commits)
+	for row := p.offset; row < p.offset+p.visibleLines(); row++ {
+		fmt.Fprintf(w, "%s\n", p.line(row))
+	}
+}
+
+// line formats the list entry at row of the view: the hash, the subject
+// and the author, with what the filter and the search matched marked.
+func (p *Picker) line(row int) string {
+	c := p.commits[p.view[row]]
+	var hit navigator.FuzzyHit
+	if row < len(p.hits) {
+		hit = p.hits[row]
+	}
+	room := p.width - StringWidth("> "+c.Hash+" ")
+	marker := "  "
+	switch {
+	case row == p.cursor:
+		marker = "> "
+	case p.inRange(row):
+		marker = styleAccent + "│" + styleReset + " "
+	}
+	msg := c.Message
+	if p.width > 0 {
+		msg = Truncate(msg, max(room, 1))
+	}
+	var spans [][]int
+	if p.query.Text != nil {
+		spans = p.query.Text.FindAllStringIndex(msg, -1)
+	}
+	line := marker + markMatches(c.Hash, hit.Hash, nil) + " " + markMatches(msg, hit.Message, spans)
+
+	author := "  " + c.Author
+	room -= StringWidth(msg)
+	if c.Author == "" || p.width > 0 && room <= len("  ") {
+		return line
 	}
+	if p.width > 0 {
+		author = Truncate(author, room)
+	}
+	at := make([]int, len(hit.Author))
+	for i, pos := range hit.Author {
+		at[i] = pos + len("  ")
+	}
+	return line + styleMuted + markMatches(author, at, nil) + styleReset
+}
 
-	for i := p.offset; i < end; i++ {
-		c := p.commits[i]
-		if i == p.cursor {
-			fmt.Fprintf(w, "> %s %s\n", c.Hash, c.Message)
+// markMatches shows the characters of s that start at the byte offsets in
+// at, and the byte ranges in spans, in the theme's match style. Offsets
+// past the end of s, cut off by Truncate, are left out.
+func markMatches(s string, at []int, spans [][]int) string {
+	if len(at) == 0 && len(spans) == 0 {
+		return s
+	}
+	marked := make([]bool, len(s))
+	for _, i := range at {
+		if i < len(s) {
+			_, n := utf8.DecodeRuneInString(s[i:])
+			for j := i; j < i+n; j++ {
+				marked[j] = true
+			}
+		}
+	}
+	for _, sp := range spans {
+		for j := sp[0]; j < sp[1]; j++ {
+			marked[j] = true
+		}
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		j := i
+		for j < len(s) && marked[j] == marked[i] {
+			j++
+		}
+		if marked[i] {
+			b.WriteString(styleMatch + s[i:j] + styleMatchOff)
 		} else {
-			fmt.Fprintf(w, "  %s %s\n", c.Hash, c.Message)
+			b.WriteString(s[i:j])
 		}
+		i = j
 	}
+	return b.String()
 }
 
 // visibleLines returns how many commit lines are currently displayed.
 func (p *Picker) visibleLines() int {
-	end := p.offset + p.pageSize
-	if end > len(p.commits) {
-		end = len(p.commits)
+	return max(min(p.offset+p.pageSize, len(p.view))-p.offset, 0)
+}
+
+// height returns the number of lines the list takes up: a page, or all
+// commits if there are fewer and no more to load. It doesn't shrink with
+// the filter, so that the status line stays put.
+func (p *Picker) height() int {
+	if p.more {
+		return p.pageSize
 	}
-	return end - p.offset
+	return min(p.pageSize, len(p.commits))
 }
 
 // renderRaw writes the picker list for raw terminal mode using \r\n.
 func (p *Picker) renderRaw(w io.Writer) {
-	end := p.offset + p.pageSize
-	if end > len(p.commits) {
-		end = len(p.commits)
+	for _, line := range p.lines() {
+		fmt.Fprintf(w, "\x1b[2K%s\r\n", line)
 	}
+	fmt.Fprintf(w, "\x1b[2K%s\r\n", Truncate(p.statusLine(), p.width))
+}
 
-	for i := p.offset; i < end; i++ {
-		c := p.commits[i]
-		if i == p.cursor {
-			fmt.Fprintf(w, "\x1b[2K> %s %s\r\n", c.Hash, c.Message)
-		} else {
-			fmt.Fprintf(w, "\x1b[2K  %s %s\r\n", c.Hash, c.Message)
+// lines returns the list's height() lines, blank below the last commit.
+func (p *Picker) lines() []string {
+	lines := make([]string, 0, p.height())
+	for row := p.offset; row < p.offset+p.height(); row++ {
+		line := ""
+		if row < len(p.view) {
+			line = p.line(row)
 		}
+		lines = append(lines, line)
 	}
+	return lines
 }
 
-// PickCommit runs an interactive picker loop.
-// Returns the selected commit, or nil if the user quits.
-func PickCommit(commits []navigator.Commit, in io.Reader, out io.Writer, pageSize int) (*navigator.Commit, error) {
-	p := NewPicker(commits, pageSize)
+// statusLine is the line below the list: the status override, or else
+// the filter and search, and whether more commits are loading.
+func (p *Picker) statusLine() string {
+	status := p.status
+	if status == "" {
+		status = p.describe()
+	}
+	if p.loading {
+		status = strings.TrimPrefix(status+"  loading more commits…", "  ")
+	}
+	return status
+}
 
-	// Print header (stays fixed)
-	fmt.Fprint(out, "Select a commit to replay from:\r\n")
-	fmt.Fprint(out, "j/↓ down  k/↑ up  ^D half-page down  ^U half-page up  Enter select  q quit\r\n")
-	fmt.Fprint(out, "\r\n")
+// describe sums up the active filter and search for the status line.
+func (p *Picker) describe() string {
+	var parts []string
+	if start, end, n, ok := p.Range(); ok {
+		parts = append(parts, fmt.Sprintf("range %s..%s  %d commit%s", start.Hash, end.Hash, n, plural(n)))
+	}
+	if !p.filter.Empty() {
+		parts = append(parts, p.filterStatus())
+	}
+	if !p.query.Empty() {
+		parts = append(parts, p.matchStatus())
+	}
+	return strings.Join(parts, "  ")
+}
 
-	// Initial render
-	p.renderRaw(out)
+// filterStatus shows the filter and how many of the commits loaded so far
+// it lets through.
+func (p *Picker) filterStatus() string {
+	total := fmt.Sprint(len(p.commits))
+	if p.more {
+		total += "+"
+	}
+	return fmt.Sprintf("filter: %s  %d/%s", p.filter.Raw, len(p.view), total)
+}
+
+// matchStatus describes the active search for the status line.
+func (p *Picker) matchStatus() string {
+	if p.query.Empty() {
+		return ""
+	}
+	status := fmt.Sprintf("/%s  %d matches", p.query.Raw, len(p.matches))
+	if n := navigator.MatchNumber(p.matches, p.cursor); n > 0 {
+		status = fmt.Sprintf("/%s  match %d/%d", p.query.Raw, n, len(p.matches))
+	}
+	if keys := p.keys.orDefault().hints(hintFor("next/prev", ActionNextMatch, ActionPrevMatch)); keys != "" {
+		status += "  " + keys
+	}
+	return status
+}
 
-	oneByte := make([]byte, 1)
+// search runs the incremental "/" prompt. The cursor follows the first match
+// at or below where the search started; Esc restores the previous state.
+func (p *Picker) search(t *pickTerm) error {
+	origin, prevQuery := p.cursor, p.query
+	li := NewLineInput("/", "")
+	redraw := func() {
+		p.status = "/" + li.Text()
+		t.redraw()
+	}
+	redraw()
 	for {
-		_, err := in.Read(oneByte)
+		k, err := t.readKey()
 		if err != nil {
-			return nil, err
-		}
-
-		switch oneByte[0] {
-		case 'j':
-			p.MoveDown()
-		case 'k':
-			p.MoveUp()
-		case 4: // Ctrl+D — half page down
-			p.HalfPageDown()
-		case 21: // Ctrl+U — half page up
-			p.HalfPageUp()
-		case '\r', '\n':
-			selected := p.Selected()
-			return &selected, nil
-		case 'q':
-			return nil, nil
-		case 3: // Ctrl+C
-			return nil, nil
-		case 0x1b: // escape sequence (arrow keys)
-			seq := make([]byte, 2)
-			_, err := io.ReadFull(in, seq)
-			if err != nil {
-				continue
+			return err
+		}
+		done, cancel := li.Handle(k)
+		if cancel {
+			p.SetQuery(prevQuery)
+			p.MoveTo(origin)
+			p.status = ""
+			return nil
+		}
+		q, err := navigator.ParseQuery(li.Text())
+		if err == nil {
+			p.SetQuery(q)
+			p.MoveTo(origin)
+			if n := navigator.MatchNumber(p.matches, origin); n == 0 {
+				p.NextMatch()
 			}
-			if seq[0] == '[' {
-				switch seq[1] {
-				case 'A': // arrow up
-					p.MoveUp()
-				case 'B': // arrow down
-					p.MoveDown()
+		}
+		if done {
+			p.status = ""
+			return nil
+		}
+		redraw()
+	}
+}
+
+// filterPrompt runs the fuzzy filter prompt. The list narrows as the
+// filter is typed, and keys bound to moving the cursor that can't be part
+// of it still move it. Enter keeps the filter; Esc clears it.
+func (p *Picker) filterPrompt(t *pickTerm, parser *KeyParser) error {
+	li := NewLineInput("filter: ", p.filter.Raw)
+	for {
+		p.status = ""
+		if p.filter.Empty() {
+			p.status = li.Label + li.Text()
+		}
+		t.redraw()
+		k, err := t.readKey()
+		if err != nil {
+			return err
+		}
+		if !li.handles(k) {
+			for _, cmd := range parser.Feed(k) {
+				if !p.scroll(cmd.Action) {
+					t.scrollPreview(cmd.Action)
 				}
 			}
-		default:
 			continue
 		}
+		done, cancel := li.Handle(k)
+		if cancel {
+			p.SetFilter(navigator.Fuzzy{})
+			p.status = ""
+			return nil
+		}
+		p.SetFilter(navigator.ParseFuzzy(li.Text()))
+		t.loadMore()
+		if done {
+			p.status = ""
+			return nil
+		}
+	}
+}
+
+// Resize lays the list out again for pageSize lines of width columns,
+// scrolling as little as possible to keep the cursor in view and the page
+// full.
+func (p *Picker) Resize(pageSize, width int) {
+	p.pageSize, p.width = max(pageSize, 1), width
+	p.offset = min(p.offset, max(len(p.view)-p.pageSize, 0))
+	if p.cursor >= p.offset+p.pageSize {
+		p.offset = p.cursor - p.pageSize + 1
+	}
+}
+
+// pickerHeader is the number of lines above the list: two lines of header
+// and a blank one. The status line below it makes one more.
+const pickerHeader = 3
+
+// PickOptions configures PickCommit.
+type PickOptions struct {
+	PageSize int                        // most commits shown at once
+	Size     func() (width, height int) // terminal size, zero if unknown; nil for no limit
+	Resized  <-chan os.Signal           // receives when the terminal is resized
+	Keymap   *Keymap                    // nil for the default keymap
+	Title    string                     // first header line, "" for the default
+
+	// Load returns the commits that follow the first skip, for paging in
+	// older history as the cursor nears the end of the list. An empty page
+	// means there are no more. Nil if the commits given are all there is.
+	Load func(skip int) ([]navigator.Commit, error)
+
+	// Diff returns a commit's diff for the preview pane, which is shown
+	// beside the list on terminals at least SplitWidth columns wide (below
+	// it on narrower ones) when there is room for it. Nil for no preview.
+	Diff       func(hash string) ([]string, error)
+	SplitWidth int // 0 for DefaultSplitWidth
+
+	// CheckRange reports why PickRange can't return a range, from its
+	// oldest commit to its newest. Nil accepts every range.
+	CheckRange func(start, end navigator.Commit) error
+
+	// CountRange returns how many commits replaying a range covers, for
+	// the status line. Nil counts the list's rows from one end to the
+	// other, which misses commits merged in from elsewhere.
+	CountRange func(start, end navigator.Commit) (int, error)
+}
+
+// paneLayout is where the preview pane goes.
+type paneLayout int
 
-		// Move cursor up to beginning of list and re-render in place
-		lines := p.visibleLines()
-		fmt.Fprintf(out, "\x1b[%dA", lines) // move up N lines
-		p.renderRaw(out)
+const (
+	paneNone   paneLayout = iota // no preview, or no room for one
+	paneBelow                    // under the status line
+	paneBeside                   // right of the list
+)
+
+// pickTerm connects a Picker to the terminal.
+type pickTerm struct {
+	p      *Picker
+	keys   <-chan KeyEvent
+	out    io.Writer
+	opts   PickOptions
+	loaded chan pickPage // pages from Load, one at a time
+	ranges bool          // ranges can be marked
+
+	pv    *Preview // nil without opts.Diff
+	pane  paneLayout
+	body  int // lines for the list and the pane, beside or below it
+	listW int // columns of the list beside the pane
+
+	due      <-chan time.Time       // fires when the preview's diff is due
+	diffs    chan previewDiff       // diffs from opts.Diff, one at a time
+	fetching bool                   // a diff is being fetched
+	cache    map[string]previewDiff // diffs fetched, by hash
+	recent   []string               // hashes in cache, least recently shown first
+}
+
+// previewDiff is the result of a call to PickOptions.Diff.
+type previewDiff struct {
+	hash  string
+	lines []string
+	err   error
+}
+
+// pickPage is the result of a call to PickOptions.Load.
+type pickPage struct {
+	commits []navigator.Commit
+	err     error
+}
+
+// loadMore starts loading the next page of commits in the background if
+// the picker needs it.
+func (t *pickTerm) loadMore() {
+	if !t.p.needsMore() {
+		return
+	}
+	t.p.loading = true
+	skip := len(t.p.commits)
+	go func() {
+		commits, err := t.opts.Load(skip)
+		t.loaded <- pickPage{commits, err}
+	}()
+}
+
+// receive adds a loaded page to the list, and loads the next one if the
+// cursor is still near the end.
+func (t *pickTerm) receive(pg pickPage) {
+	p := t.p
+	height := p.height()
+	p.loading = false
+	switch {
+	case pg.err != nil:
+		p.more = false
+		p.status = fmt.Sprintf("Can't load more commits: %v", pg.err)
+	case len(pg.commits) == 0:
+		p.more = false
+	default:
+		p.Append(pg.commits)
+	}
+	t.loadMore()
+	if p.height() != height {
+		fmt.Fprint(t.out, "\x1b[2J\x1b[H")
+		t.draw()
+		return
+	}
+	t.redraw()
+}
+
+// fit sizes the list to the terminal: at most PageSize lines, fewer if the
+// terminal is too short for the header, the list and the status line.
+//
+// With the preview on, the picker takes the whole terminal but for a
+// line, so that drawing the last one doesn't scroll the screen. Beside
+// the pane the list takes two fifths of the width and all the lines;
+// above it, a third of the lines and at most PageSize.
+func (t *pickTerm) fit() {
+	t.pane = paneNone
+	if t.opts.Size == nil {
+		t.p.Resize(t.opts.PageSize, 0)
+		return
+	}
+	termW, termH := t.opts.Size()
+	pageSize := t.opts.PageSize
+	if termH > 0 {
+		pageSize = min(pageSize, termH-pickerHeader-1)
+	}
+	if t.pv != nil && t.pv.Active && termW > 0 && termH > 0 {
+		avail := termH - pickerHeader - 2
+		splitWidth := t.opts.SplitWidth
+		if splitWidth == 0 {
+			splitWidth = DefaultSplitWidth
+		}
+		switch list := min(t.opts.PageSize, max(avail/3, 1)); {
+		case termW >= splitWidth && avail >= minPreviewRows:
+			t.pane, t.listW, t.body = paneBeside, termW*2/5, avail
+			t.p.Resize(avail, t.listW-1)
+			return
+		case avail-list >= minPreviewRows:
+			t.pane, t.body = paneBelow, avail
+			t.p.Resize(list, termW)
+			return
+		}
+	}
+	t.p.Resize(pageSize, termW)
+}
+
+// draw prints the header and the list from the cursor's position.
+func (t *pickTerm) draw() {
+	km := t.opts.Keymap.orDefault()
+	pairs := []hint{
+		hintFor("down", ActionScrollDown), hintFor("up", ActionScrollUp),
+		hintFor("half-page down", ActionScrollHalfDown), hintFor("half-page up", ActionScrollHalfUp),
+		hintFor("filter", ActionFilter), hintFor("search", ActionSearch),
+	}
+	if t.pv != nil {
+		pairs = append(pairs, hintFor("preview", ActionToggleDiff))
+	}
+	if t.pane != paneNone {
+		pairs = append(pairs, hintFor("scroll preview", ActionPreviewPageDown, ActionPreviewPageUp))
+	}
+	if t.ranges {
+		pairs = append(pairs, hintFor("range", ActionMarkRange))
+	}
+	pairs = append(pairs, hintFor("select", ActionSelect), hintFor("quit", ActionQuit))
+	width := t.p.width
+	if t.pane == paneBeside {
+		width, _ = t.opts.Size() // the header spans the pane too
+	}
+	title := t.opts.Title
+	if title == "" {
+		title = "Select a commit to replay from:"
+	}
+	fmt.Fprintf(t.out, "%s\r\n", Truncate(title, width))
+	fmt.Fprintf(t.out, "%s\r\n", Truncate(km.hints(pairs...), width))
+	fmt.Fprint(t.out, "\r\n")
+	t.render()
+}
+
+// redraw re-renders the list in place, moving the cursor up to the top of
+// the list (plus the status line and the pane below) first.
+func (t *pickTerm) redraw() {
+	up := t.p.height()
+	if t.pane != paneNone {
+		up = t.body
+	}
+	fmt.Fprintf(t.out, "\x1b[%dA", up+1)
+	t.render()
+}
+
+// render writes the list, the status line and the preview pane, pointing
+// the preview at the commit under the cursor first.
+func (t *pickTerm) render() {
+	if t.pane == paneNone {
+		t.p.renderRaw(t.out)
+		return
+	}
+	t.follow()
+	var c navigator.Commit
+	if t.p.Shown() > 0 {
+		c = t.p.Selected()
+	}
+	termW, _ := t.opts.Size()
+	lines := t.p.lines()
+	if t.pane == paneBeside {
+		lines = append(lines, make([]string, t.body-len(lines))...)
+		pane := t.pv.render(c, termW-t.listW-2, t.body)
+		for i := range lines {
+			// Move to the pane's column rather than pad the list line,
+			// whose escape codes would throw off the count.
+			lines[i] += fmt.Sprintf("\x1b[%dG%s│%s %s", t.listW+1, styleMuted, styleReset, pane[i])
+		}
+	}
+	for _, line := range lines {
+		fmt.Fprintf(t.out, "\x1b[2K%s\r\n", line)
+	}
+	fmt.Fprintf(t.out, "\x1b[2K%s\r\n", Truncate(t.p.statusLine(), termW))
+	if t.pane == paneBelow {
+		for _, line := range t.pv.render(c, termW, t.paneRows()) {
+			fmt.Fprintf(t.out, "\x1b[2K%s\r\n", line)
+		}
+	}
+}
+
+// paneRows is the number of lines of the pane below the list, title
+// included: all the list leaves, since it is shorter with few commits.
+func (t *pickTerm) paneRows() int {
+	return t.body - t.p.height()
+}
+
+// follow points the preview at the commit under the cursor. Its diff is
+// fetched once the cursor has rested there for previewDelay, unless it
+// was fetched before.
+func (t *pickTerm) follow() {
+	hash := ""
+	if t.p.Shown() > 0 {
+		hash = t.p.Selected().Hash
+	}
+	if hash == t.pv.hash {
+		return
+	}
+	t.pv.Show(hash)
+	if d, ok := t.cached(hash); ok {
+		t.pv.SetDiff(d.lines, d.err)
+		return
+	}
+	if hash != "" {
+		t.due = time.After(previewDelay)
+	}
+}
+
+// fetch starts fetching the diff the preview waits for in the background,
+// unless a fetch is already under way; it is started when that one ends.
+func (t *pickTerm) fetch() {
+	t.due = nil
+	if t.fetching || !t.pv.pending {
+		return
+	}
+	t.fetching = true
+	hash := t.pv.hash
+	go func() {
+		lines, err := t.opts.Diff(hash)
+		t.diffs <- previewDiff{hash, lines, err}
+	}()
+}
+
+// cached returns the diff cached for hash, marking it as shown last.
+func (t *pickTerm) cached(hash string) (previewDiff, bool) {
+	d, ok := t.cache[hash]
+	if ok {
+		t.recent = append(slices.DeleteFunc(t.recent, func(h string) bool { return h == hash }), hash)
+	}
+	return d, ok
+}
+
+// store caches d, dropping the diff shown least recently once the cache
+// holds previewCacheSize.
+func (t *pickTerm) store(d previewDiff) {
+	if _, ok := t.cached(d.hash); !ok {
+		if len(t.recent) >= previewCacheSize {
+			delete(t.cache, t.recent[0])
+			t.recent = t.recent[1:]
+		}
+		t.recent = append(t.recent, d.hash)
+	}
+	t.cache[d.hash] = d
+}
+
+// gotDiff shows a fetched diff if the cursor is still on its commit, or
+// else fetches the one it has moved to. Diffs are cached, errors aren't.
+func (t *pickTerm) gotDiff(d previewDiff) {
+	t.fetching = false
+	if d.err == nil {
+		t.store(d)
+	}
+	if d.hash != t.pv.hash {
+		if t.due == nil {
+			t.fetch()
+		}
+		return
+	}
+	t.pv.SetDiff(d.lines, d.err)
+	if t.pane != paneNone {
+		t.redraw()
+	}
+}
+
+// scrollPreview applies a preview scrolling action to the pane if it is
+// showing, reporting whether a is one.
+func (t *pickTerm) scrollPreview(a Action) bool {
+	if t.pane == paneNone {
+		return false
+	}
+	return t.pv.scroll(a)
+}
+
+// togglePreview shows or hides the preview pane, laying the picker out
+// again for it.
+func (t *pickTerm) togglePreview() {
+	if t.pv == nil {
+		return
+	}
+	t.pv.Toggle()
+	t.fit()
+	fmt.Fprint(t.out, "\x1b[2J\x1b[H")
+	t.draw()
+}
+
+// readKey waits for the next key. A terminal resize in the meantime lays
+// the picker out again and redraws it from the top of a cleared screen,
+// since the old lines may have been rewrapped or scrolled away; commits
+// loaded in the meantime are added to the list, and diffs to the preview.
+func (t *pickTerm) readKey() (Key, error) {
+	for {
+		select {
+		case ev := <-t.keys:
+			return ev.Key, ev.Err
+		case pg := <-t.loaded:
+			t.receive(pg)
+		case <-t.due:
+			t.fetch()
+		case d := <-t.diffs:
+			t.gotDiff(d)
+		case <-t.opts.Resized:
+			t.fit()
+			fmt.Fprint(t.out, "\x1b[2J\x1b[H")
+			t.draw()
+		}
+	}
+}
+
+// PickCommit runs an interactive picker loop over keys, bound to actions
+// by opts.Keymap. Lines are cut to the terminal's width so that none wraps
+// and breaks the in-place redraw.
+// Returns the selected commit, or nil if the user quits.
+func PickCommit(commits []navigator.Commit, keys <-chan KeyEvent, out io.Writer, opts PickOptions) (*navigator.Commit, error) {
+	p, i, _, err := pick(commits, keys, out, opts, false)
+	return p.at(i), err
+}
+
+// PickRange is PickCommit that also lets the user mark a range: the
+// mark-range action on one end, then select on the other. It returns
+// the range's oldest and newest commits once opts.CheckRange accepts
+// them, or the selected commit and a nil end if no range was marked.
+func PickRange(commits []navigator.Commit, keys <-chan KeyEvent, out io.Writer, opts PickOptions) (start, end *navigator.Commit, err error) {
+	p, first, last, err := pick(commits, keys, out, opts, true)
+	return p.at(first), p.at(last), err
+}
+
+// PickRef runs the picker over refs instead of commits, for choosing whose
+// history to pick from: each line shows the ref's commit, its name, and its
+// kind with the commit's subject, all of which the filter matches. Returns
+// the selected ref, or nil if the user quits. opts.Load, opts.Diff and
+// opts.CheckRange are ignored.
+func PickRef(refs []navigator.Ref, keys <-chan KeyEvent, out io.Writer, opts PickOptions) (*navigator.Ref, error) {
+	commits := make([]navigator.Commit, len(refs))
+	for i, r := range refs {
+		commits[i] = navigator.Commit{Hash: r.Hash, Message: r.Name, Author: r.Kind + "  " + r.Subject, Date: r.Date}
+	}
+	if opts.Title == "" {
+		opts.Title = "Select a branch, tag or reflog entry to pick a commit from:"
+	}
+	opts.Load, opts.Diff, opts.CheckRange = nil, nil, nil
+	_, i, _, err := pick(commits, keys, out, opts, false)
+	if i < 0 {
+		return nil, err
+	}
+	return &refs[i], nil
+}
+
+// pick runs the picker loop, with range marking if ranges is set. It
+// returns the picker and the indices in its commits of the selected
+// commit, or of the range's oldest and newest; -1 for none.
+func pick(commits []navigator.Commit, keys <-chan KeyEvent, out io.Writer, opts PickOptions, ranges bool) (p *Picker, start, end int, err error) {
+	p = NewPicker(commits, opts.PageSize)
+	p.more = opts.Load != nil
+	p.countRange = opts.CountRange
+	p.keys = opts.Keymap
+	t := &pickTerm{p: p, keys: keys, out: out, opts: opts, loaded: make(chan pickPage, 1), ranges: ranges}
+	if opts.Diff != nil {
+		t.pv, t.diffs, t.cache = NewPreview(), make(chan previewDiff, 1), make(map[string]previewDiff)
+	}
+	t.fit()
+	t.loadMore()
+	t.draw()
+
+	parser := KeyParser{Keymap: opts.Keymap}
+	for {
+		k, err := t.readKey()
+		if err != nil {
+			return p, -1, -1, err
+		}
+
+		for _, cmd := range parser.Feed(k) {
+			switch {
+			case p.scroll(cmd.Action):
+			case t.scrollPreview(cmd.Action):
+			case cmd.Action == ActionToggleDiff:
+				t.togglePreview()
+				continue
+			case cmd.Action == ActionSelect:
+				if p.Shown() == 0 {
+					continue
+				}
+				first, last, _, ok := p.Range()
+				if !ok {
+					return p, p.view[p.cursor], -1, nil
+				}
+				if opts.CheckRange != nil {
+					if err := opts.CheckRange(first, last); err != nil {
+						p.status = fmt.Sprintf("Can't replay that range: %v", err)
+						t.redraw()
+						continue
+					}
+				}
+				newest, oldest := p.span()
+				return p, oldest, newest, nil
+			case cmd.Action == ActionMarkRange && t.ranges:
+				if p.Shown() == 0 {
+					continue
+				}
+				p.MarkRange()
+			case cmd.Action == ActionQuit:
+				return p, -1, -1, nil
+			case cmd.Action == ActionFilter:
+				if err := p.filterPrompt(t, &parser); err != nil {
+					return p, -1, -1, err
+				}
+			case cmd.Action == ActionClearFilter:
+				p.SetFilter(navigator.Fuzzy{})
+			case cmd.Action == ActionSearch:
+				if err := p.search(t); err != nil {
+					return p, -1, -1, err
+				}
+			case cmd.Action == ActionNextMatch:
+				p.NextMatch()
+			case cmd.Action == ActionPrevMatch:
+				p.PrevMatch()
+			default:
+				continue
+			}
+			p.status = ""
+			t.loadMore()
+			t.redraw()
+		}
 	}
 }
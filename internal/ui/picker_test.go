@@ -2,6 +2,7 @@ package ui
 
 import (
 	"bytes"
+	"fmt"
 	"strings"
 	"testing"
 
@@ -158,3 +159,257 @@ func TestPicker_Render_Scrolling(t *testing.T) {
 		t.Error("after scrolling, jkl3456 should be visible")
 	}
 }
+
+func TestPicker_SearchMatches(t *testing.T) {
+	p := NewPicker(sampleCommits(), 3)
+	q, _ := navigator.ParseQuery("f")
+	if n := p.SetQuery(q); n != 3 {
+		t.Fatalf("expected 3 matches (first, fourth, fifth), got %d", n)
+	}
+
+	p.NextMatch()
+	if got := p.Selected().Hash; got != "jkl3456" {
+		t.Errorf("expected next match jkl3456, got %s", got)
+	}
+	p.NextMatch()
+	p.NextMatch() // wraps
+	if got := p.Selected().Hash; got != "abc1234" {
+		t.Errorf("expected wrap to abc1234, got %s", got)
+	}
+	p.PrevMatch() // wraps backwards
+	if got := p.Selected().Hash; got != "mno7890" {
+		t.Errorf("expected wrap to mno7890, got %s", got)
+	}
+
+	var buf bytes.Buffer
+	p.Render(&buf)
+	if !strings.Contains(buf.String(), "\x1b[7mf\x1b[27mourth") {
+		t.Errorf("expected highlighted match in render, got %q", buf.String())
+	}
+}
+
+func TestPicker_LineFitsWidth(t *testing.T) {
+	commits := []navigator.Commit{{Hash: "abc1234", Message: "修正: 長いコミットメッセージがターミナルの幅を超える 🎉"}}
+	p := NewPicker(commits, 10)
+	p.width = 30
+
+	line := p.line(0)
+	if w := StringWidth(line); w > 30 {
+		t.Errorf("expected at most 30 columns, got %d: %q", w, line)
+	}
+	if !strings.HasPrefix(line, "> abc1234 修正") {
+		t.Errorf("expected the start of the message, got %q", line)
+	}
+}
+
+func TestPicker_Resize(t *testing.T) {
+	p := NewPicker(sampleCommits(), 5)
+	p.MoveTo(3)
+
+	p.Resize(2, 40)
+	if p.offset != 2 || p.visibleLines() != 2 {
+		t.Errorf("expected the cursor on the last of 2 lines from offset 2, got offset %d, %d lines", p.offset, p.visibleLines())
+	}
+	if p.width != 40 {
+		t.Errorf("expected width 40, got %d", p.width)
+	}
+
+	// Growing again fills the page rather than leaving the top half empty.
+	p.Resize(4, 40)
+	if p.offset != 1 || p.visibleLines() != 4 {
+		t.Errorf("expected 4 lines from offset 1, got offset %d, %d lines", p.offset, p.visibleLines())
+	}
+	if got := p.Selected().Hash; got != "jkl3456" {
+		t.Errorf("expected the cursor to stay on jkl3456, got %s", got)
+	}
+
+	p.Resize(0, 40)
+	if p.visibleLines() != 1 || p.offset != 3 {
+		t.Errorf("expected a single line at the cursor, got offset %d, %d lines", p.offset, p.visibleLines())
+	}
+}
+
+func TestPicker_Filter(t *testing.T) {
+	p := NewPicker(sampleCommits(), 2)
+	p.MoveTo(2) // ghi9012 third commit
+
+	if n := p.SetFilter(navigator.ParseFuzzy("fth")); n != 2 {
+		t.Fatalf("expected fourth and fifth to match, got %d", n)
+	}
+	// third is gone; the cursor moves on to the next commit shown.
+	if got := p.Selected().Hash; got != "jkl3456" || p.cursor != 0 || p.offset != 0 {
+		t.Errorf("expected the cursor on jkl3456 at the top, got %s (cursor %d, offset %d)", got, p.cursor, p.offset)
+	}
+	p.MoveDown()
+	p.MoveDown() // stays on the last commit shown
+	if got := p.Selected().Hash; got != "mno7890" {
+		t.Errorf("expected mno7890, got %s", got)
+	}
+
+	q, _ := navigator.ParseQuery("fourth")
+	if n := p.SetQuery(q); n != 1 || !p.NextMatch() || p.Selected().Hash != "jkl3456" {
+		t.Errorf("expected the search to run on the filtered commits, got %d matches, cursor on %s", n, p.Selected().Hash)
+	}
+
+	// Clearing the filter keeps the cursor on the same commit.
+	if n := p.SetFilter(navigator.Fuzzy{}); n != 5 {
+		t.Errorf("expected all 5 commits back, got %d", n)
+	}
+	if got := p.Selected().Hash; got != "jkl3456" || p.cursor < p.offset || p.cursor >= p.offset+2 {
+		t.Errorf("expected the cursor on jkl3456 in view, got %s (cursor %d, offset %d)", got, p.cursor, p.offset)
+	}
+
+	if n := p.SetFilter(navigator.ParseFuzzy("zzz")); n != 0 || p.visibleLines() != 0 || p.height() != 2 {
+		t.Errorf("expected an empty list keeping its height, got %d shown, %d lines, height %d", n, p.visibleLines(), p.height())
+	}
+}
+
+func TestPicker_FilterMarksMatches(t *testing.T) {
+	commits := []navigator.Commit{{Hash: "abc1234", Message: "fix race", Author: "Ana"}}
+	p := NewPicker(commits, 5)
+	p.SetFilter(navigator.ParseFuzzy("frc 12 ana"))
+
+	line := p.line(0)
+	for _, want := range []string{
+		"abc\x1b[7m12\x1b[27m34",
+		"\x1b[7mf\x1b[27mix \x1b[7mr\x1b[27ma\x1b[7mc\x1b[27me",
+		styleMuted + "  \x1b[7mAna\x1b[27m" + styleReset,
+	} {
+		if !strings.Contains(line, want) {
+			t.Errorf("expected %q in %q", want, line)
+		}
+	}
+
+	// The author is cut off first when the line is too long.
+	p.width = 18
+	if got := ansiRe.ReplaceAllString(p.line(0), ""); got != "> abc1234 fix race" {
+		t.Errorf("expected the author left out, got %q", got)
+	}
+}
+
+func TestPicker_Append(t *testing.T) {
+	commits := sampleCommits()
+	p := NewPicker(commits[:2], 2)
+	p.SetFilter(navigator.ParseFuzzy("fi"))
+	p.Append(commits[2:])
+
+	// first, fourth and fifth match "fi"; third doesn't.
+	if p.Shown() != 3 || len(p.commits) != 5 {
+		t.Fatalf("expected 3 of 5 commits shown, got %d of %d", p.Shown(), len(p.commits))
+	}
+	p.MoveDown()
+	if got := p.Selected().Hash; got != "jkl3456" {
+		t.Errorf("expected jkl3456 below abc1234, got %s", got)
+	}
+	if len(p.hits) != p.Shown() {
+		t.Errorf("expected a hit for every commit shown, got %d", len(p.hits))
+	}
+}
+
+func TestPickTerm_LoadsMore(t *testing.T) {
+	commits := sampleCommits()
+	var skips []int
+	load := func(skip int) ([]navigator.Commit, error) {
+		skips = append(skips, skip)
+		if skip >= len(commits) {
+			return nil, nil
+		}
+		return commits[skip:min(skip+2, len(commits))], nil
+	}
+	var out bytes.Buffer
+	p := NewPicker(commits[:2], 2)
+	p.more = true
+	term := &pickTerm{p: p, out: &out, opts: PickOptions{PageSize: 2, Load: load}, loaded: make(chan pickPage, 1)}
+	term.fit()
+
+	// The cursor is already within a page of the end.
+	for p.more {
+		term.loadMore()
+		if !p.loading {
+			break
+		}
+		if !strings.Contains(ansiRe.ReplaceAllString(captureRender(p), ""), "loading more commits…") {
+			t.Errorf("expected a loading indicator while loading")
+		}
+		term.receive(<-term.loaded)
+		p.MoveTo(p.Shown() - 1)
+	}
+	if fmt.Sprint(skips) != "[2 4 5]" {
+		t.Errorf("expected pages after 2, 4 and 5 commits, got %v", skips)
+	}
+	if len(p.commits) != 5 || p.more || p.loading {
+		t.Errorf("expected all 5 commits and nothing more to load, got %d (more %v, loading %v)", len(p.commits), p.more, p.loading)
+	}
+
+	// Far from the end, nothing is loaded.
+	p.more = true
+	p.MoveTo(0)
+	p.Resize(2, 0)
+	if p.needsMore() {
+		t.Error("expected no loading with the cursor more than a page from the end")
+	}
+}
+
+func captureRender(p *Picker) string {
+	var b bytes.Buffer
+	p.renderRaw(&b)
+	return b.String()
+}
+
+func TestPicker_Range(t *testing.T) {
+	p := NewPicker(sampleCommits(), 5)
+	if _, _, _, ok := p.Range(); ok {
+		t.Fatal("expected no range before one is marked")
+	}
+	p.MoveDown()
+	p.MarkRange()
+	p.MoveDown()
+	p.MoveDown()
+	start, end, n, ok := p.Range()
+	if !ok || start.Hash != "jkl3456" || end.Hash != "def5678" || n != 3 {
+		t.Errorf("expected jkl3456..def5678 with 3 commits, got %s..%s with %d (%v)", start.Hash, end.Hash, n, ok)
+	}
+	for row, want := range []bool{false, true, true, true, false} {
+		if p.inRange(row) != want {
+			t.Errorf("row %d: expected in range %v", row, want)
+		}
+	}
+	if got := ansiRe.ReplaceAllString(p.describe(), ""); got != "range jkl3456..def5678  3 commits" {
+		t.Errorf("expected the range in the status line, got %q", got)
+	}
+
+	// Marked from the other end, the range is the same, and commits the
+	// filter hides still count.
+	p.MarkRange()
+	p.MarkRange()
+	p.MoveTo(1)
+	if p.SetFilter(navigator.ParseFuzzy("se")) != 1 {
+		t.Fatal("expected the filter to leave def5678 alone")
+	}
+	if start, end, n, _ := p.Range(); start.Hash != "jkl3456" || end.Hash != "def5678" || n != 3 {
+		t.Errorf("expected jkl3456..def5678 with 3 commits, got %s..%s with %d", start.Hash, end.Hash, n)
+	}
+
+	p.MarkRange()
+	if _, _, _, ok := p.Range(); ok {
+		t.Error("expected marking again to drop the range")
+	}
+}
+
+func TestPicker_RangeCount(t *testing.T) {
+	p := NewPicker(sampleCommits(), 5)
+	var asked []string
+	p.countRange = func(start, end navigator.Commit) (int, error) {
+		asked = append(asked, start.Hash+".."+end.Hash)
+		return 7, nil // a merge between the ends brings in more commits
+	}
+	p.MarkRange()
+	p.MoveDown()
+	p.describe()
+	if _, _, n, _ := p.Range(); n != 7 {
+		t.Errorf("expected the range counted by countRange, got %d", n)
+	}
+	if len(asked) != 1 || asked[0] != "def5678..abc1234" {
+		t.Errorf("expected one count of def5678..abc1234, got %v", asked)
+	}
+}
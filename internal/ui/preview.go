@@ -0,0 +1,265 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/anuchito/replay/internal/navigator"
+	"github.com/anuchito/replay/internal/syntax"
+)
+
+// previewDelay is how long the picker's cursor must rest on a commit before
+// its diff is fetched, so that scrolling through the list doesn't start a
+// git process for every commit it passes.
+const previewDelay = 150 * time.Millisecond
+
+// previewCacheSize is how many diffs the picker keeps for commits it has
+// shown, so that moving back to them needs no fetch.
+const previewCacheSize = 64
+
+// minPreviewRows is the shortest preview pane worth showing, its title
+// included; a terminal without room for it gets the list alone.
+const minPreviewRows = 5
+
+// Preview is the picker's pane showing the commit under the cursor: how
+// many lines each file gains and loses, then the diff. It is toggled
+// on/off and scrolls with its own keys, independently of the list.
+type Preview struct {
+	Active  bool
+	hash    string // commit shown, "" for none
+	lines   []string
+	spans   [][]syntax.Span // syntax highlighting of lines
+	stats   []fileStat
+	pending bool // the diff is being fetched
+	err     error
+	offset  int // first content row shown
+	height  int // content rows from the last render
+}
+
+// fileStat is one file's line of the stat summary.
+type fileStat struct {
+	path           string
+	added, removed int
+	binary         bool
+}
+
+func NewPreview() *Preview {
+	return &Preview{Active: true}
+}
+
+func (pv *Preview) Toggle() {
+	pv.Active = !pv.Active
+}
+
+// Show switches the pane to commit hash, whose diff is yet to come.
+func (pv *Preview) Show(hash string) {
+	*pv = Preview{Active: pv.Active, hash: hash, pending: hash != "", height: pv.height}
+}
+
+// SetDiff fills the pane with the diff of the commit shown, or the error
+// fetching it.
+func (pv *Preview) SetDiff(lines []string, err error) {
+	pv.pending, pv.err = false, err
+	pv.lines, pv.spans, pv.stats = lines, highlightDiff(lines), diffStat(lines)
+	pv.offset = 0
+}
+
+// rows is the number of content rows: the stat summary, a blank line and
+// the diff.
+func (pv *Preview) rows() int {
+	if pv.pending || pv.err != nil || pv.hash == "" {
+		return 1
+	}
+	return len(pv.stats) + 2 + len(pv.lines)
+}
+
+func (pv *Preview) scrollBy(n int) {
+	pv.offset = min(pv.offset+n, pv.rows()-pv.height)
+	pv.offset = max(pv.offset, 0)
+}
+
+// scroll applies a preview scrolling action, reporting whether a is one.
+func (pv *Preview) scroll(a Action) bool {
+	switch a {
+	case ActionPreviewDown:
+		pv.scrollBy(1)
+	case ActionPreviewUp:
+		pv.scrollBy(-1)
+	case ActionPreviewPageDown:
+		pv.scrollBy(max(pv.height-1, 1))
+	case ActionPreviewPageUp:
+		pv.scrollBy(-max(pv.height-1, 1))
+	default:
+		return false
+	}
+	return true
+}
+
+// render lays the pane out in height lines of width columns for commit
+// c, the one shown: a title, then the content from the scroll offset.
+func (pv *Preview) render(c navigator.Commit, width, height int) []string {
+	pv.height = max(height-1, 0)
+	pv.scrollBy(0)
+
+	lines := make([]string, 0, height)
+	label := "── "
+	if pv.hash != "" {
+		label += c.Hash + "  " + c.Message + " "
+	}
+	if pv.rows() > pv.height && pv.height > 0 {
+		label = Truncate(label, max(width-12, 0)) + fmt.Sprintf("(%d/%d) ", min(pv.offset+pv.height, pv.rows()), pv.rows())
+	}
+	label = Truncate(label, width)
+	lines = append(lines, styleTitle+label+strings.Repeat("─", max(width-StringWidth(label), 0))+styleReset)
+
+	for i := pv.offset; len(lines) < height; i++ {
+		lines = append(lines, pv.row(i, width))
+	}
+	return lines
+}
+
+// row renders content row i, "" past the end.
+func (pv *Preview) row(i, width int) string {
+	switch {
+	case pv.hash == "":
+		return ""
+	case pv.pending:
+		if i == 0 {
+			return styleMuted + Truncate("loading…", width) + styleReset
+		}
+		return ""
+	case pv.err != nil:
+		if i == 0 {
+			return Truncate(fmt.Sprintf("Can't show the diff: %v", pv.err), width)
+		}
+		return ""
+	case i < len(pv.stats):
+		return statLine(pv.stats[i], pv.stats, width)
+	case i == len(pv.stats):
+		return styleMuted + Truncate(pv.summary(), width) + styleReset
+	case i == len(pv.stats)+1:
+		return ""
+	}
+	i -= len(pv.stats) + 2
+	if i >= len(pv.lines) {
+		return ""
+	}
+	return renderDiffLine(pv.lines[i], pv.spans[i], width, 0)
+}
+
+// statLine draws a file's line of the summary the way git's --stat does:
+// " path | 12 ++++----", lined up with the other files in all and the bar
+// scaled to fit the widest of them.
+func statLine(f fileStat, all []fileStat, width int) string {
+	nameW, most := 0, 0
+	for _, s := range all {
+		nameW = max(nameW, StringWidth(s.path))
+		most = max(most, s.added+s.removed)
+	}
+	nameW = min(nameW, max(width/2, 1))
+	countW := len(fmt.Sprint(most))
+	name := Truncate(f.path, nameW)
+	line := " " + name + strings.Repeat(" ", nameW-StringWidth(name)) + " | "
+	if f.binary {
+		return Truncate(line+"Bin", width)
+	}
+	line += fmt.Sprintf("%*d ", countW, f.added+f.removed)
+	room := width - StringWidth(line)
+	if room <= 0 {
+		return Truncate(line, width)
+	}
+	plus, minus := f.added, f.removed
+	if most > room {
+		plus, minus = scaleBar(f.added, most, room), scaleBar(f.removed, most, room)
+	}
+	bar := ""
+	if plus > 0 {
+		bar += styleAdded + strings.Repeat("+", plus) + styleReset
+	}
+	if minus > 0 {
+		bar += styleRemoved + strings.Repeat("-", minus) + styleReset
+	}
+	return line + bar
+}
+
+// scaleBar shrinks n of most to fit room columns, keeping at least one
+// for any change at all.
+func scaleBar(n, most, room int) int {
+	if n == 0 {
+		return 0
+	}
+	return max(n*room/most, 1)
+}
+
+// summary is the stat's last line, e.g. "2 files changed, 10 insertions(+),
+// 3 deletions(-)".
+func (pv *Preview) summary() string {
+	if len(pv.stats) == 0 {
+		return " no changes"
+	}
+	added, removed := 0, 0
+	for _, f := range pv.stats {
+		added += f.added
+		removed += f.removed
+	}
+	s := fmt.Sprintf(" %d file%s changed", len(pv.stats), plural(len(pv.stats)))
+	if added > 0 || removed == 0 {
+		s += fmt.Sprintf(", %d insertion%s(+)", added, plural(added))
+	}
+	if removed > 0 {
+		s += fmt.Sprintf(", %d deletion%s(-)", removed, plural(removed))
+	}
+	return s
+}
+
+func plural(n int) string {
+	if n == 1 {
+		return ""
+	}
+	return "s"
+}
+
+// diffStat counts the lines each file of a diff adds and removes. In the
+// combined diff of a merge, a line counts as added or removed if it is
+// against any of the parents.
+func diffStat(lines []string) []fileStat {
+	var stats []fileStat
+	parents := 1 // columns of +/- markers
+	inHunk := false
+	for _, l := range lines {
+		switch {
+		case strings.HasPrefix(l, "diff --git "):
+			path := l
+			if j := strings.LastIndex(l, " b/"); j >= 0 {
+				path = l[j+3:]
+			}
+			stats = append(stats, fileStat{path: path})
+			inHunk = false
+			continue
+		case strings.HasPrefix(l, "diff --cc "), strings.HasPrefix(l, "diff --combined "):
+			stats = append(stats, fileStat{path: l[strings.LastIndex(l, " ")+1:]})
+			inHunk = false
+			continue
+		case len(stats) == 0:
+			continue
+		case strings.HasPrefix(l, "@@"):
+			parents = max(len(l)-len(strings.TrimLeft(l, "@"))-1, 1)
+			inHunk = true
+			continue
+		case strings.HasPrefix(l, "Binary files "):
+			stats[len(stats)-1].binary = true
+			continue
+		case !inHunk || len(l) < parents:
+			continue
+		}
+		f := &stats[len(stats)-1]
+		switch markers := l[:parents]; {
+		case strings.Contains(markers, "+"):
+			f.added++
+		case strings.Contains(markers, "-"):
+			f.removed++
+		}
+	}
+	return stats
+}
@@ -0,0 +1,168 @@
+package ui
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/anuchito/replay/internal/navigator"
+)
+
+func TestDiffStat(t *testing.T) {
+	diff := []string{
+		"diff --git a/main.go b/main.go",
+		"index 1111111..2222222 100644",
+		"--- a/main.go",
+		"+++ b/main.go",
+		"@@ -1,3 +1,4 @@",
+		" package main",
+		"-var x = 1",
+		"+var x = 2",
+		"+var y = 3",
+		"diff --git a/logo.png b/logo.png",
+		"Binary files a/logo.png and b/logo.png differ",
+		"diff --cc merged.go",
+		"index 3333333,4444444..5555555",
+		"--- a/merged.go",
+		"+++ b/merged.go",
+		"@@@ -1,2 -1,2 +1,3 @@@",
+		"  same",
+		"+ from ours",
+		" +from theirs",
+		"- gone",
+	}
+	want := "[{main.go 2 1 false} {logo.png 0 0 true} {merged.go 2 1 false}]"
+	if got := fmt.Sprint(diffStat(diff)); got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+}
+
+func TestPreview_Render(t *testing.T) {
+	c := navigator.Commit{Hash: "abc1234", Message: "add things"}
+	pv := NewPreview()
+	pv.Show(c.Hash)
+	lines := pv.render(c, 40, 4)
+	if got := ansiRe.ReplaceAllString(lines[1], ""); got != "loading…" {
+		t.Errorf("expected a loading line until the diff comes, got %q", got)
+	}
+
+	diff := []string{"diff --git a/a.txt b/a.txt", "--- a/a.txt", "+++ b/a.txt", "@@ -1 +1,40 @@", "-old"}
+	for i := range 40 {
+		diff = append(diff, fmt.Sprintf("+line %d", i))
+	}
+	pv.SetDiff(diff, nil)
+	lines = pv.render(c, 40, 4)
+	plain := make([]string, len(lines))
+	for i, l := range lines {
+		plain[i] = ansiRe.ReplaceAllString(l, "")
+	}
+	if !strings.HasPrefix(plain[0], "── abc1234  add things (3/48) ─") || StringWidth(plain[0]) != 40 {
+		t.Errorf("expected a full-width title with the scroll position, got %q", plain[0])
+	}
+	if want := " a.txt | 41 " + strings.Repeat("+", 27) + "-"; plain[1] != want {
+		t.Errorf("expected the stat bar scaled to fit, %q, got %q", want, plain[1])
+	}
+	if plain[2] != " 1 file changed, 40 insertions(+), 1 deletion(-)"[:40] {
+		t.Errorf("expected the summary line, got %q", plain[2])
+	}
+
+	pv.scroll(ActionPreviewPageDown)
+	if pv.offset != 2 {
+		t.Errorf("expected a page down to scroll a page less a line, got offset %d", pv.offset)
+	}
+	for range 50 {
+		pv.scroll(ActionPreviewDown)
+	}
+	lines = pv.render(c, 40, 4)
+	if got := ansiRe.ReplaceAllString(lines[3], ""); got != "+line 39" {
+		t.Errorf("expected scrolling to stop at the last line, got %q", got)
+	}
+
+	pv.Show("def5678")
+	if pv.offset != 0 || !pv.pending || !pv.Active {
+		t.Errorf("expected another commit to start at the top, pending, got %+v", pv)
+	}
+}
+
+func TestPickTerm_Preview(t *testing.T) {
+	commits := sampleCommits()
+	var fetched []string
+	diff := func(hash string) ([]string, error) {
+		fetched = append(fetched, hash)
+		return []string{"diff --git a/" + hash + " b/" + hash, "@@ -0,0 +1 @@", "+" + hash}, nil
+	}
+	var out bytes.Buffer
+	size := func() (int, int) { return 80, 24 }
+	p := NewPicker(commits, 20)
+	term := &pickTerm{
+		p: p, out: &out, opts: PickOptions{PageSize: 20, Size: size, Diff: diff},
+		pv: NewPreview(), diffs: make(chan previewDiff, 1), cache: make(map[string]previewDiff),
+	}
+	term.fit()
+	if term.pane != paneBelow || p.pageSize != 6 || term.paneRows() != 14 {
+		t.Fatalf("expected the pane below a list of 6 on 80x24, got layout %d, list %d, pane %d", term.pane, p.pageSize, term.paneRows())
+	}
+	term.draw()
+
+	// Moving on before the delay is up fetches only where the cursor rests.
+	for range 3 {
+		p.MoveDown()
+		term.redraw()
+	}
+	<-term.due
+	term.fetch()
+	term.gotDiff(<-term.diffs)
+	if fmt.Sprint(fetched) != "[jkl3456]" {
+		t.Errorf("expected only the last commit fetched, got %v", fetched)
+	}
+	if !strings.Contains(ansiRe.ReplaceAllString(out.String(), ""), "+jkl3456") {
+		t.Error("expected the fetched diff drawn")
+	}
+
+	// Back on a commit already fetched, the cached diff shows at once.
+	p.MoveUp()
+	term.redraw()
+	<-term.due
+	term.fetch()
+	term.gotDiff(<-term.diffs)
+	p.MoveDown()
+	term.redraw()
+	if term.pv.pending || term.due != nil || len(fetched) != 2 {
+		t.Errorf("expected the cached diff shown without a fetch, fetched %v", fetched)
+	}
+
+	size = func() (int, int) { return 130, 24 }
+	term.opts.Size = size
+	term.fit()
+	if term.pane != paneBeside || p.pageSize != 19 || p.width != 51 {
+		t.Errorf("expected the pane beside a list of 19 on 130x24, got layout %d, list %d, width %d", term.pane, p.pageSize, p.width)
+	}
+
+	term.pv.Toggle()
+	term.fit()
+	if term.pane != paneNone || p.pageSize != 20 {
+		t.Errorf("expected the list alone with the preview off, got layout %d, list %d", term.pane, p.pageSize)
+	}
+}
+
+func TestPickTerm_CacheDropsLeastRecentlyShown(t *testing.T) {
+	term := &pickTerm{cache: make(map[string]previewDiff)}
+	for i := range previewCacheSize {
+		term.store(previewDiff{hash: fmt.Sprint(i)})
+	}
+	term.cached("0")
+	term.store(previewDiff{hash: "new"})
+
+	if len(term.cache) != previewCacheSize {
+		t.Errorf("expected %d diffs cached, got %d", previewCacheSize, len(term.cache))
+	}
+	if _, ok := term.cache["1"]; ok {
+		t.Error("expected the diff shown least recently dropped")
+	}
+	for _, hash := range []string{"0", "2", "new"} {
+		if _, ok := term.cache[hash]; !ok {
+			t.Errorf("expected %s still cached", hash)
+		}
+	}
+}
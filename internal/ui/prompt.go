@@ -0,0 +1,63 @@
+package ui
+
+import (
+	"fmt"
+	"io"
+)
+
+// LineInput is a single-line text prompt driven by decoded keys.
+type LineInput struct {
+	Label string
+	Hint  string // muted text after the input, e.g. a live match count
+	text  []rune
+}
+
+func NewLineInput(label, initial string) *LineInput {
+	return &LineInput{Label: label, text: []rune(initial)}
+}
+
+func (li *LineInput) Text() string {
+	return string(li.text)
+}
+
+// Handle applies a key. It reports done when the input is submitted with
+// Enter, and cancel when it is abandoned with Esc, Ctrl+C or a backspace on
+// an empty line.
+func (li *LineInput) Handle(k Key) (done, cancel bool) {
+	switch k {
+	case KeyEnter:
+		return true, false
+	case KeyEsc, KeyCtrlC:
+		return false, true
+	case KeyBackspace:
+		if len(li.text) == 0 {
+			return false, true
+		}
+		li.text = li.text[:len(li.text)-1]
+	case "ctrl+u":
+		li.text = li.text[:0]
+	default:
+		if k.IsPrintable() {
+			li.text = append(li.text, []rune(string(k))...)
+		}
+	}
+	return false, false
+}
+
+// handles reports whether Handle does anything with k.
+func (li *LineInput) handles(k Key) bool {
+	switch k {
+	case KeyEnter, KeyEsc, KeyCtrlC, KeyBackspace, "ctrl+u":
+		return true
+	}
+	return k.IsPrintable()
+}
+
+// Render redraws the prompt on the current terminal line.
+func (li *LineInput) Render(w io.Writer) {
+	fmt.Fprintf(w, "\r\x1b[2K%s%s", li.Label, string(li.text))
+	if li.Hint != "" {
+		// Save/restore the cursor so it stays at the end of the input.
+		fmt.Fprintf(w, "\x1b7  %s%s%s\x1b8", styleMuted, li.Hint, styleReset)
+	}
+}
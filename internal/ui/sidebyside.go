@@ -0,0 +1,163 @@
+package ui
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/anuchito/replay/internal/syntax"
+)
+
+// DefaultSplitWidth is the narrowest terminal the side-by-side layout is
+// used on; below it DiffView falls back to the unified diff.
+const DefaultSplitWidth = 120
+
+// Tabs are expanded to these tab stops: git's default in the unified
+// diff, and a narrower one in the side-by-side halves.
+const (
+	tabWidth      = 8
+	splitTabWidth = 4
+)
+
+// hScrollStep is how many columns ScrollLeft and ScrollRight move.
+const hScrollStep = 8
+
+var hunkRe = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
+
+// sideCell is one half of a side-by-side row.
+type sideCell struct {
+	num  int    // line number in the old or new file
+	kind byte   // ' ' context, '-' deleted, '+' added, 0 for a filler
+	text string // the line without its diff marker
+	line int    // index of the diff line it came from
+}
+
+// sideRow is one display row of the side-by-side layout. Lines outside a
+// hunk body (file and hunk headers, combined diffs of merges) span the
+// full width and keep their raw text.
+type sideRow struct {
+	span        bool
+	full        string
+	line        int // index of the diff line, for spanning rows
+	left, right sideCell
+}
+
+// splitDiff parses unified diff lines into side-by-side rows. Within a
+// hunk, runs of deletions and additions are paired up line by line, and
+// the shorter side is padded with fillers.
+func splitDiff(lines []string) []sideRow {
+	var rows []sideRow
+	var dels, adds []sideCell
+	flush := func() {
+		for i := 0; i < max(len(dels), len(adds)); i++ {
+			var r sideRow
+			if i < len(dels) {
+				r.left = dels[i]
+			}
+			if i < len(adds) {
+				r.right = adds[i]
+			}
+			rows = append(rows, r)
+		}
+		dels, adds = dels[:0], adds[:0]
+	}
+
+	oldN, newN := 0, 0       // next line number on each side
+	oldLeft, newLeft := 0, 0 // lines of the current hunk still to come
+	for i, line := range lines {
+		if oldLeft > 0 || newLeft > 0 {
+			switch {
+			case strings.HasPrefix(line, "-") && oldLeft > 0:
+				dels = append(dels, sideCell{oldN, '-', line[1:], i})
+				oldN, oldLeft = oldN+1, oldLeft-1
+				continue
+			case strings.HasPrefix(line, "+") && newLeft > 0:
+				adds = append(adds, sideCell{newN, '+', line[1:], i})
+				newN, newLeft = newN+1, newLeft-1
+				continue
+			case strings.HasPrefix(line, " ") || line == "":
+				flush()
+				text := strings.TrimPrefix(line, " ")
+				rows = append(rows, sideRow{left: sideCell{oldN, ' ', text, i}, right: sideCell{newN, ' ', text, i}})
+				oldN, newN = oldN+1, newN+1
+				oldLeft, newLeft = oldLeft-1, newLeft-1
+				continue
+			case strings.HasPrefix(line, `\`):
+				// "\ No newline at end of file" sits between the lines it
+				// qualifies; showing it would break their pairing.
+				continue
+			}
+		}
+		if strings.HasPrefix(line, `\`) {
+			continue
+		}
+		flush()
+		oldLeft, newLeft = 0, 0
+		if m := hunkRe.FindStringSubmatch(line); m != nil {
+			oldN, oldLeft = hunkRange(m[1], m[2])
+			newN, newLeft = hunkRange(m[3], m[4])
+		}
+		rows = append(rows, sideRow{span: true, full: line, line: i})
+	}
+	flush()
+	return rows
+}
+
+// hunkRange parses the start and length of one side of a hunk header; an
+// omitted length means one line.
+func hunkRange(start, length string) (int, int) {
+	s, _ := strconv.Atoi(start)
+	if length == "" {
+		return s, 1
+	}
+	n, _ := strconv.Atoi(length)
+	return s, n
+}
+
+// gutterWidth is the number of digits needed for the largest line number.
+func gutterWidth(rows []sideRow) int {
+	n := 0
+	for _, r := range rows {
+		n = max(n, r.left.num, r.right.num)
+	}
+	return len(strconv.Itoa(n))
+}
+
+// renderSideRow draws a row across termW columns: the old side, a divider
+// and the new side, each scrolled skip columns to the right. spans holds
+// the syntax highlighting of the diff lines and may be nil.
+func renderSideRow(r sideRow, termW, numW int, spans [][]syntax.Span, skip int) string {
+	if r.span {
+		return renderDiffLine(r.full, nil, termW, skip)
+	}
+	half := (termW - 1) / 2
+	return renderCell(r.left, half, numW, spans, skip, false) + styleMuted + "│" + styleReset + renderCell(r.right, termW-1-half, numW, spans, skip, false)
+}
+
+// renderCell draws one side of a row padded to exactly width columns:
+// the line number, the diff marker and the tab-expanded text from column
+// skip on. A continuation row of a wrapped line (cont) shows wrapMarker
+// in place of the line number and marker.
+func renderCell(c sideCell, width, numW int, spans [][]syntax.Span, skip int, cont bool) string {
+	if c.kind == 0 {
+		return strings.Repeat(" ", width)
+	}
+	var highlight []syntax.Span
+	if c.line < len(spans) {
+		highlight = spans[c.line]
+	}
+	textW := max(width-numW-2, 0)
+	color := diffColor(c.kind)
+	text, used := paint(c.text, highlight, color, skip, textW, splitTabWidth)
+	pad := strings.Repeat(" ", textW-used)
+
+	gutter := fmt.Sprintf("%*d", numW, c.num)
+	if cont {
+		return strings.Repeat(" ", numW+1) + styleMuted + wrapMarker + styleReset + text + pad
+	}
+	if color == "" {
+		return styleMuted + gutter + styleReset + "  " + text + pad
+	}
+	return styleMuted + gutter + styleReset + " " + color + string(c.kind) + text + pad
+}
@@ -0,0 +1,153 @@
+package ui
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/anuchito/replay/internal/navigator"
+)
+
+var sampleDiff = []string{
+	"diff --git a/f.go b/f.go",
+	"index 1111111..2222222 100644",
+	"--- a/f.go",
+	"+++ b/f.go",
+	"@@ -8,4 +8,5 @@ func f() {",
+	" a := 1",
+	"-b := 2",
+	"-c := 3",
+	"+b := 20",
+	" d := 4",
+	"+e := 5",
+	"+--- not a header",
+	"@@ -30 +31,0 @@",
+	"-gone",
+}
+
+func cell(num int, kind byte, text string) sideCell {
+	return sideCell{num: num, kind: kind, text: text}
+}
+
+// sameCell compares cells ignoring which diff line they came from.
+func sameCell(a, b sideCell) bool {
+	return a.num == b.num && a.kind == b.kind && a.text == b.text
+}
+
+func TestSplitDiff(t *testing.T) {
+	rows := splitDiff(sampleDiff)
+
+	type cells struct{ left, right sideCell }
+	want := []any{
+		"diff --git a/f.go b/f.go",
+		"index 1111111..2222222 100644",
+		"--- a/f.go",
+		"+++ b/f.go",
+		"@@ -8,4 +8,5 @@ func f() {",
+		cells{cell(8, ' ', "a := 1"), cell(8, ' ', "a := 1")},
+		cells{cell(9, '-', "b := 2"), cell(9, '+', "b := 20")},
+		cells{cell(10, '-', "c := 3"), sideCell{}},
+		cells{cell(11, ' ', "d := 4"), cell(10, ' ', "d := 4")},
+		cells{sideCell{}, cell(11, '+', "e := 5")},
+		cells{sideCell{}, cell(12, '+', "--- not a header")},
+		"@@ -30 +31,0 @@",
+		cells{cell(30, '-', "gone"), sideCell{}},
+	}
+	if len(rows) != len(want) {
+		t.Fatalf("expected %d rows, got %d: %+v", len(want), len(rows), rows)
+	}
+	for i, w := range want {
+		switch w := w.(type) {
+		case string:
+			if !rows[i].span || rows[i].full != w {
+				t.Errorf("row %d: expected full-width %q, got %+v", i, w, rows[i])
+			}
+		case cells:
+			if rows[i].span || !sameCell(rows[i].left, w.left) || !sameCell(rows[i].right, w.right) {
+				t.Errorf("row %d: expected %+v, got %+v", i, w, rows[i])
+			}
+		}
+	}
+	if n := gutterWidth(rows); n != 2 {
+		t.Errorf("expected gutter width 2, got %d", n)
+	}
+}
+
+func TestSplitDiff_CombinedDiffSpans(t *testing.T) {
+	rows := splitDiff([]string{"diff --cc f.go", "@@@ -1,2 -1,2 +1,2 @@@", "- a", " +b"})
+	for i, r := range rows {
+		if !r.span {
+			t.Errorf("row %d: expected merge diff lines to span, got %+v", i, r)
+		}
+	}
+}
+
+func TestSplitDiff_NoNewlineMarker(t *testing.T) {
+	rows := splitDiff([]string{"@@ -1 +1 @@", "-old", `\ No newline at end of file`, "+new", `\ No newline at end of file`})
+	if len(rows) != 2 || rows[1].left.text != "old" || rows[1].right.text != "new" {
+		t.Errorf("expected old and new paired, got %+v", rows)
+	}
+}
+
+var ansiRe = regexp.MustCompile("\x1b\\[[0-9;]*[A-Za-z]")
+
+func TestRenderSideRow_Aligned(t *testing.T) {
+	for _, r := range []sideRow{
+		{left: cell(9, '-', "b := 2"), right: cell(9, '+', "b := 20")},
+		{left: cell(10, '-', "\tc := 3")},
+		{right: cell(11, '+', strings.Repeat("x", 200))},
+	} {
+		line := ansiRe.ReplaceAllString(renderSideRow(r, 121, 2, nil, 0), "")
+		if n := utf8.RuneCountInString(line); n != 121 {
+			t.Errorf("expected 121 columns, got %d: %q", n, line)
+		}
+		if i := strings.Index(line, "│"); utf8.RuneCountInString(line[:i]) != 60 {
+			t.Errorf("expected divider at column 60, got %q", line)
+		}
+	}
+}
+
+func TestSplitDiff_RecordsSourceLines(t *testing.T) {
+	rows := splitDiff(sampleDiff)
+	if r := rows[6]; r.left.line != 6 || r.right.line != 8 {
+		t.Errorf("expected cells from diff lines 6 and 8, got %d and %d", r.left.line, r.right.line)
+	}
+}
+
+func TestDiffView_FallsBackToUnified(t *testing.T) {
+	dv := NewDiffView()
+	dv.Toggle()
+	dv.SetDiff(sampleDiff)
+
+	var buf bytes.Buffer
+	dv.Render(&buf, 100, 30, navigator.Commit{}, navigator.Commit{}, true, Progress{})
+	if plain := ansiRe.ReplaceAllString(buf.String(), ""); !strings.Contains(plain, "+b := 20") || strings.Contains(plain, "│") {
+		t.Errorf("expected unified diff below the split width")
+	}
+
+	buf.Reset()
+	dv.Render(&buf, 140, 30, navigator.Commit{}, navigator.Commit{}, true, Progress{})
+	if !strings.Contains(buf.String(), "│") || !strings.Contains(buf.String(), "s unified") {
+		t.Errorf("expected side-by-side diff on a wide terminal")
+	}
+
+	dv.Split = false
+	buf.Reset()
+	dv.Render(&buf, 140, 30, navigator.Commit{}, navigator.Commit{}, true, Progress{})
+	if strings.Contains(buf.String(), "│") {
+		t.Errorf("expected unified diff with Split off")
+	}
+}
+
+func TestDiffView_ScrollsByLayoutRows(t *testing.T) {
+	dv := NewDiffView()
+	dv.SetDiff(sampleDiff) // 14 lines, 13 side-by-side rows
+	var buf bytes.Buffer
+	dv.Render(&buf, 140, 10, navigator.Commit{}, navigator.Commit{}, true, Progress{})
+	dv.ScrollTo(100, 10)
+	if dv.Offset() != 13-6 {
+		t.Errorf("expected offset clamped to side-by-side rows, got %d", dv.Offset())
+	}
+}
@@ -0,0 +1,462 @@
+package ui
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/anuchito/replay/internal/syntax"
+)
+
+// ColorMode is how many colors the terminal can show.
+type ColorMode int
+
+const (
+	// NoColor leaves out colors but keeps bold, dim, italic, underline and
+	// reverse video, so that headings and search matches still stand out.
+	NoColor ColorMode = iota
+	Colors16
+	Colors256
+	TrueColor
+)
+
+// DetectColorMode picks the color mode for setting, the value of
+// --color: "never", "always" or "auto" (or empty). In auto mode there is
+// no color when stdout isn't a terminal, when NO_COLOR is set or when TERM
+// is "dumb". Otherwise the depth comes from COLORTERM and TERM, with 16
+// colors when they say nothing.
+func DetectColorMode(setting string, isTerminal bool, getenv func(string) string) (ColorMode, error) {
+	switch setting {
+	case "never":
+		return NoColor, nil
+	case "always":
+	case "", "auto":
+		if !isTerminal || getenv("NO_COLOR") != "" || getenv("TERM") == "dumb" {
+			return NoColor, nil
+		}
+	default:
+		return NoColor, fmt.Errorf("invalid color setting %q (one of never, auto, always)", setting)
+	}
+	ct, t := strings.ToLower(getenv("COLORTERM")), getenv("TERM")
+	switch {
+	case ct == "truecolor" || ct == "24bit", strings.HasSuffix(t, "-direct"), strings.HasSuffix(t, "-truecolor"):
+		return TrueColor, nil
+	case strings.Contains(t, "256color"):
+		return Colors256, nil
+	}
+	return Colors16, nil
+}
+
+// The escape sequences of the active theme, set by SetTheme. Every role
+// of the theme has one.
+var (
+	styleAdded      string
+	styleRemoved    string
+	styleHunk       string
+	styleFileHeader string
+	styleTitle      string
+	styleEmphasis   string
+	styleAccent     string
+	styleMuted      string
+	styleMatch      string
+	styleMatchOff   string // ends styleMatch without resetting the rest
+
+	// syntaxColors avoids red and green in the built-in themes so that
+	// added and removed lines stay recognizable: the diff color remains
+	// on everything that isn't a token.
+	syntaxColors map[syntax.Kind]string
+)
+
+const styleReset = "\x1b[0m"
+
+// roles are the parts of the screen a theme styles, as the config file
+// names them.
+var roles = []string{
+	"added", "removed", "hunk", "file-header", // diff lines
+	"title", "emphasis", "accent", "muted", "match", // panes and lists
+	"keyword", "literal", "string", "number", "comment", "key", "heading", // syntax
+}
+
+var syntaxRoles = map[string]syntax.Kind{
+	"keyword": syntax.Keyword,
+	"literal": syntax.Literal,
+	"string":  syntax.String,
+	"number":  syntax.Number,
+	"comment": syntax.Comment,
+	"key":     syntax.Key,
+	"heading": syntax.Heading,
+}
+
+// darkStyles is the default theme. It sticks to the terminal's own
+// palette, so it looks the way the terminal is set up to look.
+var darkStyles = map[string]string{
+	"added":       "green",
+	"removed":     "red",
+	"hunk":        "cyan",
+	"file-header": "bold",
+	"title":       "bold cyan",
+	"emphasis":    "bold",
+	"accent":      "cyan",
+	"muted":       "dim",
+	"match":       "reverse",
+	"keyword":     "magenta",
+	"literal":     "blue",
+	"string":      "yellow",
+	"number":      "cyan",
+	"comment":     "bright-black",
+	"key":         "blue",
+	"heading":     "bold",
+}
+
+// themes change some of the default theme's styles; the other roles keep
+// theirs.
+var themes = map[string]map[string]string{
+	"dark": {},
+	// Darker colors that read well on a white background.
+	"light": {
+		"added":   "#116329",
+		"removed": "#a40e26",
+		"hunk":    "#0550ae",
+		"title":   "bold #0550ae",
+		"accent":  "#0550ae",
+		"muted":   "#6e7781",
+		"keyword": "#8250df",
+		"literal": "#0550ae",
+		"string":  "#0a3069",
+		"number":  "#0550ae",
+		"comment": "#6e7781",
+		"key":     "#953800",
+		"heading": "bold #0550ae",
+	},
+	// Bright colors and no dimmed text.
+	"high-contrast": {
+		"added":       "bold bright-green",
+		"removed":     "bold bright-red",
+		"hunk":        "bold bright-cyan",
+		"file-header": "bold underline",
+		"title":       "bold black on bright-cyan",
+		"accent":      "bright-cyan",
+		"muted":       "italic",
+		"match":       "bold black on bright-yellow",
+		"keyword":     "bold bright-magenta",
+		"literal":     "bright-blue",
+		"string":      "bright-yellow",
+		"number":      "bright-cyan",
+		"comment":     "italic",
+		"key":         "bright-blue",
+		"heading":     "bold underline",
+	},
+}
+
+// Themes returns the names of the built-in themes.
+func Themes() []string {
+	names := make([]string, 0, len(themes))
+	for name := range themes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Theme styles each role: the diff lines, the panes and the syntax
+// highlighting.
+type Theme struct {
+	styles map[string]style
+}
+
+// NewTheme builds the named built-in theme ("" for the default, "dark")
+// with the styles in overrides replacing the theme's for the roles they
+// name. A style is a list of attributes (bold, dim, italic, underline,
+// reverse) and colors separated by spaces; a color after "on" is the
+// background. Colors are the terminal's black, red, green, yellow, blue,
+// magenta, cyan and white, their bright- versions, 256-color palette
+// numbers or #rrggbb.
+func NewTheme(base string, overrides map[string]string) (*Theme, error) {
+	if base == "" {
+		base = "dark"
+	}
+	changes, ok := themes[base]
+	if !ok {
+		return nil, fmt.Errorf("unknown theme %q (one of %s)", base, strings.Join(Themes(), ", "))
+	}
+	t := &Theme{styles: make(map[string]style, len(roles))}
+	for _, specs := range []map[string]string{darkStyles, changes} {
+		for role, spec := range specs {
+			st, err := parseStyle(spec)
+			if err != nil {
+				return nil, fmt.Errorf("theme %s: %s: %w", base, role, err)
+			}
+			t.styles[role] = st
+		}
+	}
+	for _, role := range sortedKeys(overrides) {
+		if _, ok := t.styles[role]; !ok {
+			return nil, fmt.Errorf("unknown style %q (one of %s)", role, strings.Join(roles, ", "))
+		}
+		st, err := parseStyle(overrides[role])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", role, err)
+		}
+		t.styles[role] = st
+	}
+	return t, nil
+}
+
+var defaultTheme, _ = NewTheme("", nil)
+
+func init() {
+	SetTheme(nil, Colors16)
+}
+
+// SetTheme makes t (nil for the default theme) the theme everything is
+// drawn with, written for a terminal with the given color mode.
+func SetTheme(t *Theme, mode ColorMode) {
+	if t == nil {
+		t = defaultTheme
+	}
+	on := func(role string) string { return t.styles[role].sgr(mode) }
+	styleAdded = on("added")
+	styleRemoved = on("removed")
+	styleHunk = on("hunk")
+	styleFileHeader = on("file-header")
+	styleTitle = on("title")
+	styleEmphasis = on("emphasis")
+	styleAccent = on("accent")
+	styleMuted = on("muted")
+	styleMatch = on("match")
+	styleMatchOff = t.styles["match"].off(mode)
+	syntaxColors = make(map[syntax.Kind]string, len(syntaxRoles))
+	for role, kind := range syntaxRoles {
+		syntaxColors[kind] = on(role)
+	}
+}
+
+// style is a parsed theme entry.
+type style struct {
+	attrs  []int // SGR parameters: 1 bold, 2 dim, 3 italic, 4 underline, 7 reverse
+	fg, bg color
+}
+
+var styleAttrs = map[string]int{"bold": 1, "dim": 2, "italic": 3, "underline": 4, "reverse": 7}
+
+// attrsOff turns each attribute off again; bold and dim share one.
+var attrsOff = map[int]string{1: "22", 2: "22", 3: "23", 4: "24", 7: "27"}
+
+func parseStyle(spec string) (style, error) {
+	var st style
+	fields := strings.Fields(strings.ToLower(spec))
+	for i := 0; i < len(fields); i++ {
+		f := fields[i]
+		if a, ok := styleAttrs[f]; ok {
+			st.attrs = append(st.attrs, a)
+			continue
+		}
+		target := &st.fg
+		if f == "on" {
+			if i+1 == len(fields) {
+				return st, fmt.Errorf("no color after \"on\" in %q", spec)
+			}
+			i++
+			f, target = fields[i], &st.bg
+		}
+		c, err := parseColor(f)
+		if err != nil {
+			return st, err
+		}
+		*target = c
+	}
+	return st, nil
+}
+
+// sgr returns the escape sequence that turns the style on, or "" for a
+// plain style.
+func (st style) sgr(mode ColorMode) string {
+	params := make([]string, 0, len(st.attrs)+2)
+	for _, a := range st.attrs {
+		params = append(params, strconv.Itoa(a))
+	}
+	if p := st.fg.params(mode, false); p != "" {
+		params = append(params, p)
+	}
+	if p := st.bg.params(mode, true); p != "" {
+		params = append(params, p)
+	}
+	if len(params) == 0 {
+		return ""
+	}
+	return "\x1b[" + strings.Join(params, ";") + "m"
+}
+
+// off returns the escape sequence that turns the style off again while
+// leaving other attributes alone.
+func (st style) off(mode ColorMode) string {
+	var params []string
+	seen := map[string]bool{}
+	for _, a := range st.attrs {
+		p := attrsOff[a]
+		if !seen[p] {
+			params, seen[p] = append(params, p), true
+		}
+	}
+	if st.fg.params(mode, false) != "" {
+		params = append(params, "39")
+	}
+	if st.bg.params(mode, true) != "" {
+		params = append(params, "49")
+	}
+	if len(params) == 0 {
+		return ""
+	}
+	return "\x1b[" + strings.Join(params, ";") + "m"
+}
+
+type colorKind uint8
+
+const (
+	colorDefault colorKind = iota
+	colorBasic             // one of the terminal's 16 colors, n
+	colorIndexed           // the 256-color palette, n
+	colorRGB
+)
+
+type color struct {
+	kind    colorKind
+	n       uint8
+	r, g, b uint8
+}
+
+var basicColors = []string{"black", "red", "green", "yellow", "blue", "magenta", "cyan", "white"}
+
+func parseColor(s string) (color, error) {
+	if s == "default" {
+		return color{}, nil
+	}
+	if s == "gray" || s == "grey" {
+		s = "bright-black"
+	}
+	name, bright := strings.CutPrefix(s, "bright-")
+	for i, b := range basicColors {
+		if name == b {
+			if bright {
+				i += 8
+			}
+			return color{kind: colorBasic, n: uint8(i)}, nil
+		}
+	}
+	if hex, ok := strings.CutPrefix(s, "#"); ok && len(hex) == 6 {
+		if v, err := strconv.ParseUint(hex, 16, 32); err == nil {
+			return color{kind: colorRGB, r: uint8(v >> 16), g: uint8(v >> 8), b: uint8(v)}, nil
+		}
+	}
+	if n, err := strconv.ParseUint(s, 10, 8); err == nil {
+		return color{kind: colorIndexed, n: uint8(n)}, nil
+	}
+	return color{}, fmt.Errorf("unknown color or attribute %q", s)
+}
+
+// params returns the SGR parameters selecting c as the foreground or
+// background, approximating it with the colors mode allows.
+func (c color) params(mode ColorMode, bg bool) string {
+	if c.kind == colorDefault || mode == NoColor {
+		return ""
+	}
+	if c.kind == colorRGB && mode < TrueColor {
+		c = color{kind: colorIndexed, n: nearest256(c.r, c.g, c.b)}
+	}
+	if c.kind == colorIndexed && c.n >= 16 && mode < Colors256 {
+		r, g, b := paletteRGB(c.n)
+		c = color{kind: colorBasic, n: nearest16(r, g, b)}
+	}
+	base := 30
+	if bg {
+		base = 40
+	}
+	switch {
+	case c.kind == colorRGB:
+		return fmt.Sprintf("%d;2;%d;%d;%d", base+8, c.r, c.g, c.b)
+	case c.n >= 16:
+		return fmt.Sprintf("%d;5;%d", base+8, c.n)
+	case c.n >= 8:
+		return strconv.Itoa(base + 60 + int(c.n) - 8)
+	}
+	return strconv.Itoa(base + int(c.n))
+}
+
+// xterm's default values for the 16 basic colors.
+var basicRGB = [16][3]uint8{
+	{0, 0, 0}, {205, 0, 0}, {0, 205, 0}, {205, 205, 0},
+	{0, 0, 238}, {205, 0, 205}, {0, 205, 205}, {229, 229, 229},
+	{127, 127, 127}, {255, 0, 0}, {0, 255, 0}, {255, 255, 0},
+	{92, 92, 255}, {255, 0, 255}, {0, 255, 255}, {255, 255, 255},
+}
+
+// paletteRGB returns the color of entry n of the xterm 256-color palette:
+// the 16 basic colors, a 6×6×6 cube and a ramp of 24 grays.
+func paletteRGB(n uint8) (r, g, b uint8) {
+	switch {
+	case n < 16:
+		c := basicRGB[n]
+		return c[0], c[1], c[2]
+	case n < 232:
+		i := n - 16
+		return cubeLevel(i / 36), cubeLevel(i / 6 % 6), cubeLevel(i % 6)
+	}
+	v := 8 + 10*(n-232)
+	return v, v, v
+}
+
+func cubeLevel(i uint8) uint8 {
+	if i == 0 {
+		return 0
+	}
+	return 55 + 40*i
+}
+
+// nearest256 picks the cube or gray entry of the 256-color palette
+// closest to r, g, b.
+func nearest256(r, g, b uint8) uint8 {
+	cube := func(v uint8) uint8 {
+		switch {
+		case v < 48:
+			return 0
+		case v < 115:
+			return 1
+		}
+		return (v - 35) / 40
+	}
+	best := 16 + 36*cube(r) + 6*cube(g) + cube(b)
+	avg := (int(r) + int(g) + int(b)) / 3
+	gray := uint8(232 + min(max(avg-3, 0)/10, 23))
+	if distance(gray, r, g, b) < distance(best, r, g, b) {
+		best = gray
+	}
+	return best
+}
+
+// nearest16 picks the basic color closest to r, g, b.
+func nearest16(r, g, b uint8) uint8 {
+	best := uint8(0)
+	for n := uint8(1); n < 16; n++ {
+		if distance(n, r, g, b) < distance(best, r, g, b) {
+			best = n
+		}
+	}
+	return best
+}
+
+// distance is the squared distance between palette entry n and r, g, b.
+func distance(n, r, g, b uint8) int {
+	pr, pg, pb := paletteRGB(n)
+	dr, dg, db := int(pr)-int(r), int(pg)-int(g), int(pb)-int(b)
+	return dr*dr + dg*dg + db*db
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
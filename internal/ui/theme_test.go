@@ -0,0 +1,157 @@
+package ui
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/anuchito/replay/internal/syntax"
+)
+
+func TestDetectColorMode(t *testing.T) {
+	tests := []struct {
+		setting  string
+		terminal bool
+		env      map[string]string
+		want     ColorMode
+	}{
+		{"auto", true, map[string]string{"TERM": "xterm"}, Colors16},
+		{"auto", true, map[string]string{"TERM": "xterm-256color"}, Colors256},
+		{"auto", true, map[string]string{"TERM": "xterm-256color", "COLORTERM": "truecolor"}, TrueColor},
+		{"", true, map[string]string{"TERM": "xterm-direct"}, TrueColor},
+		{"auto", false, map[string]string{"TERM": "xterm-256color"}, NoColor},
+		{"auto", true, map[string]string{"TERM": "xterm-256color", "NO_COLOR": "1"}, NoColor},
+		{"auto", true, map[string]string{"TERM": "dumb"}, NoColor},
+		{"always", false, map[string]string{"TERM": "xterm-256color", "NO_COLOR": "1"}, Colors256},
+		{"always", false, map[string]string{"TERM": "dumb"}, Colors16},
+		{"never", true, map[string]string{"COLORTERM": "24bit"}, NoColor},
+	}
+	for _, tt := range tests {
+		got, err := DetectColorMode(tt.setting, tt.terminal, func(k string) string { return tt.env[k] })
+		if err != nil {
+			t.Errorf("%q %v %v: unexpected error: %v", tt.setting, tt.terminal, tt.env, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%q %v %v: expected mode %d, got %d", tt.setting, tt.terminal, tt.env, tt.want, got)
+		}
+	}
+	if _, err := DetectColorMode("sometimes", true, func(string) string { return "" }); err == nil {
+		t.Error("expected an error for --color=sometimes")
+	}
+}
+
+func TestStyle_SGR(t *testing.T) {
+	tests := []struct {
+		spec string
+		mode ColorMode
+		want string
+	}{
+		{"green", Colors16, "\x1b[32m"},
+		{"bold bright-black", Colors16, "\x1b[1;90m"},
+		{"black on bright-yellow", TrueColor, "\x1b[30;103m"},
+		{"208", Colors256, "\x1b[38;5;208m"},
+		{"208", Colors16, "\x1b[33m"},
+		{"#0550ae", TrueColor, "\x1b[38;2;5;80;174m"},
+		{"#0550ae", Colors256, "\x1b[38;5;25m"},
+		{"#0550ae", Colors16, "\x1b[34m"},
+		{"on #303030", Colors256, "\x1b[48;5;236m"},
+		{"bold reverse red", NoColor, "\x1b[1;7m"},
+		{"red", NoColor, ""},
+		{"", TrueColor, ""},
+	}
+	for _, tt := range tests {
+		st, err := parseStyle(tt.spec)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", tt.spec, err)
+			continue
+		}
+		if got := st.sgr(tt.mode); got != tt.want {
+			t.Errorf("%q in mode %d: expected %q, got %q", tt.spec, tt.mode, tt.want, got)
+		}
+	}
+	for _, bad := range []string{"purple", "bold on", "#12345", "256", "bright-default"} {
+		if _, err := parseStyle(bad); err == nil {
+			t.Errorf("%q: expected an error", bad)
+		}
+	}
+}
+
+func TestStyle_Off(t *testing.T) {
+	st, _ := parseStyle("bold dim black on yellow")
+	if got := st.off(Colors16); got != "\x1b[22;39;49m" {
+		t.Errorf("expected bold, dim and both colors turned off, got %q", got)
+	}
+	if got := st.off(NoColor); got != "\x1b[22m" {
+		t.Errorf("expected only bold and dim turned off without color, got %q", got)
+	}
+}
+
+func TestNewTheme(t *testing.T) {
+	for _, name := range Themes() {
+		theme, err := NewTheme(name, nil)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", name, err)
+			continue
+		}
+		for _, role := range roles {
+			if _, ok := theme.styles[role]; !ok {
+				t.Errorf("%s: expected a style for %s", name, role)
+			}
+		}
+	}
+
+	theme, err := NewTheme("light", map[string]string{"added": "bold 22", "match": ""})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := theme.styles["added"].sgr(Colors256); got != "\x1b[1;38;5;22m" {
+		t.Errorf("expected the override for added, got %q", got)
+	}
+	if got := theme.styles["match"].sgr(Colors256); got != "" {
+		t.Errorf("expected match left plain, got %q", got)
+	}
+	if got := theme.styles["removed"].sgr(TrueColor); got != "\x1b[38;2;164;14;38m" {
+		t.Errorf("expected removed from the light theme, got %q", got)
+	}
+
+	errs := []struct {
+		base      string
+		overrides map[string]string
+		want      string
+	}{
+		{"solarized", nil, `unknown theme "solarized"`},
+		{"", map[string]string{"addded": "green"}, `unknown style "addded"`},
+		{"", map[string]string{"added": "lime"}, `added: unknown color or attribute "lime"`},
+	}
+	for _, tt := range errs {
+		_, err := NewTheme(tt.base, tt.overrides)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s %v: expected error containing %q, got %v", tt.base, tt.overrides, tt.want, err)
+		}
+	}
+}
+
+func TestSetTheme(t *testing.T) {
+	t.Cleanup(func() { SetTheme(nil, Colors16) })
+	theme, err := NewTheme("high-contrast", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	SetTheme(theme, Colors16)
+	if got := highlight("a race", regexp.MustCompile("race")); got != "a \x1b[1;30;103mrace\x1b[22;39;49m" {
+		t.Errorf("expected the high-contrast match style, got %q", got)
+	}
+	if got := renderDiffLine("+x", nil, 10, 0); !strings.HasPrefix(got, "\x1b[1;92m+") {
+		t.Errorf("expected bright green added lines, got %q", got)
+	}
+
+	SetTheme(theme, NoColor)
+	if got := renderDiffLine("+x", nil, 10, 0); !strings.HasPrefix(got, "\x1b[1m+") {
+		t.Errorf("expected added lines in bold only without color, got %q", got)
+	}
+	if syntaxColors[syntax.String] != "" {
+		t.Errorf("expected no string color without color, got %q", syntaxColors[syntax.String])
+	}
+}
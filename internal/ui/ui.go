@@ -3,12 +3,17 @@ package ui
 import (
 	"fmt"
 	"io"
+	"regexp"
+	"strings"
+	"time"
 
 	"github.com/anuchito/replay/internal/navigator"
 )
 
 type UI struct {
-	out io.Writer
+	out   io.Writer
+	Width int     // terminal columns; commit lines are cut to fit, 0 for no limit
+	Keys  *Keymap // for the banner; nil for the default
 }
 
 func New(out io.Writer) *UI {
@@ -18,15 +23,121 @@ func New(out io.Writer) *UI {
 func (u *UI) PrintBanner() {
 	fmt.Fprint(u.out, "Replay Mode\r\n")
 	fmt.Fprint(u.out, "-----------\r\n")
-	fmt.Fprint(u.out, "n → next\r\n")
-	fmt.Fprint(u.out, "p → previous\r\n")
-	fmt.Fprint(u.out, "d → toggle next commit diff\r\n")
-	fmt.Fprint(u.out, "q → quit\r\n")
+	for _, line := range u.Keys.BannerLines() {
+		fmt.Fprintf(u.out, "%s\r\n", line)
+	}
 	fmt.Fprint(u.out, "\r\n")
 }
 
-func (u *UI) PrintCommit(commit navigator.Commit, current, total int) {
-	fmt.Fprintf(u.out, "[%d/%d] %s %s\r\n", current, total, commit.Hash, commit.Message)
+// Progress describes where a commit sits in the replay range.
+type Progress struct {
+	Pos, Total int    // within the active filter; Pos is 0 if the commit is hidden
+	Of         int    // size of the whole range when a filter is active, else 0
+	Loading    bool   // the range is still being read, so totals aren't known
+	Marks      []rune // marks set on the commit
+
+	// Search state: Matches is the number of commits matching the active
+	// search, Match the 1-based ordinal of this commit among them (0 if it
+	// doesn't match). Highlight marks the matched text in the subject.
+	Match, Matches int
+	Highlight      *regexp.Regexp
+
+	// Graph state: Merge/Fork flag commits with several in-range parents or
+	// children; Branch is how many side branches deep the user has stepped.
+	Merge, Fork bool
+	Branch      int
+
+	// Time-lapse state: the commit's author date and how long after the
+	// start of the range it was made. Hidden when Date is zero.
+	Date    time.Time
+	Elapsed time.Duration
+}
+
+// String renders the position indicator, e.g. "3/15 'a  match 2/5" or,
+// with a filter active, "3/12 of 140". While the range is still loading
+// the total reads "3/loading…".
+func (p Progress) String() string {
+	pos := fmt.Sprint(p.Pos)
+	if p.Pos == 0 {
+		pos = "-"
+	}
+	s := fmt.Sprintf("%s/%d", pos, p.Total)
+	switch {
+	case p.Loading:
+		s = pos + "/loading…"
+	case p.Of > 0:
+		s += fmt.Sprintf(" of %d", p.Of)
+	}
+	for _, m := range p.Marks {
+		s += " '" + string(m)
+	}
+	if p.Merge {
+		s += "  merge"
+	}
+	if p.Fork {
+		s += "  fork"
+	}
+	if p.Branch > 0 {
+		s += fmt.Sprintf("  side branch %d", p.Branch)
+	}
+	switch {
+	case p.Match > 0:
+		s += fmt.Sprintf("  match %d/%d", p.Match, p.Matches)
+	case p.Matches > 0:
+		s += fmt.Sprintf("  %d matches", p.Matches)
+	}
+	if !p.Date.IsZero() {
+		s += fmt.Sprintf("  %s %s", p.Date.Format("2006-01-02 15:04"), FormatElapsed(p.Elapsed))
+	}
+	return s
+}
+
+// FormatElapsed renders project time compactly: "+45m", "+3h05m", "+2d04h".
+func FormatElapsed(d time.Duration) string {
+	sign := "+"
+	if d < 0 {
+		sign, d = "-", -d
+	}
+	d = d.Round(time.Minute)
+	days, hours, mins := int(d/(24*time.Hour)), int(d/time.Hour)%24, int(d/time.Minute)%60
+	switch {
+	case days > 0:
+		return fmt.Sprintf("%s%dd%02dh", sign, days, hours)
+	case hours > 0:
+		return fmt.Sprintf("%s%dh%02dm", sign, hours, mins)
+	default:
+		return fmt.Sprintf("%s%dm", sign, mins)
+	}
+}
+
+// AutoplayStatus renders the autoplay countdown, e.g.
+// "▶ next in 1.4s (every 2s)  +=/- speed  a pauses".
+func (u *UI) AutoplayStatus(remaining time.Duration, pace string) string {
+	controls := u.Keys.orDefault().hints(hintFor("speed", ActionFaster, ActionSlower), hintFor("pauses", ActionAutoplay))
+	return strings.TrimSuffix(fmt.Sprintf("▶ next in %.1fs (%s)  %s", remaining.Seconds(), pace, controls), "  ")
+}
+
+func (u *UI) PrintCommit(commit navigator.Commit, prog Progress) {
+	prefix := fmt.Sprintf("[%s] %s ", prog, commit.Hash)
+	msg := commit.Message
+	if u.Width > 0 {
+		msg = Truncate(msg, max(u.Width-StringWidth(prefix), 1))
+	}
+	fmt.Fprintf(u.out, "%s%s\r\n", prefix, highlight(msg, prog.Highlight))
+}
+
+// highlight shows every match of re in s in the theme's match style,
+// reverse video by default. A nil re returns s unchanged.
+func highlight(s string, re *regexp.Regexp) string {
+	if re == nil {
+		return s
+	}
+	return re.ReplaceAllStringFunc(s, func(m string) string {
+		if m == "" {
+			return m
+		}
+		return styleMatch + m + styleMatchOff
+	})
 }
 
 func (u *UI) PrintError(msg string) {
@@ -2,8 +2,10 @@ package ui
 
 import (
 	"bytes"
+	"regexp"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/anuchito/replay/internal/navigator"
 )
@@ -33,7 +35,7 @@ func TestPrintCommit(t *testing.T) {
 	u := New(&buf)
 
 	commit := navigator.Commit{Hash: "abc1234", Message: "add feature"}
-	u.PrintCommit(commit, 3, 15)
+	u.PrintCommit(commit, Progress{Pos: 3, Total: 15})
 
 	out := buf.String()
 	if !strings.Contains(out, "abc1234") {
@@ -52,7 +54,7 @@ func TestPrintCommit_UsesRawNewline(t *testing.T) {
 	u := New(&buf)
 
 	commit := navigator.Commit{Hash: "abc1234", Message: "add feature"}
-	u.PrintCommit(commit, 1, 5)
+	u.PrintCommit(commit, Progress{Pos: 1, Total: 5})
 
 	out := buf.String()
 	if !strings.HasSuffix(out, "\r\n") {
@@ -81,3 +83,98 @@ func TestPrintError_UsesRawNewline(t *testing.T) {
 		t.Errorf("PrintError should end with \\r\\n for raw terminal compatibility, got %q", out)
 	}
 }
+
+func TestPrintCommit_ShowsMarks(t *testing.T) {
+	var buf bytes.Buffer
+	u := New(&buf)
+
+	commit := navigator.Commit{Hash: "abc1234", Message: "add feature"}
+	u.PrintCommit(commit, Progress{Pos: 2, Total: 9, Marks: []rune{'a', 'b'}})
+
+	out := buf.String()
+	if !strings.Contains(out, "[2/9 'a 'b]") {
+		t.Errorf("expected marks in position indicator, got %q", out)
+	}
+}
+
+func TestPrintCommit_SearchMatch(t *testing.T) {
+	var buf bytes.Buffer
+	u := New(&buf)
+
+	commit := navigator.Commit{Hash: "abc1234", Message: "fix race in watcher"}
+	u.PrintCommit(commit, Progress{Pos: 4, Total: 9, Match: 2, Matches: 3, Highlight: regexp.MustCompile("race")})
+
+	out := buf.String()
+	if !strings.Contains(out, "match 2/3") {
+		t.Errorf("expected match counter, got %q", out)
+	}
+	if !strings.Contains(out, "\x1b[7mrace\x1b[27m") {
+		t.Errorf("expected highlighted match, got %q", out)
+	}
+}
+
+func TestProgress_Filtered(t *testing.T) {
+	p := Progress{Pos: 3, Total: 12, Of: 140}
+	if got := p.String(); got != "3/12 of 140" {
+		t.Errorf("expected '3/12 of 140', got %q", got)
+	}
+	p.Pos = 0
+	if got := p.String(); got != "-/12 of 140" {
+		t.Errorf("expected hidden commit shown as '-/12 of 140', got %q", got)
+	}
+}
+
+func TestAutoplayStatus(t *testing.T) {
+	got := New(nil).AutoplayStatus(1400*time.Millisecond, "every 2s")
+	if !strings.Contains(got, "next in 1.4s") || !strings.Contains(got, "every 2s") {
+		t.Errorf("unexpected status %q", got)
+	}
+}
+
+func TestProgress_TimeLapse(t *testing.T) {
+	p := Progress{
+		Pos: 3, Total: 9,
+		Date:    time.Date(2024, 5, 1, 14, 5, 0, 0, time.UTC),
+		Elapsed: 26*time.Hour + 30*time.Minute,
+	}
+	if got, want := p.String(), "3/9  2024-05-01 14:05 +1d02h"; got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestFormatElapsed(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{0, "+0m"},
+		{45 * time.Minute, "+45m"},
+		{3*time.Hour + 5*time.Minute, "+3h05m"},
+		{52 * time.Hour, "+2d04h"},
+		{-90 * time.Minute, "-1h30m"},
+	}
+	for _, tt := range tests {
+		if got := FormatElapsed(tt.d); got != tt.want {
+			t.Errorf("%v: expected %q, got %q", tt.d, tt.want, got)
+		}
+	}
+}
+
+func TestProgress_Loading(t *testing.T) {
+	p := Progress{Pos: 3, Total: 500, Of: 900, Loading: true}
+	if got := p.String(); got != "3/loading…" {
+		t.Errorf("expected total to read loading…, got %q", got)
+	}
+}
+
+func TestPrintCommit_FitsWidth(t *testing.T) {
+	var buf bytes.Buffer
+	u := New(&buf)
+	u.Width = 24
+	u.PrintCommit(navigator.Commit{Hash: "abc1234", Message: "長いメッセージ long message"}, Progress{Pos: 1, Total: 5})
+
+	line := strings.TrimSuffix(buf.String(), "\r\n")
+	if line != "[1/5] abc1234 長いメッセ" {
+		t.Errorf("expected the message cut at 24 columns, got %q", line)
+	}
+}
@@ -0,0 +1,118 @@
+package ui
+
+import (
+	"sort"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Text is measured in terminal columns per grapheme cluster, the way
+// terminals draw it: East Asian wide and fullwidth characters and emoji
+// take two columns, combining marks and joiners none, and a flag or a
+// ZWJ emoji sequence counts once.
+
+// wide lists the code point ranges drawn two columns wide: the East Asian
+// Wide and Fullwidth ranges and the emoji that default to emoji
+// presentation.
+var wide = [][2]rune{
+	{0x1100, 0x115f}, {0x231a, 0x231b}, {0x2329, 0x232a}, {0x23e9, 0x23ec},
+	{0x23f0, 0x23f0}, {0x23f3, 0x23f3}, {0x25fd, 0x25fe}, {0x2614, 0x2615},
+	{0x2648, 0x2653}, {0x267f, 0x267f}, {0x2693, 0x2693}, {0x26a1, 0x26a1},
+	{0x26aa, 0x26ab}, {0x26bd, 0x26be}, {0x26c4, 0x26c5}, {0x26ce, 0x26ce},
+	{0x26d4, 0x26d4}, {0x26ea, 0x26ea}, {0x26f2, 0x26f3}, {0x26f5, 0x26f5},
+	{0x26fa, 0x26fa}, {0x26fd, 0x26fd}, {0x2705, 0x2705}, {0x270a, 0x270b},
+	{0x2728, 0x2728}, {0x274c, 0x274c}, {0x274e, 0x274e}, {0x2753, 0x2755},
+	{0x2757, 0x2757}, {0x2795, 0x2797}, {0x27b0, 0x27b0}, {0x27bf, 0x27bf},
+	{0x2b1b, 0x2b1c}, {0x2b50, 0x2b50}, {0x2b55, 0x2b55}, {0x2e80, 0x303e},
+	{0x3041, 0x33ff}, {0x3400, 0x4dbf}, {0x4e00, 0x9fff}, {0xa000, 0xa4cf},
+	{0xa960, 0xa97f}, {0xac00, 0xd7a3}, {0xf900, 0xfaff}, {0xfe10, 0xfe19},
+	{0xfe30, 0xfe6f}, {0xff00, 0xff60}, {0xffe0, 0xffe6}, {0x16fe0, 0x16fe4},
+	{0x17000, 0x18cff}, {0x1b000, 0x1b2ff}, {0x1f004, 0x1f004}, {0x1f0cf, 0x1f0cf},
+	{0x1f18e, 0x1f18e}, {0x1f191, 0x1f19a}, {0x1f200, 0x1f251}, {0x1f300, 0x1f64f},
+	{0x1f680, 0x1f6ff}, {0x1f7e0, 0x1f7eb}, {0x1f900, 0x1f9ff}, {0x1fa70, 0x1faff},
+	{0x20000, 0x2fffd}, {0x30000, 0x3fffd},
+}
+
+// runeWidth returns the columns r takes on its own. Control characters
+// count as zero; callers expand tabs before measuring.
+func runeWidth(r rune) int {
+	switch {
+	case r < 0x20 || (r >= 0x7f && r < 0xa0):
+		return 0
+	case r < 0x300:
+		return 1
+	case zeroWidth(r):
+		return 0
+	}
+	i := sort.Search(len(wide), func(i int) bool { return wide[i][1] >= r })
+	if i < len(wide) && wide[i][0] <= r {
+		return 2
+	}
+	return 1
+}
+
+// zeroWidth reports whether r attaches to the character before it:
+// combining marks, format characters such as the zero-width joiner,
+// variation selectors and Hangul medial and final jamo.
+func zeroWidth(r rune) bool {
+	return unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf) ||
+		(r >= 0x1160 && r <= 0x11ff) || (r >= 0xfe00 && r <= 0xfe0f) || (r >= 0xe0100 && r <= 0xe01ef)
+}
+
+func isRegional(r rune) bool     { return r >= 0x1f1e6 && r <= 0x1f1ff }
+func isSkinModifier(r rune) bool { return r >= 0x1f3fb && r <= 0x1f3ff }
+
+// nextCluster returns the length in bytes and the width in columns of the
+// grapheme cluster at the start of s.
+func nextCluster(s string) (n, width int) {
+	r, size := utf8.DecodeRuneInString(s)
+	n, width = size, runeWidth(r)
+	regional := isRegional(r)
+	for n < len(s) {
+		next, size := utf8.DecodeRuneInString(s[n:])
+		switch {
+		case next == 0x200d: // zero-width joiner: the next rune joins the cluster
+			n += size
+			if n < len(s) {
+				_, size = utf8.DecodeRuneInString(s[n:])
+				n += size
+			}
+		case next == 0xfe0f: // emoji presentation
+			n, width = n+size, 2
+		case regional && isRegional(next): // a pair of regional indicators is a flag
+			n, width, regional = n+size, 2, false
+		case isSkinModifier(next) || zeroWidth(next):
+			n += size
+		default:
+			return n, width
+		}
+	}
+	return n, width
+}
+
+// StringWidth returns the number of columns s takes in a terminal.
+func StringWidth(s string) int {
+	width := 0
+	for i := 0; i < len(s); {
+		n, w := nextCluster(s[i:])
+		i, width = i+n, width+w
+	}
+	return width
+}
+
+// Truncate cuts s to at most maxW columns without splitting a character
+// or a grapheme cluster. A maxW of zero or less leaves s unchanged.
+func Truncate(s string, maxW int) string {
+	if maxW <= 0 {
+		return s
+	}
+	width := 0
+	for i := 0; i < len(s); {
+		n, w := nextCluster(s[i:])
+		if width+w > maxW {
+			return s[:i]
+		}
+		i, width = i+n, width+w
+	}
+	return s
+}
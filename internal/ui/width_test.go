@@ -0,0 +1,54 @@
+package ui
+
+import "testing"
+
+func TestStringWidth(t *testing.T) {
+	tests := []struct {
+		s    string
+		want int
+	}{
+		{"", 0},
+		{"hello", 5},
+		{"héllo", 5},
+		{"é", 1},              // e + combining acute
+		{"漢字", 4},              // CJK
+		{"ｈｉ", 4},              // fullwidth latin
+		{"한국어", 6},             // Hangul syllables
+		{"🎉", 2},               // emoji
+		{"❤️", 2},              // text symbol with emoji presentation
+		{"👍🏽", 2},              // skin tone modifier
+		{"👩‍💻", 2},             // ZWJ sequence
+		{"🇯🇵", 2},              // flag
+		{"─│→", 3},             // box drawing and arrows are narrow
+		{"a\x1bb", 2},          // control characters take no space
+		{"fix: 漢字 🎉 done", 17}, // mixed
+	}
+	for _, tt := range tests {
+		if got := StringWidth(tt.s); got != tt.want {
+			t.Errorf("StringWidth(%q): expected %d, got %d", tt.s, tt.want, got)
+		}
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		s    string
+		maxW int
+		want string
+	}{
+		{"hello", 10, "hello"},
+		{"hello", 3, "hel"},
+		{"hello", 0, "hello"},
+		{"漢字かな", 5, "漢字"}, // a wide character never straddles the edge
+		{"漢字かな", 6, "漢字か"},
+		{"aéb", 2, "aé"}, // the combining mark stays with its base
+		{"👩‍💻x", 2, "👩‍💻"},
+		{"🇯🇵🇫🇷", 3, "🇯🇵"},
+		{"héllo wörld", 7, "héllo w"},
+	}
+	for _, tt := range tests {
+		if got := Truncate(tt.s, tt.maxW); got != tt.want {
+			t.Errorf("Truncate(%q, %d): expected %q, got %q", tt.s, tt.maxW, tt.want, got)
+		}
+	}
+}
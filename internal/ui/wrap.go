@@ -0,0 +1,247 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/anuchito/replay/internal/syntax"
+)
+
+// collapseWidth is the widest line the wrap mode will wrap. Files with a
+// longer line in their hunks, typically minified or generated, are
+// collapsed to a single row instead of filling screens with one line.
+const collapseWidth = 1000
+
+// wrapMarker starts the continuation rows of a wrapped line, in the
+// column of the diff marker.
+const wrapMarker = "↪"
+
+// displayRow is one screen row of the diff area: part of a unified diff
+// line or a side-by-side row.
+type displayRow struct {
+	row  int // index into diffLines or sideRows
+	part int // wrapped segment, 0 for the first; -1 for a collapsed or folded file
+}
+
+// diffFile summarizes the hunks of one file in the diff.
+type diffFile struct {
+	lines   int      // hunk lines
+	longest int      // columns of the widest hunk line
+	start   int      // index of its first diff line
+	size    int      // diff lines, its header included
+	stat    fileStat // path and line counts; no path before the first file
+}
+
+// scanFiles records which file each diff line belongs to and marks the
+// hunks of files too wide to wrap.
+func (dv *DiffView) scanFiles(lines []string) {
+	dv.fileOf = make([]int, len(lines))
+	dv.collapsed = make([]bool, len(lines))
+	dv.files = nil
+	stats := diffStat(lines)
+	inHunk := make([]bool, len(lines))
+	file, hunk := -1, false
+	for i, l := range lines {
+		if file < 0 || strings.HasPrefix(l, "diff ") {
+			file, hunk = file+1, false
+			f := diffFile{start: i}
+			if strings.HasPrefix(l, "diff ") && len(stats) > 0 {
+				f.stat, stats = stats[0], stats[1:]
+			}
+			dv.files = append(dv.files, f)
+		}
+		if strings.HasPrefix(l, "@@") {
+			hunk = true
+		}
+		dv.fileOf[i], inHunk[i] = file, hunk
+		dv.files[file].size++
+		if hunk {
+			f := &dv.files[file]
+			f.lines++
+			f.longest = max(f.longest, StringWidth(expandTabs(l, tabWidth)))
+		}
+	}
+	for i := range lines {
+		dv.collapsed[i] = inHunk[i] && dv.files[dv.fileOf[i]].longest > collapseWidth
+	}
+	dv.folded = make([]bool, len(dv.files))
+}
+
+// source is the index of the diff line a side-by-side row starts at.
+func (r sideRow) source() int {
+	switch {
+	case r.span:
+		return r.line
+	case r.left.kind != 0:
+		return r.left.line
+	}
+	return r.right.line
+}
+
+// relayout splits the diff into display rows for a terminal termW columns
+// wide, keeping the diff line at the top of the screen in place.
+func (dv *DiffView) relayout(termW int) {
+	top := -1
+	if dv.scrollOffset < len(dv.layout) {
+		top = dv.rowSource(dv.layout[dv.scrollOffset].row)
+	}
+	dv.split = dv.Split && dv.SplitFits(termW)
+	dv.laidOut = layoutKey{termW, dv.split, dv.Wrap}
+
+	n := len(dv.diffLines)
+	if dv.split {
+		n = len(dv.sideRows)
+	}
+	dv.layout = dv.layout[:0]
+	placeholder := -1 // file whose collapsed or folded row was added last
+	for row := 0; row < n; row++ {
+		src := dv.rowSource(row)
+		f := dv.fileOf[src]
+		if dv.folded[f] && src != dv.files[f].start || dv.Wrap && dv.collapsed[src] {
+			if f != placeholder {
+				dv.layout = append(dv.layout, displayRow{row, -1})
+				placeholder = f
+			}
+			continue
+		}
+		parts := 1
+		if dv.Wrap {
+			parts = dv.rowParts(row, termW)
+		}
+		for p := 0; p < parts; p++ {
+			dv.layout = append(dv.layout, displayRow{row, p})
+		}
+	}
+
+	dv.scrollOffset = 0
+	for i, d := range dv.layout {
+		if dv.rowSource(d.row) >= top {
+			dv.scrollOffset = i
+			break
+		}
+	}
+}
+
+// rowSource maps a row of the current layout to its diff line.
+func (dv *DiffView) rowSource(row int) int {
+	if dv.split {
+		return dv.sideRows[row].source()
+	}
+	return row
+}
+
+// rowParts is the number of screen rows a row takes when wrapped.
+func (dv *DiffView) rowParts(row, termW int) int {
+	if !dv.split {
+		return lineParts(dv.diffLines[row], termW)
+	}
+	r := dv.sideRows[row]
+	if r.span {
+		return lineParts(r.full, termW)
+	}
+	half := (termW - 1) / 2
+	return max(cellParts(r.left, half, dv.numW), cellParts(r.right, termW-1-half, dv.numW))
+}
+
+func lineParts(line string, termW int) int {
+	if line == "" {
+		return 1
+	}
+	return len(wrapColumns(line[1:], termW-1, tabWidth))
+}
+
+func cellParts(c sideCell, width, numW int) int {
+	if c.kind == 0 {
+		return 1
+	}
+	return len(wrapColumns(c.text, max(width-numW-2, 0), splitTabWidth))
+}
+
+// wrapColumns breaks text into segments of at most width columns, never
+// inside a grapheme cluster, and returns the column each segment starts
+// at. It breaks where paint stops, so that painting segment i with its
+// start as skip draws exactly that segment. Tabs are only whitespace, so
+// they may be broken anywhere.
+func wrapColumns(text string, width, tabW int) []int {
+	starts := []int{0}
+	if width <= 0 {
+		return starts
+	}
+	col := 0
+	for i := 0; i < len(text); {
+		n, w := nextCluster(text[i:])
+		start := starts[len(starts)-1]
+		switch {
+		case text[i] == '\t':
+			w = tabW - col%tabW
+			for col+w-start > width {
+				start += width
+				starts = append(starts, start)
+			}
+		case col+w-start > width && col > start:
+			starts = append(starts, col)
+		}
+		col += w
+		i += n
+	}
+	return starts
+}
+
+// renderRow draws one display row.
+func (dv *DiffView) renderRow(d displayRow, termW int) string {
+	switch {
+	case d.part < 0:
+		i := dv.fileOf[dv.rowSource(d.row)]
+		f := dv.files[i]
+		text := fmt.Sprintf("⋯ %d lines not wrapped: the longest is %d columns (minified or generated?)  %s", f.lines, f.longest, dv.Keys.orDefault().hints(hintFor("turns wrapping off", ActionToggleWrap)))
+		if dv.folded[i] {
+			text = fmt.Sprintf("⋯ %d lines folded  %s unfolds", f.size-1, dv.Keys.orDefault().Hint(ActionToggleFold))
+		}
+		return styleMuted + Truncate(text, termW) + styleReset
+	case dv.split && dv.Wrap:
+		return renderSideRowPart(dv.sideRows[d.row], termW, dv.numW, dv.spans, d.part)
+	case dv.split:
+		return renderSideRow(dv.sideRows[d.row], termW, dv.numW, dv.spans, dv.hOffset)
+	case dv.Wrap:
+		return renderLinePart(dv.diffLines[d.row], dv.spans[d.row], termW, d.part)
+	}
+	return renderDiffLine(dv.diffLines[d.row], dv.spans[d.row], termW, dv.hOffset)
+}
+
+// renderLinePart draws segment part of a wrapped diff line. The first
+// segment starts with the line's marker; the rest with wrapMarker in its
+// place, so the code stays aligned.
+func renderLinePart(line string, spans []syntax.Span, termW, part int) string {
+	if line == "" || termW <= 1 {
+		return ""
+	}
+	color := diffLineColor(line)
+	starts := wrapColumns(line[1:], termW-1, tabWidth)
+	lead := color + line[:1]
+	if part > 0 {
+		lead = styleMuted + wrapMarker + styleReset
+	}
+	text, _ := paint(line[1:], spans, color, starts[part], termW-1, tabWidth)
+	return lead + text
+}
+
+// renderSideRowPart draws segment part of a wrapped side-by-side row.
+// A side with fewer segments is left blank.
+func renderSideRowPart(r sideRow, termW, numW int, spans [][]syntax.Span, part int) string {
+	if r.span {
+		return renderLinePart(r.full, nil, termW, part)
+	}
+	half := (termW - 1) / 2
+	return cellPart(r.left, half, numW, spans, part) + styleMuted + "│" + styleReset + cellPart(r.right, termW-1-half, numW, spans, part)
+}
+
+func cellPart(c sideCell, width, numW int, spans [][]syntax.Span, part int) string {
+	if c.kind == 0 {
+		return strings.Repeat(" ", width)
+	}
+	starts := wrapColumns(c.text, max(width-numW-2, 0), splitTabWidth)
+	if part >= len(starts) {
+		return strings.Repeat(" ", width)
+	}
+	return renderCell(c, width, numW, spans, starts[part], part > 0)
+}
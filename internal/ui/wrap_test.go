@@ -0,0 +1,156 @@
+package ui
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/anuchito/replay/internal/navigator"
+)
+
+func TestWrapColumns(t *testing.T) {
+	tests := []struct {
+		text  string
+		width int
+		want  []int
+	}{
+		{"", 5, []int{0}},
+		{"abcde", 5, []int{0}},
+		{"abcdefghij", 4, []int{0, 4, 8}},
+		{"ab漢字", 3, []int{0, 2, 4}}, // a wide character moves to the next row whole
+		{"a\tb", 4, []int{0, 4, 8}}, // a tab can be broken
+		{"漢", 1, []int{0}},          // too wide for any row: it stays
+	}
+	for _, tt := range tests {
+		if got := wrapColumns(tt.text, tt.width, 8); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("wrapColumns(%q, %d): expected %v, got %v", tt.text, tt.width, tt.want, got)
+		}
+	}
+}
+
+// renderDiff renders dv and returns the diff area's rows without colors.
+func renderDiff(dv *DiffView, termW, termH int) []string {
+	var buf bytes.Buffer
+	dv.Render(&buf, termW, termH, navigator.Commit{}, navigator.Commit{}, true, Progress{})
+	rows := strings.Split(ansiRe.ReplaceAllString(buf.String(), ""), "\r\n")
+	return rows[2 : 2+dv.visibleLines(termH)]
+}
+
+func TestDiffView_Wrap(t *testing.T) {
+	dv := NewDiffView()
+	dv.Wrap = true
+	dv.SetDiff([]string{"@@ -1 +1 @@", "+" + strings.Repeat("x", 25), " end"})
+
+	rows := renderDiff(dv, 10, 10)
+	want := []string{"@@ -1 +1 @", "↪@", "+xxxxxxxxx", "↪xxxxxxxxx", "↪xxxxxxx", " end"}
+	for i, w := range want {
+		if rows[i] != w {
+			t.Errorf("row %d: expected %q, got %q", i, w, rows[i])
+		}
+	}
+	if dv.rows() != 6 {
+		t.Errorf("expected 6 display rows, got %d", dv.rows())
+	}
+
+	dv.ScrollTo(100, 6) // two rows of diff area
+	if dv.Offset() != 4 {
+		t.Errorf("expected scrolling clamped to display rows, got %d", dv.Offset())
+	}
+}
+
+func TestDiffView_WrapSideBySide(t *testing.T) {
+	dv := NewDiffView()
+	dv.Wrap = true
+	dv.SplitWidth = 40
+	dv.SetDiff([]string{"@@ -1 +1 @@", "-" + strings.Repeat("a", 30), "+b"})
+
+	rows := renderDiff(dv, 41, 10)
+	if !strings.HasPrefix(rows[1], "1 -aaaaaaaaaaaaaaaaa│1 +b") {
+		t.Errorf("expected the first segment with both line numbers, got %q", rows[1])
+	}
+	if !strings.HasPrefix(rows[2], "  ↪aaaaaaaaaaaaa") {
+		t.Errorf("expected a continuation row, got %q", rows[2])
+	}
+	for i, r := range rows[1:3] {
+		if n := utf8.RuneCountInString(r); n != 41 {
+			t.Errorf("row %d: expected 41 columns, got %d: %q", i+1, n, r)
+		}
+	}
+}
+
+func TestDiffView_WrapCollapsesMinifiedFiles(t *testing.T) {
+	diff := []string{
+		"diff --git a/app.min.js b/app.min.js",
+		"@@ -1 +1 @@",
+		"-" + strings.Repeat("a", 3000),
+		"+" + strings.Repeat("b", 3000),
+		"diff --git a/main.go b/main.go",
+		"@@ -1 +1 @@",
+		"-old",
+		"+new",
+	}
+	dv := NewDiffView()
+	dv.Wrap = true
+	dv.SetDiff(diff)
+
+	rows := renderDiff(dv, 80, 20)
+	if rows[0] != "diff --git a/app.min.js b/app.min.js" {
+		t.Errorf("expected the file header to stay, got %q", rows[0])
+	}
+	if !strings.HasPrefix(rows[1], "⋯ 3 lines not wrapped: the longest is 3001 columns") {
+		t.Errorf("expected the hunks collapsed to one row, got %q", rows[1])
+	}
+	if rows[2] != "diff --git a/main.go b/main.go" || rows[4] != "-old" {
+		t.Errorf("expected the next file shown in full, got %q", rows[2:5])
+	}
+
+	dv.Wrap = false
+	if rows := renderDiff(dv, 80, 20); !strings.HasPrefix(rows[2], "-aaaa") {
+		t.Errorf("expected no collapsing without wrap, got %q", rows[2])
+	}
+}
+
+func TestDiffView_RelayoutKeepsTopLine(t *testing.T) {
+	var diff []string
+	for i := 0; i < 30; i++ {
+		diff = append(diff, fmt.Sprintf(" line %d %s", i, strings.Repeat("x", 30)))
+	}
+	dv := NewDiffView()
+	dv.SetDiff(diff)
+	renderDiff(dv, 20, 10)
+	dv.ScrollTo(10, 10)
+
+	dv.Wrap = true
+	rows := renderDiff(dv, 20, 10)
+	if !strings.HasPrefix(rows[0], " line 10 ") {
+		t.Errorf("expected line 10 to stay on top, got %q", rows[0])
+	}
+}
+
+func TestDiffView_ResizeClampsScroll(t *testing.T) {
+	dv := NewDiffView()
+	lines := []string{"@@ -1,20 +1,20 @@"}
+	for i := 0; i < 20; i++ {
+		lines = append(lines, fmt.Sprintf(" line %d", i))
+	}
+	dv.SetDiff(lines)
+
+	renderDiff(dv, 80, 10)
+	dv.ScrollTo(100, 10) // the last 6 rows
+	if dv.Offset() != 15 {
+		t.Fatalf("expected offset 15, got %d", dv.Offset())
+	}
+
+	// A taller terminal shows more of the end of the diff instead of
+	// leaving blank rows below it.
+	rows := renderDiff(dv, 80, 20)
+	if dv.Offset() != 5 || rows[len(rows)-1] != " line 19" {
+		t.Errorf("expected offset 5 ending at the last line, got %d ending %q", dv.Offset(), rows[len(rows)-1])
+	}
+	rows = renderDiff(dv, 80, 40)
+	if dv.Offset() != 0 || rows[0] != "@@ -1,20 +1,20 @@" {
+		t.Errorf("expected the whole diff from the top, got offset %d starting %q", dv.Offset(), rows[0])
+	}
+}